@@ -0,0 +1,265 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// BatchConfig sets up the batch channel
+type BatchConfig struct {
+	// Max is the maximum number of events in the batch,
+	// the batch is sent once it is full, unlimited by default
+	Max int
+	// Window is the time the batch collects events
+	// after the first event of the batch is received
+	Window time.Duration
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (b *BatchConfig) CheckAndSetDefaults() error {
+	if b.Max < 0 {
+		return trace.BadParameter("BatchConfig Max can not be negative")
+	}
+	if b.Window <= 0 {
+		return trace.BadParameter(`set BatchConfig Window, for example BatchConfig{Window: "10s"}`)
+	}
+	return nil
+}
+
+// NewBatch creates batch channels, the event of the process
+// is BatchValue with the list of the events of the batched channel
+type NewBatch struct {
+}
+
+// NewInstance returns a function creating batch channels
+func (n *NewBatch) NewInstance(group Group) (Group, interface{}) {
+	scope := WithArgumentScope(group)
+	return scope, func(channel Channel, config interface{}) (Channel, error) {
+		out, err := Batch(channel, config)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		b := out.(*BatchChannel)
+		if proto, err := scope.LocalDefinition(KeyEvent); err == nil {
+			b.proto = proto
+		}
+		if err := group.AddDefinition(KeyEvent, b.prototype()); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return b, nil
+	}
+}
+
+// Batch returns a channel that collects events of the channel
+// and sends them together, once the batch is full or the window
+// after the first event of the batch ends. Events is the list
+// of the batched events and Count is their number:
+//
+// Process(Spec{
+// Watch: Batch(github.Branches(src), BatchConfig{Max: 10, Window: "1m"}),
+// Run: func() {
+// for _, e := range event.Events {
+// Infof("Commit %v", e.Commit)
+// }
+// },
+// })
+//
+func Batch(channel Channel, config interface{}) (Channel, error) {
+	var cfg BatchConfig
+	if err := evalChannelArg(config, &cfg); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &BatchChannel{
+		in:      channel,
+		config:  config,
+		cfg:     cfg,
+		eventsC: make(chan Event, DefaultChannelBufferSize),
+	}, nil
+}
+
+// BatchChannel collects events and sends them together
+type BatchChannel struct {
+	in      Channel
+	config  interface{}
+	cfg     BatchConfig
+	eventsC chan Event
+	// proto is the event of the batched channel,
+	// nil if the channel does not define event
+	proto interface{}
+}
+
+// String returns user friendly representation of the channel
+func (b *BatchChannel) String() string {
+	return fmt.Sprintf("Batch(%v)", b.in)
+}
+
+// Watches returns resources watched by the batched channel
+func (b *BatchChannel) Watches() []Resource {
+	return watches(b.in)
+}
+
+// Start starts the batched channel and collects its events
+func (b *BatchChannel) Start(pctx context.Context) error {
+	startChannel(pctx, b.in)
+	go func() {
+		var events []Event
+		// windowC is set while the batch collects events
+		var windowC <-chan time.Time
+		for {
+			select {
+			case <-pctx.Done():
+				return
+			case <-b.in.Done():
+				if len(events) != 0 {
+					sendEvent(pctx, b.eventsC, b.newEvent(events))
+				}
+				return
+			case event := <-b.in.Events():
+				events = append(events, event)
+				if len(events) == 1 {
+					windowC = time.After(b.cfg.Window)
+				}
+				if b.cfg.Max == 0 || len(events) < b.cfg.Max {
+					continue
+				}
+			case <-windowC:
+			}
+			if !sendEvent(pctx, b.eventsC, b.newEvent(events)) {
+				return
+			}
+			events, windowC = nil, nil
+		}
+	}()
+	return nil
+}
+
+// newEvent returns a new batch event
+func (b *BatchChannel) newEvent(events []Event) *BatchEvent {
+	return &BatchEvent{Events: events, proto: b.proto, created: time.Now().UTC()}
+}
+
+// prototype returns the value of event in the processes
+func (b *BatchChannel) prototype() BatchValue {
+	return BatchValue{
+		Events: reflect.MakeSlice(reflect.SliceOf(batchElementType(b.proto)), 0, 0).Interface(),
+		Count:  Int(0),
+	}
+}
+
+// Events returns the batch events
+func (b *BatchChannel) Events() <-chan Event {
+	return b.eventsC
+}
+
+// Done returns a channel closed when the batched channel is done
+func (b *BatchChannel) Done() <-chan struct{} {
+	return b.in.Done()
+}
+
+// UnmarshalEvent restores the batch and the batched events
+func (b *BatchChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	var e batchEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	events := make([]Event, 0, len(e.Events))
+	for _, data := range e.Events {
+		event, err := unmarshalEvent(ctx, b.in, data)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		events = append(events, event)
+	}
+	return &BatchEvent{Events: events, proto: b.proto, created: e.Created}, nil
+}
+
+// MarshalCode marshals channel to code
+func (b *BatchChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Batch, b.in, b.config).MarshalCode(ctx)
+}
+
+// BatchEvent is the batch of events
+type BatchEvent struct {
+	// Events are the batched events
+	Events  []Event
+	proto   interface{}
+	created time.Time
+}
+
+// Created returns the time the batch was sent
+func (b *BatchEvent) Created() time.Time {
+	return b.created
+}
+
+// String returns user friendly representation of the event
+func (b *BatchEvent) String() string {
+	return fmt.Sprintf("Batch(events=%v)", len(b.Events))
+}
+
+// MarshalEvent serializes the batched events
+func (b *BatchEvent) MarshalEvent() ([]byte, error) {
+	e := batchEventJSON{Created: b.created}
+	for _, event := range b.Events {
+		s, ok := event.(SerializableEvent)
+		if !ok {
+			return nil, trace.NotImplemented("%v can not be serialized", event)
+		}
+		data, err := s.MarshalEvent()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		e.Events = append(e.Events, data)
+	}
+	return json.Marshal(e)
+}
+
+// batchEventJSON is a serialized batch event
+type batchEventJSON struct {
+	Created time.Time         `json:"created"`
+	Events  []json.RawMessage `json:"events"`
+}
+
+// AddMetadata sets BatchValue as event, the list of events
+// has the values of event of the batched channel, or the
+// descriptions of the events if the channel does not define event
+func (b *BatchEvent) AddMetadata(ctx ExecutionContext) {
+	elemType := batchElementType(b.proto)
+	values := reflect.MakeSlice(reflect.SliceOf(elemType), 0, len(b.Events))
+	for _, event := range b.Events {
+		if b.proto == nil {
+			values = reflect.Append(values, reflect.ValueOf(String(fmt.Sprintf("%v", event))))
+			continue
+		}
+		value := reflect.ValueOf(eventContext(ctx, event).Value(ContextKey(KeyEvent)))
+		if !value.IsValid() || value.Type() != elemType {
+			value = reflect.Zero(elemType)
+		}
+		values = reflect.Append(values, value)
+	}
+	ctx.SetValue(ContextKey(KeyEvent), BatchValue{Events: values.Interface(), Count: Int(len(b.Events))})
+}
+
+// batchElementType returns the type of the batched events in the list,
+// events are described with strings if the channel does not define event
+func batchElementType(proto interface{}) reflect.Type {
+	if proto == nil {
+		return reflect.TypeOf(String(""))
+	}
+	return reflect.TypeOf(proto)
+}
+
+// BatchValue is the event of the processes watching Batch
+type BatchValue struct {
+	// Events is the list of the batched events
+	Events interface{}
+	// Count is the number of the batched events
+	Count Int
+}
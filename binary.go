@@ -0,0 +1,351 @@
+package force
+
+import (
+	"bytes"
+	"go/token"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// Binary returns a new binary expression, operand types
+// are checked to match the operator, for example:
+//
+// event.Branch == "master"
+// count > 3 && enabled
+// "v" + ver
+//
+func Binary(op token.Token, x, y Expression) (Expression, error) {
+	var evalType interface{}
+	switch op {
+	case token.LAND, token.LOR:
+		if err := ExpectBool(x); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if err := ExpectBool(y); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		evalType = false
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+		t, err := arithmeticType(op, x, y)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		evalType = t
+	case token.EQL, token.NEQ:
+		if err := ExpectEqualTypes(x, y); err != nil {
+			return nil, trace.BadParameter("operator %v: %v", op, err)
+		}
+		if !reflect.TypeOf(x.Type()).Comparable() {
+			return nil, trace.BadParameter("operator %v is not defined for %v", op, x.Type())
+		}
+		evalType = false
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		if err := ExpectEqualTypes(x, y); err != nil {
+			return nil, trace.BadParameter("operator %v: %v", op, err)
+		}
+		switch x.Type().(type) {
+		case string, int, float64, time.Duration, time.Time:
+		default:
+			return nil, trace.BadParameter("operator %v is only defined for strings, numbers, durations and times, got %v", op, x.Type())
+		}
+		evalType = false
+	default:
+		return nil, trace.BadParameter("operator %v is not supported", op)
+	}
+	return &BinaryExpr{
+		op:       op,
+		x:        x,
+		y:        y,
+		evalType: evalType,
+	}, nil
+}
+
+// arithmeticType returns the type of the arithmetic expression,
+// durations could be added to or subtracted from times,
+// multiplied or divided by ints
+func arithmeticType(op token.Token, x, y Expression) (interface{}, error) {
+	switch xt := x.Type().(type) {
+	case time.Time:
+		switch {
+		case op == token.ADD && ExpectDuration(y) == nil:
+			return time.Time{}, nil
+		case op == token.SUB && ExpectDuration(y) == nil:
+			return time.Time{}, nil
+		case op == token.SUB && ExpectTime(y) == nil:
+			return time.Duration(0), nil
+		}
+	case time.Duration:
+		switch {
+		case (op == token.ADD || op == token.SUB) && ExpectDuration(y) == nil:
+			return time.Duration(0), nil
+		case (op == token.MUL || op == token.QUO) && ExpectInt(y) == nil:
+			return time.Duration(0), nil
+		}
+	case string:
+		if op == token.ADD && ExpectString(y) == nil {
+			return "", nil
+		}
+	case int:
+		if ExpectInt(y) == nil {
+			return 0, nil
+		}
+	case float64:
+		if op != token.REM && ExpectFloat(y) == nil {
+			return 0.0, nil
+		}
+	default:
+		return nil, trace.BadParameter("operator %v is not defined for %T", op, xt)
+	}
+	return nil, trace.BadParameter("operator %v is not defined for %T and %T", op, x.Type(), y.Type())
+}
+
+// BinaryExpr is an arithmetic, comparison or logical
+// expression with two operands
+type BinaryExpr struct {
+	op       token.Token
+	x        Expression
+	y        Expression
+	evalType interface{}
+}
+
+// Type returns the type of the evaluated expression
+func (b *BinaryExpr) Type() interface{} {
+	return b.evalType
+}
+
+// Eval evaluates both operands and applies the operator,
+// logical operators are short circuited
+func (b *BinaryExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	switch b.op {
+	case token.LAND, token.LOR:
+		x, err := EvalBool(ctx, b.x)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if b.op == token.LAND && !x {
+			return false, nil
+		}
+		if b.op == token.LOR && x {
+			return true, nil
+		}
+		return EvalBool(ctx, b.y)
+	}
+	x, err := b.x.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	y, err := b.y.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if xv, ok := x.(time.Time); ok {
+		return evalTimes(b.op, xv, y)
+	}
+	if xv, ok := x.(time.Duration); ok {
+		return evalDurations(b.op, xv, y)
+	}
+	switch b.op {
+	case token.EQL:
+		return equal(x, y), nil
+	case token.NEQ:
+		return !equal(x, y), nil
+	}
+	switch xv := x.(type) {
+	case int:
+		yv, ok := y.(int)
+		if !ok {
+			return nil, trace.BadParameter("expected int, got %T", y)
+		}
+		return evalInts(b.op, xv, yv)
+	case string:
+		yv, ok := y.(string)
+		if !ok {
+			return nil, trace.BadParameter("expected string, got %T", y)
+		}
+		return evalStrings(b.op, xv, yv)
+	case float64:
+		yv, ok := y.(float64)
+		if !ok {
+			return nil, trace.BadParameter("expected float, got %T", y)
+		}
+		return evalFloats(b.op, xv, yv)
+	default:
+		return nil, trace.BadParameter("operator %v is not supported for %T", b.op, x)
+	}
+}
+
+// equal returns true if the evaluated values are equal,
+// times are equal if they denote the same instant
+func equal(x, y interface{}) bool {
+	if xv, ok := x.(time.Time); ok {
+		if yv, ok := y.(time.Time); ok {
+			return xv.Equal(yv)
+		}
+	}
+	return x == y
+}
+
+func evalInts(op token.Token, x, y int) (interface{}, error) {
+	switch op {
+	case token.ADD:
+		return x + y, nil
+	case token.SUB:
+		return x - y, nil
+	case token.MUL:
+		return x * y, nil
+	case token.QUO:
+		if y == 0 {
+			return nil, trace.BadParameter("integer division by zero")
+		}
+		return x / y, nil
+	case token.REM:
+		if y == 0 {
+			return nil, trace.BadParameter("integer division by zero")
+		}
+		return x % y, nil
+	case token.LSS:
+		return x < y, nil
+	case token.LEQ:
+		return x <= y, nil
+	case token.GTR:
+		return x > y, nil
+	case token.GEQ:
+		return x >= y, nil
+	}
+	return nil, trace.BadParameter("operator %v is not supported for ints", op)
+}
+
+func evalStrings(op token.Token, x, y string) (interface{}, error) {
+	switch op {
+	case token.ADD:
+		return x + y, nil
+	case token.LSS:
+		return x < y, nil
+	case token.LEQ:
+		return x <= y, nil
+	case token.GTR:
+		return x > y, nil
+	case token.GEQ:
+		return x >= y, nil
+	}
+	return nil, trace.BadParameter("operator %v is not supported for strings", op)
+}
+
+func evalFloats(op token.Token, x, y float64) (interface{}, error) {
+	switch op {
+	case token.ADD:
+		return x + y, nil
+	case token.SUB:
+		return x - y, nil
+	case token.MUL:
+		return x * y, nil
+	case token.QUO:
+		if y == 0 {
+			return nil, trace.BadParameter("float division by zero")
+		}
+		return x / y, nil
+	case token.LSS:
+		return x < y, nil
+	case token.LEQ:
+		return x <= y, nil
+	case token.GTR:
+		return x > y, nil
+	case token.GEQ:
+		return x >= y, nil
+	}
+	return nil, trace.BadParameter("operator %v is not supported for floats", op)
+}
+
+func evalDurations(op token.Token, x time.Duration, y interface{}) (interface{}, error) {
+	if n, ok := y.(int); ok {
+		switch op {
+		case token.MUL:
+			return x * time.Duration(n), nil
+		case token.QUO:
+			if n == 0 {
+				return nil, trace.BadParameter("duration division by zero")
+			}
+			return x / time.Duration(n), nil
+		}
+		return nil, trace.BadParameter("operator %v is not supported for duration and int", op)
+	}
+	yv, ok := y.(time.Duration)
+	if !ok {
+		return nil, trace.BadParameter("expected duration, got %T", y)
+	}
+	switch op {
+	case token.ADD:
+		return x + yv, nil
+	case token.SUB:
+		return x - yv, nil
+	case token.EQL:
+		return x == yv, nil
+	case token.NEQ:
+		return x != yv, nil
+	case token.LSS:
+		return x < yv, nil
+	case token.LEQ:
+		return x <= yv, nil
+	case token.GTR:
+		return x > yv, nil
+	case token.GEQ:
+		return x >= yv, nil
+	}
+	return nil, trace.BadParameter("operator %v is not supported for durations", op)
+}
+
+func evalTimes(op token.Token, x time.Time, y interface{}) (interface{}, error) {
+	if d, ok := y.(time.Duration); ok {
+		switch op {
+		case token.ADD:
+			return x.Add(d), nil
+		case token.SUB:
+			return x.Add(-d), nil
+		}
+		return nil, trace.BadParameter("operator %v is not supported for time and duration", op)
+	}
+	yv, ok := y.(time.Time)
+	if !ok {
+		return nil, trace.BadParameter("expected time, got %T", y)
+	}
+	switch op {
+	case token.SUB:
+		return x.Sub(yv), nil
+	case token.EQL:
+		return equal(x, yv), nil
+	case token.NEQ:
+		return !equal(x, yv), nil
+	case token.LSS:
+		return x.Before(yv), nil
+	case token.LEQ:
+		return !x.After(yv), nil
+	case token.GTR:
+		return x.After(yv), nil
+	case token.GEQ:
+		return !x.Before(yv), nil
+	}
+	return nil, trace.BadParameter("operator %v is not supported for times", op)
+}
+
+// MarshalCode marshals binary expression to code, the expression
+// is always wrapped in parentheses to preserve the evaluation order
+func (b *BinaryExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, "(")
+	data, err := MarshalCode(ctx, b.x)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	buf.Write(data)
+	io.WriteString(buf, " "+b.op.String()+" ")
+	data, err = MarshalCode(ctx, b.y)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	buf.Write(data)
+	io.WriteString(buf, ")")
+	return buf.Bytes(), nil
+}
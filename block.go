@@ -0,0 +1,74 @@
+package force
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gravitational/trace"
+)
+
+// Block groups statements of the block, if/else or switch
+// statement body, statements are evaluated in sequence
+// in a new scope
+func Block(actions ...Action) *BlockAction {
+	return &BlockAction{
+		actions: actions,
+	}
+}
+
+// BlockAction is a block of statements,
+// unlike Sequence, it could be empty
+type BlockAction struct {
+	actions []Action
+}
+
+// Type returns the type of the last statement of the block
+func (b *BlockAction) Type() interface{} {
+	if len(b.actions) == 0 {
+		return false
+	}
+	return b.actions[len(b.actions)-1].Type()
+}
+
+// Children returns statements of the block
+func (b *BlockAction) Children() []interface{} {
+	return actionNodes(b.actions)
+}
+
+// EvalWithScope evaluates statements in sequence using the passed scope
+func (b *BlockAction) EvalWithScope(ctx ExecutionContext) (interface{}, error) {
+	if len(b.actions) == 0 {
+		return false, nil
+	}
+	seq := &SequenceAction{actions: b.actions}
+	return seq.EvalWithScope(ctx)
+}
+
+// Eval evaluates statements in sequence in a new scope
+func (b *BlockAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	return b.EvalWithScope(WithRuntimeScope(ctx))
+}
+
+// MarshalCode marshals block to code
+func (b *BlockAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, "{\n")
+	if err := b.marshalStatements(ctx, buf); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	io.WriteString(buf, "}")
+	return buf.Bytes(), nil
+}
+
+// marshalStatements writes statements of the block, one per line
+func (b *BlockAction) marshalStatements(ctx ExecutionContext, buf *bytes.Buffer) error {
+	for _, statement := range b.actions {
+		data, err := statement.MarshalCode(ctx)
+		if err != nil {
+			return trace.Wrap(err)
+		}
+		buf.Write(data)
+		io.WriteString(buf, "\n")
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+const (
+	// CompletedSucceeded is a status of the successful run
+	CompletedSucceeded = "succeeded"
+	// CompletedFailed is a status of the failed run
+	CompletedFailed = "failed"
+)
+
+// CommitEvent is an event associated with a commit,
+// e.g. a push to the branch or a pull request update
+type CommitEvent interface {
+	// GetCommit returns commit associated with the event
+	GetCommit() string
+}
+
+// NewCompletions returns completions publishing completion events
+// of the processes to the Completed channels
+func NewCompletions() *Completions {
+	return &Completions{
+		channels: make(map[*CompletedChannel]struct{}),
+	}
+}
+
+// Completions publishes completion events of the processes,
+// the runner sets it as KeyCompletions plugin of the group
+type Completions struct {
+	mutex    sync.RWMutex
+	channels map[*CompletedChannel]struct{}
+}
+
+// GetCompletions returns completions of the group
+func GetCompletions(group Group) (*Completions, error) {
+	out, ok := group.GetPlugin(KeyCompletions)
+	if !ok {
+		return nil, trace.NotFound("process completions are not supported by %v", group)
+	}
+	completions, ok := out.(*Completions)
+	if !ok {
+		return nil, trace.BadParameter("expected *Completions, got %T", out)
+	}
+	return completions, nil
+}
+
+// Publish sends the completion event to the channels
+// watching the process, the event is queued by every channel,
+// so Publish never blocks and the events are never dropped
+func (c *Completions) Publish(ctx context.Context, event *CompletedEvent) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for channel := range c.channels {
+		if !channel.watches(string(event.Process)) {
+			continue
+		}
+		channel.push(event)
+	}
+}
+
+// subscribe adds the channel
+func (c *Completions) subscribe(channel *CompletedChannel) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.channels[channel] = struct{}{}
+}
+
+// unsubscribe removes the channel
+func (c *Completions) unsubscribe(channel *CompletedChannel) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.channels, channel)
+}
+
+// UndefinedCompletions returns names of the processes watched by
+// the Completed channels of the processes defined by the nodes,
+// that are not defined by the nodes
+func UndefinedCompletions(nodes ...interface{}) []string {
+	processes := Processes(nodes...)
+	defined := make(map[string]bool, len(processes))
+	for _, p := range processes {
+		defined[p.Name()] = true
+	}
+	var out []string
+	seen := make(map[string]bool)
+	for _, p := range processes {
+		watcher, ok := p.Channel().(Watcher)
+		if !ok {
+			continue
+		}
+		for _, watched := range watcher.Watches() {
+			if watched.Kind != ResourceProcessCompletion || defined[watched.Name] || seen[watched.Name] {
+				continue
+			}
+			seen[watched.Name] = true
+			out = append(out, watched.Name)
+		}
+	}
+	return out
+}
+
+// NewCompleted creates channels of completion events,
+// the channel defines the completion event
+type NewCompleted struct {
+}
+
+// NewInstance returns a function creating completed channels
+func (n *NewCompleted) NewInstance(group Group) (Group, interface{}) {
+	group.AddDefinition(KeyEvent, CompletedEvent{})
+	return group, func(processes ...Expression) (Channel, error) {
+		completions, err := GetCompletions(group)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return Completed(completions, processes...)
+	}
+}
+
+// Completed returns a channel that receives completion events
+// of the processes with the names, in scripts it is used as:
+//
+// Completed("unit", "integration")
+//
+func Completed(completions *Completions, processes ...Expression) (Channel, error) {
+	if len(processes) == 0 {
+		return nil, trace.BadParameter(`Completed needs at least one process name, for example Completed("build")`)
+	}
+	names := make([]string, 0, len(processes))
+	for _, process := range processes {
+		if err := ExpectString(process); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		var name string
+		if err := evalChannelArg(process, &name); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if name == "" {
+			return nil, trace.BadParameter("Completed process name can not be empty")
+		}
+		names = append(names, name)
+	}
+	return &CompletedChannel{
+		completions: completions,
+		processes:   names,
+		signalC:     make(chan struct{}, 1),
+		eventsC:     make(chan Event, DefaultChannelBufferSize),
+	}, nil
+}
+
+// CompletedChannel receives completion events of the processes
+type CompletedChannel struct {
+	completions *Completions
+	processes   []string
+	mutex       sync.Mutex
+	// queue holds the published events that are not sent yet,
+	// so the completing runs are not blocked by the slow processes
+	queue []Event
+	// signalC signals that the events are queued
+	signalC chan struct{}
+	eventsC chan Event
+}
+
+// String returns user friendly representation of the channel
+func (c *CompletedChannel) String() string {
+	return fmt.Sprintf("Completed(%v)", c.processes)
+}
+
+// watches returns true if the channel watches the process
+func (c *CompletedChannel) watches(process string) bool {
+	for _, p := range c.processes {
+		if p == process {
+			return true
+		}
+	}
+	return false
+}
+
+// Watches returns completions of the processes
+func (c *CompletedChannel) Watches() []Resource {
+	out := make([]Resource, 0, len(c.processes))
+	for _, p := range c.processes {
+		out = append(out, ProcessCompletion(p))
+	}
+	return out
+}
+
+// push queues the event, push never blocks
+func (c *CompletedChannel) push(event Event) {
+	c.mutex.Lock()
+	c.queue = append(c.queue, event)
+	c.mutex.Unlock()
+	select {
+	case c.signalC <- struct{}{}:
+	default:
+	}
+}
+
+// pop returns the queued events and empties the queue
+func (c *CompletedChannel) pop() []Event {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	out := c.queue
+	c.queue = nil
+	return out
+}
+
+// Start subscribes to the completion events until the context is closed
+func (c *CompletedChannel) Start(pctx context.Context) error {
+	c.completions.subscribe(c)
+	go func() {
+		defer c.completions.unsubscribe(c)
+		for {
+			select {
+			case <-c.signalC:
+			case <-pctx.Done():
+				return
+			}
+			for _, event := range c.pop() {
+				select {
+				case c.eventsC <- event:
+				case <-pctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Events returns completion events
+func (c *CompletedChannel) Events() <-chan Event {
+	return c.eventsC
+}
+
+// Done returns nil, completed channel never completes
+func (c *CompletedChannel) Done() <-chan struct{} {
+	return nil
+}
+
+// UnmarshalEvent restores completion event
+func (c *CompletedChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	var e completedEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return e.event(), nil
+}
+
+// MarshalCode marshals channel to code
+func (c *CompletedChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	call := &FnCall{FnName: "Completed"}
+	for _, process := range c.processes {
+		call.Args = append(call.Args, process)
+	}
+	return call.MarshalCode(ctx)
+}
+
+// NewCompletedEvent returns the completion event of the process run
+// triggered by the event, err is the error of the failed run
+func NewCompletedEvent(process, id string, trigger Event, err error) *CompletedEvent {
+	e := &CompletedEvent{
+		Process: String(process),
+		ID:      String(id),
+		Event:   String(fmt.Sprintf("%v", trigger)),
+		Status:  CompletedSucceeded,
+		created: time.Now().UTC(),
+	}
+	if commit, ok := trigger.(CommitEvent); ok {
+		e.Commit = String(commit.GetCommit())
+	}
+	if err != nil {
+		e.Status = CompletedFailed
+		e.Error = String(err.Error())
+	}
+	return e
+}
+
+// CompletedEvent is sent when the process run completes
+type CompletedEvent struct {
+	// Process is the name of the process
+	Process String
+	// ID is the ID of the run
+	ID String
+	// Event describes the event that has triggered the run
+	Event String
+	// Commit is the commit of the event that has triggered the run,
+	// empty if the event is not associated with a commit
+	Commit String
+	// Status is "succeeded" or "failed"
+	Status String
+	// Error is the error of the failed run
+	Error   String
+	created time.Time
+}
+
+// Created returns the time the run has completed
+func (c *CompletedEvent) Created() time.Time {
+	return c.created
+}
+
+// Succeeded returns true if the run has succeeded
+func (c *CompletedEvent) Succeeded() bool {
+	return c.Status == CompletedSucceeded
+}
+
+// JoinKey returns the key joining the runs of the processes
+// triggered by the same commit, or by the same event
+func (c *CompletedEvent) JoinKey() string {
+	if c.Commit != "" {
+		return string(c.Commit)
+	}
+	return string(c.Event)
+}
+
+// String returns user friendly representation of the event
+func (c *CompletedEvent) String() string {
+	return fmt.Sprintf("Completed(process=%v, id=%v, status=%v)", c.Process, c.ID, c.Status)
+}
+
+// MarshalEvent serializes completion event
+func (c *CompletedEvent) MarshalEvent() ([]byte, error) {
+	return json.Marshal(newCompletedEventJSON(c))
+}
+
+// AddMetadata sets the event in the context,
+// so the status is available as event.Status
+func (c *CompletedEvent) AddMetadata(ctx ExecutionContext) {
+	ctx.SetValue(ContextKey(KeyEvent), *c)
+}
+
+// completedEventJSON is a serialized completion event
+type completedEventJSON struct {
+	Process string    `json:"process"`
+	ID      string    `json:"id"`
+	Event   string    `json:"event"`
+	Commit  string    `json:"commit,omitempty"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// newCompletedEventJSON returns serialized completion event
+func newCompletedEventJSON(c *CompletedEvent) completedEventJSON {
+	return completedEventJSON{
+		Process: string(c.Process),
+		ID:      string(c.ID),
+		Event:   string(c.Event),
+		Commit:  string(c.Commit),
+		Status:  string(c.Status),
+		Error:   string(c.Error),
+		Created: c.created,
+	}
+}
+
+// event returns restored completion event
+func (e completedEventJSON) event() *CompletedEvent {
+	return &CompletedEvent{
+		Process: String(e.Process),
+		ID:      String(e.ID),
+		Event:   String(e.Event),
+		Commit:  String(e.Commit),
+		Status:  String(e.Status),
+		Error:   String(e.Error),
+		created: e.Created,
+	}
+}
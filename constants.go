@@ -16,8 +16,22 @@ const (
 	KeyCurrentDir = ContextKey("current.dir")
 	// KeyError is an error value
 	KeyError = ContextKey("error")
+	// KeyAttempt is a number of the current Retry attempt
+	KeyAttempt = ContextKey("attempt")
+	// KeySuperseded is set when the execution is cancelled
+	// by a newer run with the same concurrency key
+	KeySuperseded = ContextKey("superseded")
 	// KeyLog is a logger associated with this execution
 	KeyLog = ContextKey("log")
+	// KeyRunURL is a URL of the page of the current run
+	KeyRunURL = ContextKey("run.url")
+	// KeyTracer is a tracer of the current execution
+	KeyTracer = ContextKey("tracer")
+	// KeySpan is a span of the action being evaluated
+	KeySpan = ContextKey("span")
+	// KeyCompletions is a group plugin publishing
+	// completion events of the processes
+	KeyCompletions = ContextKey("completions")
 	// KeyProc is a process name
 	KeyProc = "proc"
 	// KeyID is a unique identifier of the run
@@ -26,6 +40,9 @@ const (
 	KeyForce = "force"
 	// KeyEvent is an event produced by watchers
 	KeyEvent = "event"
+	// KeyReturn is a lexical definition of the enclosing
+	// lambda function used by return statements
+	KeyReturn = "return"
 	// Underscore is underscore symbol
 	Underscore = "_"
 	StringType = "string"
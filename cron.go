@@ -0,0 +1,292 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gravitational/force/pkg/cron"
+
+	"github.com/gravitational/trace"
+)
+
+// CronOptions sets up the cron channel
+type CronOptions struct {
+	// Timezone is a name of the time zone of the schedule,
+	// e.g. "America/New_York", UTC by default
+	Timezone string
+	// Jitter delays every event by a random period up to the jitter,
+	// so processes with the same schedule do not start at the same time
+	Jitter time.Duration
+	// CatchUpMissed sends a single event for the schedules
+	// missed while force was not running, requires StateFile
+	CatchUpMissed bool
+	// StateFile is a path to the file recording the last fire time
+	StateFile string
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (c *CronOptions) CheckAndSetDefaults() error {
+	if c.Timezone == "" {
+		c.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return trace.BadParameter("unknown CronOptions Timezone %q: %v", c.Timezone, err)
+	}
+	if c.Jitter < 0 {
+		return trace.BadParameter("CronOptions Jitter can not be negative")
+	}
+	if c.CatchUpMissed && c.StateFile == "" {
+		return trace.BadParameter("CronOptions CatchUpMissed needs StateFile to record the last fire time")
+	}
+	return nil
+}
+
+// NewCron creates cron channels, the channel defines
+// the event with the scheduled time of the event
+type NewCron struct {
+}
+
+// NewInstance returns a function creating cron channels
+func (n *NewCron) NewInstance(group Group) (Group, interface{}) {
+	group.AddDefinition(KeyEvent, CronEvent{})
+	return group, Cron
+}
+
+// Cron returns a channel that fires on the cron schedule,
+// the schedule has minute, hour, day of month, month and day of week fields,
+// the scheduled time is available as event.Scheduled:
+//
+// Cron("0 3 * * 1-5")
+// Cron("@daily", CronOptions{Timezone: "Europe/Berlin", Jitter: "5m"})
+// Cron("0 * * * *", CronOptions{CatchUpMissed: true, StateFile: "/var/lib/force/hourly"})
+//
+func Cron(schedule Expression, options ...interface{}) (Channel, error) {
+	if err := ExpectString(schedule); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(options) > 1 {
+		return nil, trace.BadParameter("Cron accepts at most one CronOptions parameter")
+	}
+	var spec string
+	if err := evalChannelArg(schedule, &spec); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	parsed, err := cron.Parse(spec)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var opts CronOptions
+	if len(options) == 1 {
+		if err := evalChannelArg(options[0], &opts); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	if err := opts.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	location, err := time.LoadLocation(opts.Timezone)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &CronChannel{
+		eventsC:  make(chan Event, DefaultChannelBufferSize),
+		spec:     spec,
+		options:  options,
+		schedule: parsed,
+		opts:     opts,
+		location: location,
+	}, nil
+}
+
+// CronChannel fires on the cron schedule
+type CronChannel struct {
+	eventsC  chan Event
+	spec     string
+	options  []interface{}
+	schedule *cron.Schedule
+	opts     CronOptions
+	location *time.Location
+}
+
+// String returns user friendly representation of the channel
+func (c *CronChannel) String() string {
+	return fmt.Sprintf("Cron(%q)", c.spec)
+}
+
+// MarshalCode marshals channel to code
+func (c *CronChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	args := append([]interface{}{c.spec}, c.options...)
+	return NewFnCall(Cron, args...).MarshalCode(ctx)
+}
+
+// Start starts sending events on schedule, if the schedule was
+// missed while force was not running, the event for the latest
+// missed time is sent right away
+func (c *CronChannel) Start(pctx context.Context) error {
+	go func() {
+		log := Log(pctx)
+		now := time.Now().In(c.location)
+		if missed := c.missed(log, now); !missed.IsZero() {
+			log.Infof("%v has missed the schedule at %v, catching up.", c, missed)
+			if !c.send(pctx, log, &CronEvent{Scheduled: Time(missed), Missed: true}) {
+				return
+			}
+		}
+		for {
+			next := c.schedule.Next(now)
+			if next.IsZero() {
+				log.Warningf("%v is never activated after %v.", c, now)
+				return
+			}
+			delay := time.Until(next)
+			if c.opts.Jitter > 0 {
+				delay += time.Duration(rand.Int63n(int64(c.opts.Jitter)))
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-pctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			if !c.send(pctx, log, &CronEvent{Scheduled: Time(next)}) {
+				return
+			}
+			// the timer could fire late, e.g. after the host has been suspended,
+			// so the next schedule is computed from the current time
+			// to skip the schedules missed in the meantime
+			now = time.Now().In(c.location)
+			if now.Before(next) {
+				now = next
+			}
+		}
+	}()
+	return nil
+}
+
+// send sends the event and records the scheduled time
+// in the state file, returns false if the context is closed
+func (c *CronChannel) send(ctx context.Context, log Logger, event *CronEvent) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case c.eventsC <- event:
+	}
+	if c.opts.StateFile == "" {
+		return true
+	}
+	if err := c.writeState(time.Time(event.Scheduled)); err != nil {
+		log.WithError(err).Warningf("Failed to record the fire time of %v.", c)
+	}
+	return true
+}
+
+// missed returns the latest scheduled time missed since
+// the recorded fire time, or zero time if nothing was missed
+func (c *CronChannel) missed(log Logger, now time.Time) time.Time {
+	if !c.opts.CatchUpMissed {
+		return time.Time{}
+	}
+	last, err := c.readState()
+	if err != nil {
+		if !trace.IsNotFound(err) {
+			log.WithError(err).Warningf("Failed to read the fire time of %v.", c)
+		}
+		return time.Time{}
+	}
+	var missed time.Time
+	for next := c.schedule.Next(last.In(c.location)); !next.IsZero() && next.Before(now); next = c.schedule.Next(next) {
+		missed = next
+	}
+	return missed
+}
+
+// readState returns the last fire time recorded in the state file
+func (c *CronChannel) readState() (time.Time, error) {
+	data, err := ioutil.ReadFile(c.opts.StateFile)
+	if err != nil {
+		return time.Time{}, trace.ConvertSystemError(err)
+	}
+	last, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, trace.BadParameter("failed to parse %v: %v", c.opts.StateFile, err)
+	}
+	return last, nil
+}
+
+// writeState records the fire time in the state file
+func (c *CronChannel) writeState(t time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(c.opts.StateFile), 0700); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	tmp := c.opts.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(t.UTC().Format(time.RFC3339)+"\n"), 0600); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	return trace.ConvertSystemError(os.Rename(tmp, c.opts.StateFile))
+}
+
+// Events returns channel events
+func (c *CronChannel) Events() <-chan Event {
+	return c.eventsC
+}
+
+// UnmarshalEvent restores cron event
+func (c *CronChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	var e cronEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &CronEvent{Scheduled: Time(e.Scheduled.In(c.location)), Missed: Bool(e.Missed)}, nil
+}
+
+// Done returns nil, cron channel never completes
+func (c *CronChannel) Done() <-chan struct{} {
+	return nil
+}
+
+// CronEvent is sent on the cron schedule
+type CronEvent struct {
+	// Scheduled is the scheduled time of the event
+	Scheduled Time
+	// Missed is set for the event catching up
+	// the schedule missed while force was not running
+	Missed Bool
+}
+
+// Created returns the scheduled time of the event
+func (c *CronEvent) Created() time.Time {
+	return time.Time(c.Scheduled)
+}
+
+// String returns user friendly representation of the event
+func (c *CronEvent) String() string {
+	if c.Missed {
+		return fmt.Sprintf("Cron(scheduled=%v, missed)", time.Time(c.Scheduled))
+	}
+	return fmt.Sprintf("Cron(scheduled=%v)", time.Time(c.Scheduled))
+}
+
+// MarshalEvent serializes cron event
+func (c *CronEvent) MarshalEvent() ([]byte, error) {
+	return json.Marshal(cronEventJSON{Scheduled: time.Time(c.Scheduled), Missed: bool(c.Missed)})
+}
+
+// cronEventJSON is a serialized cron event
+type cronEventJSON struct {
+	Scheduled time.Time `json:"scheduled"`
+	Missed    bool      `json:"missed,omitempty"`
+}
+
+// AddMetadata sets the event in the context,
+// so the scheduled time is available as event.Scheduled
+func (c *CronEvent) AddMetadata(ctx ExecutionContext) {
+	ctx.SetValue(ContextKey(KeyEvent), *c)
+}
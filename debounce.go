@@ -0,0 +1,272 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// NewDebounce creates channels debouncing events,
+// the events are the events of the debounced channel
+type NewDebounce struct {
+}
+
+// NewInstance returns a function creating debounce channels
+func (n *NewDebounce) NewInstance(group Group) (Group, interface{}) {
+	scope := WithArgumentScope(group)
+	return scope, func(channel Channel, period Expression) (Channel, error) {
+		out, err := Debounce(channel, period)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if err := defineChannelEvent(scope, group); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return out, nil
+	}
+}
+
+// Debounce returns a channel that sends the last event of the burst
+// once the channel has been quiet for the period, for example
+// saving a file in the editor triggers a single build:
+//
+// Debounce(Files("*.go"), "2s")
+//
+func Debounce(channel Channel, period Expression) (Channel, error) {
+	duration, err := evalPeriod("Debounce", period)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &DebounceChannel{
+		in:      channel,
+		period:  duration,
+		eventsC: make(chan Event, DefaultChannelBufferSize),
+	}, nil
+}
+
+// DebounceChannel sends the last event of the burst
+type DebounceChannel struct {
+	in      Channel
+	period  time.Duration
+	eventsC chan Event
+}
+
+// String returns user friendly representation of the channel
+func (d *DebounceChannel) String() string {
+	return fmt.Sprintf("Debounce(%v, %v)", d.in, d.period)
+}
+
+// Watches returns resources watched by the debounced channel
+func (d *DebounceChannel) Watches() []Resource {
+	return watches(d.in)
+}
+
+// Start starts the debounced channel and debounces its events
+func (d *DebounceChannel) Start(pctx context.Context) error {
+	startChannel(pctx, d.in)
+	go func() {
+		log := Log(pctx)
+		timer := time.NewTimer(d.period)
+		timer.Stop()
+		defer timer.Stop()
+		var pending Event
+		for {
+			select {
+			case <-pctx.Done():
+				return
+			case <-d.in.Done():
+				if pending != nil {
+					sendEvent(pctx, d.eventsC, pending)
+				}
+				return
+			case event := <-d.in.Events():
+				if pending != nil {
+					log.Debugf("%v has replaced %v with %v.", d, pending, event)
+				}
+				pending = event
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(d.period)
+			case <-timer.C:
+				if !sendEvent(pctx, d.eventsC, pending) {
+					return
+				}
+				pending = nil
+			}
+		}
+	}()
+	return nil
+}
+
+// Events returns the debounced events
+func (d *DebounceChannel) Events() <-chan Event {
+	return d.eventsC
+}
+
+// Done returns a channel closed when the debounced channel is done
+func (d *DebounceChannel) Done() <-chan struct{} {
+	return d.in.Done()
+}
+
+// UnmarshalEvent restores the event of the debounced channel
+func (d *DebounceChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	return unmarshalEvent(ctx, d.in, data)
+}
+
+// MarshalCode marshals channel to code
+func (d *DebounceChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Debounce, d.in, d.period).MarshalCode(ctx)
+}
+
+// NewThrottle creates channels throttling events,
+// the events are the events of the throttled channel
+type NewThrottle struct {
+}
+
+// NewInstance returns a function creating throttle channels
+func (n *NewThrottle) NewInstance(group Group) (Group, interface{}) {
+	scope := WithArgumentScope(group)
+	return scope, func(channel Channel, period Expression) (Channel, error) {
+		out, err := Throttle(channel, period)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if err := defineChannelEvent(scope, group); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return out, nil
+	}
+}
+
+// Throttle returns a channel that sends at most one event per period,
+// the first event is sent right away, and the last of the events
+// received during the period is sent when the period ends:
+//
+// Throttle(github.Branches(src), "1m")
+//
+func Throttle(channel Channel, period Expression) (Channel, error) {
+	duration, err := evalPeriod("Throttle", period)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &ThrottleChannel{
+		in:      channel,
+		period:  duration,
+		eventsC: make(chan Event, DefaultChannelBufferSize),
+	}, nil
+}
+
+// ThrottleChannel sends at most one event per period
+type ThrottleChannel struct {
+	in      Channel
+	period  time.Duration
+	eventsC chan Event
+}
+
+// String returns user friendly representation of the channel
+func (t *ThrottleChannel) String() string {
+	return fmt.Sprintf("Throttle(%v, %v)", t.in, t.period)
+}
+
+// Watches returns resources watched by the throttled channel
+func (t *ThrottleChannel) Watches() []Resource {
+	return watches(t.in)
+}
+
+// Start starts the throttled channel and throttles its events
+func (t *ThrottleChannel) Start(pctx context.Context) error {
+	startChannel(pctx, t.in)
+	go func() {
+		log := Log(pctx)
+		// timerC is set while the period after the sent event lasts
+		var timerC <-chan time.Time
+		var pending Event
+		for {
+			select {
+			case <-pctx.Done():
+				return
+			case <-t.in.Done():
+				if pending != nil {
+					sendEvent(pctx, t.eventsC, pending)
+				}
+				return
+			case event := <-t.in.Events():
+				if timerC != nil {
+					if pending != nil {
+						log.Debugf("%v has replaced %v with %v.", t, pending, event)
+					}
+					pending = event
+					continue
+				}
+				if !sendEvent(pctx, t.eventsC, event) {
+					return
+				}
+				timerC = time.After(t.period)
+			case <-timerC:
+				timerC = nil
+				if pending == nil {
+					continue
+				}
+				if !sendEvent(pctx, t.eventsC, pending) {
+					return
+				}
+				pending = nil
+				timerC = time.After(t.period)
+			}
+		}
+	}()
+	return nil
+}
+
+// Events returns the throttled events
+func (t *ThrottleChannel) Events() <-chan Event {
+	return t.eventsC
+}
+
+// Done returns a channel closed when the throttled channel is done
+func (t *ThrottleChannel) Done() <-chan struct{} {
+	return t.in.Done()
+}
+
+// UnmarshalEvent restores the event of the throttled channel
+func (t *ThrottleChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	return unmarshalEvent(ctx, t.in, data)
+}
+
+// MarshalCode marshals channel to code
+func (t *ThrottleChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Throttle, t.in, t.period).MarshalCode(ctx)
+}
+
+// evalPeriod evaluates the period of the channel, period
+// is a constant duration or a duration string, e.g. "10m"
+func evalPeriod(fnName string, period Expression) (time.Duration, error) {
+	if s, ok := period.(String); ok {
+		if s == "" {
+			return 0, trace.BadParameter(
+				`set duration parameter, for example %v("100s"), supported abbreviations: s (seconds), m (minutes), h (hours), for example "100m" is 100 minutes`, fnName)
+		}
+		parsed, err := ParseDuration(s)
+		if err != nil {
+			return 0, trace.Wrap(err)
+		}
+		period = parsed
+	}
+	if err := ExpectDuration(period); err != nil {
+		return 0, trace.Wrap(err)
+	}
+	var duration time.Duration
+	if err := evalChannelArg(period, &duration); err != nil {
+		return 0, trace.Wrap(err)
+	}
+	if duration <= 0 {
+		return 0, trace.BadParameter("%v period should be a positive duration, got %v", fnName, duration)
+	}
+	return duration, nil
+}
@@ -156,3 +156,45 @@ func (c *WrapContext) Process() Process {
 func (w *WrapContext) SetValue(key interface{}, value interface{}) error {
 	return trace.NotImplemented("can't set values on empty context")
 }
+
+// WithCancel returns a new runtime scope that is cancelled
+// when the parent is cancelled or when the returned cancel function is called
+func WithCancel(ctx ExecutionContext) (ExecutionContext, context.CancelFunc) {
+	cctx, cancel := context.WithCancel(ctx)
+	return &cancelScope{
+		RuntimeScope: WithRuntimeScope(ctx),
+		ctx:          cctx,
+	}, cancel
+}
+
+// WithTimeout returns a new runtime scope that is cancelled
+// after the timeout, when the parent is cancelled or when
+// the returned cancel function is called
+func WithTimeout(ctx ExecutionContext, timeout time.Duration) (ExecutionContext, context.CancelFunc) {
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	return &cancelScope{
+		RuntimeScope: WithRuntimeScope(ctx),
+		ctx:          cctx,
+	}, cancel
+}
+
+// cancelScope is a runtime scope with it's own cancellation
+type cancelScope struct {
+	*RuntimeScope
+	ctx context.Context
+}
+
+// Deadline returns the deadline of the scope
+func (c *cancelScope) Deadline() (deadline time.Time, ok bool) {
+	return c.ctx.Deadline()
+}
+
+// Done returns channel that is closed when the scope is cancelled
+func (c *cancelScope) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// Err returns an error associated with the scope
+func (c *cancelScope) Err() error {
+	return c.ctx.Err()
+}
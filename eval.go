@@ -61,6 +61,13 @@ func EmptyContext() ExecutionContext {
 	return WithRuntimeScope(nil)
 }
 
+// evalChannelArg evaluates the argument of the function creating a channel
+// into out, arguments are evaluated right away with empty context,
+// because channels are created before any events are processed
+func evalChannelArg(in, out interface{}) error {
+	return EvalInto(EmptyContext(), in, out)
+}
+
 // ZeroFromAST returns zero value original struct from AST
 func ZeroFromAST(in interface{}) (interface{}, error) {
 	if in == nil {
@@ -0,0 +1,346 @@
+package force
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/gravitational/trace"
+)
+
+// NewFilter creates channels filtering events, the predicate
+// is parsed with the event of the filtered channel
+type NewFilter struct {
+}
+
+// NewInstance returns a function creating filter channels
+func (n *NewFilter) NewInstance(group Group) (Group, interface{}) {
+	scope := WithArgumentScope(group)
+	return scope, func(channel Channel, predicate Expression) (Channel, error) {
+		out, err := Filter(channel, predicate)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if err := defineChannelEvent(scope, group); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return out, nil
+	}
+}
+
+// Filter returns a channel that passes only the events
+// matching the predicate, the predicate is a function without arguments
+// returning bool, it uses event the same way Run does:
+//
+// Filter(github.Branches(src), func() bool {
+// return event.Branch != "gh-pages"
+// })
+//
+func Filter(channel Channel, predicate Expression) (Channel, error) {
+	lambda, err := eventFunction(predicate)
+	if err != nil {
+		return nil, trace.BadParameter("Filter expects func() bool: %v", err)
+	}
+	if reflect.TypeOf(lambda.ResultType()) != reflect.TypeOf(true) {
+		return nil, trace.BadParameter("Filter expects func() bool, got function returning %v", reflect.TypeOf(lambda.ResultType()))
+	}
+	return &FilterChannel{
+		in:        channel,
+		predicate: predicate,
+		lambda:    lambda,
+		eventsC:   make(chan Event, DefaultChannelBufferSize),
+	}, nil
+}
+
+// FilterChannel drops the events not matching the predicate
+type FilterChannel struct {
+	in        Channel
+	predicate Expression
+	lambda    *LambdaFunction
+	eventsC   chan Event
+}
+
+// String returns user friendly representation of the channel
+func (f *FilterChannel) String() string {
+	return fmt.Sprintf("Filter(%v)", f.in)
+}
+
+// Watches returns resources watched by the filtered channel
+func (f *FilterChannel) Watches() []Resource {
+	return watches(f.in)
+}
+
+// Start starts the filtered channel and filters its events
+func (f *FilterChannel) Start(pctx context.Context) error {
+	startChannel(pctx, f.in)
+	go forwardEvents(pctx, f.in, f.eventsC, func(event Event) (Event, error) {
+		ok, err := EvalBool(eventContext(pctx, event), &LambdaFunctionCall{Expression: f.lambda})
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if !ok {
+			Log(pctx).Debugf("%v has dropped %v.", f, event)
+			return nil, nil
+		}
+		return event, nil
+	})
+	return nil
+}
+
+// Events returns the filtered events
+func (f *FilterChannel) Events() <-chan Event {
+	return f.eventsC
+}
+
+// Done returns a channel closed when the filtered channel is done
+func (f *FilterChannel) Done() <-chan struct{} {
+	return f.in.Done()
+}
+
+// UnmarshalEvent restores the event of the filtered channel
+func (f *FilterChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	return unmarshalEvent(ctx, f.in, data)
+}
+
+// MarshalCode marshals channel to code
+func (f *FilterChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Filter, f.in, f.predicate).MarshalCode(ctx)
+}
+
+// NewMapEvents creates channels mapping events, the function
+// is parsed in a new scope with the event of the mapped channel,
+// and the result of the function becomes the event of the process
+type NewMapEvents struct {
+}
+
+// NewInstance returns a function creating map channels
+func (n *NewMapEvents) NewInstance(group Group) (Group, interface{}) {
+	return WithArgumentScope(group), func(channel Channel, fn Expression) (Channel, error) {
+		out, err := MapEvents(channel, fn)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		m := out.(*MapChannel)
+		if err := group.AddDefinition(KeyEvent, m.lambda.Result); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return m, nil
+	}
+}
+
+// MapEvents returns a channel that converts every event with the function,
+// the function without arguments uses event the same way Run does,
+// its result is available in the process as event, in scripts
+// the function is called Map:
+//
+// Map(github.PullRequests(src), func() string {
+// return event.Commit
+// })
+//
+func MapEvents(channel Channel, fn Expression) (Channel, error) {
+	lambda, err := eventFunction(fn)
+	if err != nil {
+		return nil, trace.BadParameter("Map expects a function returning a value: %v", err)
+	}
+	if lambda.Result == nil {
+		return nil, trace.BadParameter("Map expects a function returning a value, e.g. func() string { return event.Commit }")
+	}
+	return &MapChannel{
+		in:      channel,
+		fn:      fn,
+		lambda:  lambda,
+		eventsC: make(chan Event, DefaultChannelBufferSize),
+	}, nil
+}
+
+// MapChannel converts events of the channel
+type MapChannel struct {
+	in      Channel
+	fn      Expression
+	lambda  *LambdaFunction
+	eventsC chan Event
+}
+
+// String returns user friendly representation of the channel
+func (m *MapChannel) String() string {
+	return fmt.Sprintf("Map(%v)", m.in)
+}
+
+// Watches returns resources watched by the mapped channel
+func (m *MapChannel) Watches() []Resource {
+	return watches(m.in)
+}
+
+// Start starts the mapped channel and converts its events
+func (m *MapChannel) Start(pctx context.Context) error {
+	startChannel(pctx, m.in)
+	go forwardEvents(pctx, m.in, m.eventsC, func(event Event) (Event, error) {
+		return m.convert(pctx, event)
+	})
+	return nil
+}
+
+// convert converts the event with the function
+func (m *MapChannel) convert(ctx context.Context, event Event) (Event, error) {
+	out, err := (&LambdaFunctionCall{Expression: m.lambda}).Eval(eventContext(ctx, event))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &MappedEvent{Event: event, Value: out}, nil
+}
+
+// Events returns the converted events
+func (m *MapChannel) Events() <-chan Event {
+	return m.eventsC
+}
+
+// Done returns a channel closed when the mapped channel is done
+func (m *MapChannel) Done() <-chan struct{} {
+	return m.in.Done()
+}
+
+// UnmarshalEvent restores the event of the mapped channel and converts it again
+func (m *MapChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	event, err := unmarshalEvent(ctx, m.in, data)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return m.convert(ctx, event)
+}
+
+// MarshalCode marshals channel to code
+func (m *MapChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	call := &FnCall{FnName: "Map", Args: []interface{}{m.in, m.fn}}
+	return call.MarshalCode(ctx)
+}
+
+// MappedEvent is the event converted by Map
+type MappedEvent struct {
+	// Event is the original event
+	Event
+	// Value is the result of the conversion
+	Value interface{}
+}
+
+// String returns user friendly representation of the event
+func (m *MappedEvent) String() string {
+	return fmt.Sprintf("%v", m.Event)
+}
+
+// AddMetadata adds metadata of the original event,
+// the converted value is set as event
+func (m *MappedEvent) AddMetadata(ctx ExecutionContext) {
+	m.Event.AddMetadata(ctx)
+	ctx.SetValue(ContextKey(KeyEvent), m.Value)
+}
+
+// MarshalEvent serializes the original event,
+// the event is converted again when restored
+func (m *MappedEvent) MarshalEvent() ([]byte, error) {
+	event, ok := m.Event.(SerializableEvent)
+	if !ok {
+		return nil, trace.NotImplemented("%v can not be serialized", m.Event)
+	}
+	return event.MarshalEvent()
+}
+
+// eventFunction returns the lambda function
+// without arguments evaluated for every event
+func eventFunction(fn Expression) (*LambdaFunction, error) {
+	lambda, err := ExpectLambdaFunction(fn)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(lambda.Params) != 0 {
+		return nil, trace.BadParameter("the function should not have arguments, use event to access the event")
+	}
+	return lambda, nil
+}
+
+// eventContext returns a context with the event set
+// the same way it is set for the process run
+func eventContext(pctx context.Context, event Event) ExecutionContext {
+	parent, ok := pctx.(ExecutionContext)
+	if !ok {
+		parent = &WrapContext{Context: pctx}
+	}
+	ctx := NewContext(ContextConfig{Parent: parent, Event: event})
+	event.AddMetadata(ctx)
+	return ctx
+}
+
+// forwardEvents converts events of the channel and sends them
+// to the events channel, events converted to nil are dropped,
+// events failed to convert are logged and dropped
+func forwardEvents(ctx context.Context, in Channel, eventsC chan Event, convert func(Event) (Event, error)) {
+	log := Log(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-in.Done():
+			return
+		case event := <-in.Events():
+			out, err := convert(event)
+			if err != nil {
+				log.WithError(err).Warningf("Failed to process %v, dropping it.", event)
+				continue
+			}
+			if out == nil {
+				continue
+			}
+			if !sendEvent(ctx, eventsC, out) {
+				return
+			}
+		}
+	}
+}
+
+// sendEvent sends the event to the events channel,
+// returns false if the context is closed
+func sendEvent(ctx context.Context, eventsC chan Event, event Event) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case eventsC <- event:
+		return true
+	}
+}
+
+// startChannel starts the wrapped channel
+func startChannel(ctx context.Context, in Channel) {
+	go func() {
+		if err := in.Start(ctx); err != nil {
+			Log(ctx).WithError(err).Errorf("Failed to start %v.", in)
+		}
+	}()
+}
+
+// watches returns resources watched by the wrapped channel
+func watches(in Channel) []Resource {
+	if w, ok := in.(Watcher); ok {
+		return w.Watches()
+	}
+	return nil
+}
+
+// unmarshalEvent restores the event of the wrapped channel
+func unmarshalEvent(ctx context.Context, in Channel, data []byte) (Event, error) {
+	unmarshaler, ok := in.(EventUnmarshaler)
+	if !ok {
+		return nil, trace.NotImplemented("%v can not restore events", in)
+	}
+	return unmarshaler.UnmarshalEvent(ctx, data)
+}
+
+// defineChannelEvent defines event of the wrapped channel
+// parsed in the argument scope in the group, so the events
+// passed by the channel are used the same way
+func defineChannelEvent(scope *ArgumentScope, group Group) error {
+	event, err := scope.LocalDefinition(KeyEvent)
+	if err != nil {
+		// the wrapped channel does not define event
+		return nil
+	}
+	return group.AddDefinition(KeyEvent, event)
+}
@@ -0,0 +1,98 @@
+package force
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// Float is a constant float var
+type Float float64
+
+// MarshalCode marshals the variable to code representation
+func (f Float) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return MarshalCode(ctx, float64(f))
+}
+
+// Convert converts floats to float constant
+func (f Float) Convert(in interface{}) (interface{}, error) {
+	switch v := in.(type) {
+	case float64:
+		return Float(v), nil
+	case float32:
+		return Float(v), nil
+	case Float:
+		return v, nil
+	default:
+		return nil, trace.BadParameter("can not assign type %T to float variable", in)
+	}
+}
+
+// Eval returns float value
+func (f Float) Eval(ctx ExecutionContext) (interface{}, error) {
+	return float64(f), nil
+}
+
+// Type returns float type
+func (f Float) Type() interface{} {
+	return 0.0
+}
+
+// marshalFloat returns code representation of the float,
+// that is always parsed back as float
+func marshalFloat(f float64) []byte {
+	out := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(out, ".eEIN") {
+		out += ".0"
+	}
+	return []byte(out)
+}
+
+// FloatVar is a float variable
+type FloatVar struct {
+	Expression
+}
+
+// Type returns float type
+func (f FloatVar) Type() interface{} {
+	return 0.0
+}
+
+// Eval evaluates float variable
+func (f FloatVar) Eval(ctx ExecutionContext) (interface{}, error) {
+	if f.Expression != nil {
+		return f.Expression.Eval(ctx)
+	}
+	return 0.0, nil
+}
+
+// Convert converts floats and float expressions to float variable
+func (f FloatVar) Convert(in interface{}) (interface{}, error) {
+	switch v := in.(type) {
+	case float64:
+		return FloatVar{Expression: Float(v)}, nil
+	case float32:
+		return FloatVar{Expression: Float(v)}, nil
+	case Float:
+		return FloatVar{Expression: v}, nil
+	case FloatVar:
+		return v, nil
+	case Expression:
+		if reflect.TypeOf(v.Type()).AssignableTo(reflect.TypeOf(0.0)) {
+			return FloatVar{Expression: v}, nil
+		}
+		return nil, trace.BadParameter("can not assign type %v to float variable", v.Type())
+	default:
+		return nil, trace.BadParameter("can not assign type %T to float variable", in)
+	}
+}
+
+// ExpectFloat returns nil if expression is float, error otherwise
+func ExpectFloat(expr Expression) error {
+	if reflect.TypeOf(expr.Type()).AssignableTo(reflect.TypeOf(0.0)) {
+		return nil
+	}
+	return trace.BadParameter("%v does not evaluate to float", expr.Type())
+}
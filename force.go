@@ -3,8 +3,11 @@ package force
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"sync/atomic"
 	"time"
 
@@ -138,7 +141,48 @@ type Spec struct {
 	// Group if set, will assign the process to a specific group,
 	// otherwise, will be set to the default runner
 	Group Group `code:"-"`
-}
+	// MaxConcurrent limits the number of events
+	// processed at the same time, unlimited if 0
+	MaxConcurrent Int
+	// QueueSize is the number of events waiting
+	// to be processed, 32 by default
+	QueueSize Int
+	// Overflow sets how new events are handled when
+	// the queue is full, one of Drop (default), Block or ReplaceOldest
+	Overflow String
+	// ConcurrencyKey is evaluated for every event, runs
+	// with the same key belong to the same concurrency group
+	ConcurrencyKey Expression
+	// CancelInProgress cancels the run in progress when a new
+	// event with the same concurrency key is received
+	CancelInProgress Bool
+	// Timeout is a duration string or a duration expression,
+	// every attempt of the run is cancelled after the timeout
+	Timeout Expression
+	// Retry is a RetryPolicy, failed runs are retried
+	// according to the policy, runs are not retried by default
+	Retry interface{}
+	// OnFailure is evaluated when the run has failed, the error
+	// and the execution ID are available as Error() and ID()
+	OnFailure Action
+	// OnSuccess is evaluated when the run has succeeded
+	OnSuccess Action
+}
+
+const (
+	// OverflowDrop drops new events when the queue is full
+	OverflowDrop = "Drop"
+	// OverflowBlock waits until there is space in the queue
+	OverflowBlock = "Block"
+	// OverflowReplaceOldest drops the oldest event in the queue
+	// to make space for the new event
+	OverflowReplaceOldest = "ReplaceOldest"
+	// DefaultQueueSize is the default size of the process queue
+	DefaultQueueSize = 32
+	// DefaultChannelBufferSize is the number of events buffered
+	// by the channels before they are received by the processes
+	DefaultChannelBufferSize = 1024
+)
 
 // processNumber is a helper number to generate
 // meaningful process numbers in case if user did not specify one
@@ -160,6 +204,76 @@ func (s *Spec) CheckAndSetDefaults() error {
 	if s.Run == nil {
 		return trace.BadParameter("the Process needs Spec{Run:} parameter")
 	}
+	if s.MaxConcurrent < 0 {
+		return trace.BadParameter("Spec{MaxConcurrent:} can not be negative")
+	}
+	if s.QueueSize < 0 {
+		return trace.BadParameter("Spec{QueueSize:} can not be negative")
+	}
+	if s.QueueSize == 0 {
+		s.QueueSize = DefaultQueueSize
+	}
+	if s.ConcurrencyKey != nil {
+		if err := ExpectString(s.ConcurrencyKey); err != nil {
+			return trace.BadParameter("Spec{ConcurrencyKey:} should be a string: %v", err)
+		}
+	}
+	if s.CancelInProgress && s.ConcurrencyKey == nil {
+		return trace.BadParameter("Spec{CancelInProgress:} needs Spec{ConcurrencyKey:} parameter")
+	}
+	if s.Timeout != nil {
+		if _, err := (DurationVar{}).Convert(s.Timeout); err != nil {
+			return trace.BadParameter("Spec{Timeout:} should be a duration: %v", err)
+		}
+	}
+	switch s.Overflow {
+	case "":
+		s.Overflow = OverflowDrop
+	case OverflowDrop, OverflowBlock, OverflowReplaceOldest:
+	default:
+		return trace.BadParameter(
+			"unsupported Spec{Overflow: %q}, supported values are %q, %q and %q",
+			s.Overflow, OverflowDrop, OverflowBlock, OverflowReplaceOldest)
+	}
+	return nil
+}
+
+// DefaultServerListen is a default address of the force server,
+// the pages are served without authentication, so by default
+// the server is reachable only from the local host
+const DefaultServerListen = "127.0.0.1:8080"
+
+// ServerConfig sets up the HTTP server with the pages
+// of the processes and their runs
+type ServerConfig struct {
+	// Listen is an address to listen on, "127.0.0.1:8080" by default
+	Listen string
+	// URL is a public URL of the server used in the links to the run pages,
+	// by default it is built from the listen address, or from the host name
+	// if the server listens on all interfaces
+	URL string
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (s *ServerConfig) CheckAndSetDefaults() error {
+	if s.Listen == "" {
+		s.Listen = DefaultServerListen
+	}
+	host, port, err := net.SplitHostPort(s.Listen)
+	if err != nil {
+		return trace.BadParameter("ServerConfig Listen %q should be host:port: %v", s.Listen, err)
+	}
+	if s.URL == "" {
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host, _ = os.Hostname()
+		}
+		s.URL = fmt.Sprintf("http://%v", net.JoinHostPort(host, port))
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return trace.BadParameter("ServerConfig URL %q should be an absolute URL", s.URL)
+	}
+	s.URL = strings.TrimSuffix(s.URL, "/")
 	return nil
 }
 
@@ -171,6 +285,21 @@ type Event interface {
 	Created() time.Time
 }
 
+// SerializableEvent is an event that could be recorded
+// in the event journal and delivered again after restart
+type SerializableEvent interface {
+	Event
+	// MarshalEvent serializes the event
+	MarshalEvent() ([]byte, error)
+}
+
+// EventUnmarshaler is implemented by channels that restore
+// events serialized with MarshalEvent
+type EventUnmarshaler interface {
+	// UnmarshalEvent restores the event serialized with MarshalEvent
+	UnmarshalEvent(ctx context.Context, data []byte) (Event, error)
+}
+
 // SetError is a helper function that adds an error
 // to the context
 func SetError(ctx ExecutionContext, err error) {
@@ -180,6 +309,19 @@ func SetError(ctx ExecutionContext, err error) {
 	ctx.SetValue(KeyError, err)
 }
 
+// Supersede marks the execution as superseded by a newer run,
+// the caller is expected to cancel the execution context
+func Supersede(ctx ExecutionContext) {
+	ctx.SetValue(KeySuperseded, true)
+}
+
+// IsSuperseded returns true if the execution has been cancelled
+// because of a newer run with the same concurrency key
+func IsSuperseded(ctx ExecutionContext) bool {
+	superseded, _ := ctx.Value(KeySuperseded).(bool)
+	return superseded
+}
+
 // Error is a helper function that finds and returns
 // an error
 func Error(ctx ExecutionContext) error {
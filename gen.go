@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"time"
 	"unicode"
 
 	"github.com/gravitational/trace"
@@ -54,6 +55,14 @@ func ConvertTypeToAST(in reflect.Type) (reflect.Type, error) {
 
 // types are used to detect self referrential types and avoid loops
 func convertTypeToAST(in reflect.Type, types []reflect.Type) (reflect.Type, error) {
+	// durations and times are checked before kinds,
+	// because their kinds are int64 and struct
+	switch in {
+	case reflect.TypeOf(time.Duration(0)):
+		return reflect.TypeOf(DurationVar{}), nil
+	case reflect.TypeOf(time.Time{}):
+		return reflect.TypeOf(TimeVar{}), nil
+	}
 	switch in.Kind() {
 	case reflect.Bool:
 		return reflect.TypeOf(BoolVar{}), nil
@@ -63,6 +72,8 @@ func convertTypeToAST(in reflect.Type, types []reflect.Type) (reflect.Type, erro
 		return reflect.TypeOf(IntVar{}), nil
 	case reflect.Int32:
 		return reflect.TypeOf(IntVar{}), nil
+	case reflect.Float32, reflect.Float64:
+		return reflect.TypeOf(FloatVar{}), nil
 	case reflect.String:
 		return reflect.TypeOf(StringVar{}), nil
 	case reflect.Ptr:
@@ -80,6 +91,9 @@ func convertTypeToAST(in reflect.Type, types []reflect.Type) (reflect.Type, erro
 		}
 		return reflect.PtrTo(out), nil
 	case reflect.Map:
+		if in.Key().Kind() == reflect.String && in.Elem().Kind() == reflect.String {
+			return reflect.TypeOf(StringMapVar{}), nil
+		}
 		out, err := convertTypeToAST(in.Elem(), append([]reflect.Type{in.Elem()}, types...))
 		if err != nil {
 			return nil, trace.Wrap(err)
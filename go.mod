@@ -22,7 +22,7 @@ require (
 	github.com/google/go-querystring v1.0.0 // indirect
 	github.com/gravitational/trace v0.0.0-20190612100216-931bb2abd388
 	github.com/hashicorp/golang-lru v0.5.1 // indirect
-	github.com/jonboulle/clockwork v0.1.0 // indirect
+	github.com/jonboulle/clockwork v0.1.0
 	github.com/konsorten/go-windows-terminal-sequences v1.0.2 // indirect
 	github.com/mitchellh/hashstructure v1.0.0 // indirect
 	github.com/moby/buildkit v0.6.0
@@ -31,7 +31,7 @@ require (
 	github.com/opencontainers/runc v1.0.1-0.20190307181833-2b18fe1d885e
 	github.com/opencontainers/runtime-spec v1.0.1 // indirect
 	github.com/opentracing-contrib/go-stdlib v0.0.0-20180702182724-07a764486eb1 // indirect
-	github.com/opentracing/opentracing-go v1.0.2 // indirect
+	github.com/opentracing/opentracing-go v1.0.2
 	github.com/shurcooL/githubv4 v0.0.0-20190625031733-ee671ab25ff0
 	github.com/shurcooL/graphql v0.0.0-20181231061246-d48a9a75455f // indirect
 	github.com/sirupsen/logrus v1.4.1
@@ -1,6 +1,9 @@
 package force
 
 import (
+	"bytes"
+	"io"
+
 	"github.com/gravitational/trace"
 )
 
@@ -33,6 +36,31 @@ func If(condition Expression, action Action, elseActions ...Action) (ScopeAction
 	}, nil
 }
 
+// IfStmt returns conditional action lowered from if/else statement,
+// else action is either a block, another if/else statement or nil.
+// Unlike If, branches of the statement do not have to
+// evaluate to the same type
+func IfStmt(condition Expression, action *BlockAction, elseAction Action) (ScopeAction, error) {
+	if err := ExpectBool(condition); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	switch e := elseAction.(type) {
+	case nil, *BlockAction:
+	case *IfAction:
+		if !e.statement {
+			return nil, trace.BadParameter("else should be followed by if statement or block")
+		}
+	default:
+		return nil, trace.BadParameter("else should be followed by if statement or block, got %T", elseAction)
+	}
+	return &IfAction{
+		condition:  condition,
+		action:     action,
+		elseAction: elseAction,
+		statement:  true,
+	}, nil
+}
+
 // NewInstance returns a new instance of a function with a new lexical scope
 func (n *NewIf) NewInstance(group Group) (Group, interface{}) {
 	return WithLexicalScope(group), If
@@ -44,6 +72,18 @@ type IfAction struct {
 	condition  Expression
 	action     Action
 	elseAction Action
+	// statement is set when the action is lowered
+	// from if/else statement
+	statement bool
+}
+
+// Children returns condition and both branches
+func (s *IfAction) Children() []interface{} {
+	out := []interface{}{s.condition, s.action}
+	if s.elseAction != nil {
+		out = append(out, s.elseAction)
+	}
+	return out
 }
 
 func (p *IfAction) Type() interface{} {
@@ -52,6 +92,9 @@ func (p *IfAction) Type() interface{} {
 
 // MarshalCode marshals action into code representation
 func (p *IfAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	if p.statement {
+		return p.marshalStatement(ctx)
+	}
 	call := &FnCall{
 		Fn:   If,
 		Args: []interface{}{p.condition, p.action},
@@ -62,8 +105,36 @@ func (p *IfAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 	return call.MarshalCode(ctx)
 }
 
+// marshalStatement marshals action into if/else statement
+func (p *IfAction) marshalStatement(ctx ExecutionContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, "if ")
+	for _, node := range []interface{}{p.condition, p.action} {
+		data, err := MarshalCode(ctx, node)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		buf.Write(data)
+		io.WriteString(buf, " ")
+	}
+	if p.elseAction != nil {
+		io.WriteString(buf, "else ")
+		data, err := p.elseAction.MarshalCode(ctx)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		buf.Write(data)
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
 // EvalWithScope runs actions in sequence using the passed scope
 func (s *IfAction) EvalWithScope(ctx ExecutionContext) (interface{}, error) {
+	return EvalWithSpan(ctx, "If", s.eval)
+}
+
+// eval evaluates the condition and the action
+func (s *IfAction) eval(ctx ExecutionContext) (interface{}, error) {
 	result, err := s.condition.Eval(ctx)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -0,0 +1,248 @@
+package force
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// maxPendingJoins limits the number of keys waiting for the runs
+// of all joined processes, the oldest keys are dropped first
+const maxPendingJoins = 1024
+
+// NewJoin creates channels joining completion events,
+// the channel defines the join event
+type NewJoin struct {
+}
+
+// NewInstance returns a function creating join channels
+func (n *NewJoin) NewInstance(group Group) (Group, interface{}) {
+	group.AddDefinition(KeyEvent, JoinEvent{})
+	return group, Join
+}
+
+// Join returns a channel that waits until every channel sends
+// a completion event for the same commit, or for the same triggering
+// event if the event has no commit, and sends the join event,
+// event.Succeeded is true if all joined runs have succeeded:
+//
+// Process(Spec{
+// Watch: Join(Completed("unit"), Completed("integration")),
+// Run: func() {
+// If(event.Succeeded, Command("make deploy"))
+// },
+// })
+//
+func Join(channels ...Channel) (Channel, error) {
+	if len(channels) < 2 {
+		return nil, trace.BadParameter(`Join needs at least two channels, for example Join(Completed("unit"), Completed("integration"))`)
+	}
+	return &JoinChannel{
+		in:      channels,
+		eventsC: make(chan Event, DefaultChannelBufferSize),
+	}, nil
+}
+
+// JoinChannel joins completion events of the channels
+type JoinChannel struct {
+	in      []Channel
+	eventsC chan Event
+}
+
+// String returns user friendly representation of the channel
+func (j *JoinChannel) String() string {
+	return fmt.Sprintf("Join(%v)", j.in)
+}
+
+// Watches returns resources watched by the joined channels
+func (j *JoinChannel) Watches() []Resource {
+	var out []Resource
+	for _, in := range j.in {
+		out = append(out, watches(in)...)
+	}
+	return out
+}
+
+// joinInput is an event received from the joined channel
+type joinInput struct {
+	index int
+	event Event
+}
+
+// Start starts the joined channels and joins their events
+func (j *JoinChannel) Start(pctx context.Context) error {
+	inputsC := make(chan joinInput)
+	for i, in := range j.in {
+		startChannel(pctx, in)
+		go func(index int, in Channel) {
+			for {
+				select {
+				case <-pctx.Done():
+					return
+				case <-in.Done():
+					return
+				case event := <-in.Events():
+					select {
+					case <-pctx.Done():
+						return
+					case inputsC <- joinInput{index: index, event: event}:
+					}
+				}
+			}
+		}(i, in)
+	}
+	go func() {
+		log := Log(pctx)
+		pending := make(map[string][]*CompletedEvent)
+		// keys are ordered from the oldest to the newest
+		var keys []string
+		for {
+			var input joinInput
+			select {
+			case <-pctx.Done():
+				return
+			case input = <-inputsC:
+			}
+			completed, ok := input.event.(*CompletedEvent)
+			if !ok {
+				log.Warningf("%v expects completion events, dropping %v.", j, input.event)
+				continue
+			}
+			key := completed.JoinKey()
+			runs, ok := pending[key]
+			if !ok {
+				if len(keys) == maxPendingJoins {
+					log.Warningf("%v has too many pending runs, dropping runs of %v.", j, keys[0])
+					delete(pending, keys[0])
+					keys = keys[1:]
+				}
+				runs = make([]*CompletedEvent, len(j.in))
+				pending[key] = runs
+				keys = append(keys, key)
+			}
+			// the latest run of the process is joined
+			runs[input.index] = completed
+			if !joined(runs) {
+				continue
+			}
+			delete(pending, key)
+			for i := range keys {
+				if keys[i] == key {
+					keys = append(keys[:i], keys[i+1:]...)
+					break
+				}
+			}
+			if !sendEvent(pctx, j.eventsC, newJoinEvent(key, runs)) {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// joined returns true if all runs have completed
+func joined(runs []*CompletedEvent) bool {
+	for _, run := range runs {
+		if run == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Events returns join events
+func (j *JoinChannel) Events() <-chan Event {
+	return j.eventsC
+}
+
+// Done returns nil, join channel never completes
+func (j *JoinChannel) Done() <-chan struct{} {
+	return nil
+}
+
+// UnmarshalEvent restores join event
+func (j *JoinChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	var e joinEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	runs := make([]*CompletedEvent, 0, len(e.Completed))
+	for _, c := range e.Completed {
+		runs = append(runs, c.event())
+	}
+	event := newJoinEvent(e.Key, runs)
+	event.created = e.Created
+	return event, nil
+}
+
+// MarshalCode marshals channel to code
+func (j *JoinChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	args := make([]interface{}, 0, len(j.in))
+	for _, in := range j.in {
+		args = append(args, in)
+	}
+	return NewFnCall(Join, args...).MarshalCode(ctx)
+}
+
+// newJoinEvent returns join event of the completed runs
+func newJoinEvent(key string, runs []*CompletedEvent) *JoinEvent {
+	e := &JoinEvent{
+		Key:       String(key),
+		Succeeded: true,
+		created:   time.Now().UTC(),
+	}
+	for _, run := range runs {
+		e.Completed = append(e.Completed, *run)
+		if !run.Succeeded() {
+			e.Succeeded = false
+		}
+	}
+	return e
+}
+
+// JoinEvent is sent when all joined processes have completed
+type JoinEvent struct {
+	// Key is the commit or the event that has triggered the runs
+	Key String
+	// Succeeded is true if all runs have succeeded
+	Succeeded Bool
+	// Completed are the completion events of the runs,
+	// in the order of the joined channels
+	Completed []CompletedEvent
+	created   time.Time
+}
+
+// Created returns the time the runs were joined
+func (j *JoinEvent) Created() time.Time {
+	return j.created
+}
+
+// String returns user friendly representation of the event
+func (j *JoinEvent) String() string {
+	return fmt.Sprintf("Join(key=%v, succeeded=%v)", j.Key, j.Succeeded)
+}
+
+// MarshalEvent serializes join event
+func (j *JoinEvent) MarshalEvent() ([]byte, error) {
+	e := joinEventJSON{Key: string(j.Key), Created: j.created}
+	for i := range j.Completed {
+		e.Completed = append(e.Completed, newCompletedEventJSON(&j.Completed[i]))
+	}
+	return json.Marshal(e)
+}
+
+// joinEventJSON is a serialized join event
+type joinEventJSON struct {
+	Key       string               `json:"key"`
+	Completed []completedEventJSON `json:"completed"`
+	Created   time.Time            `json:"created"`
+}
+
+// AddMetadata sets the event in the context,
+// so the result is available as event.Succeeded
+func (j *JoinEvent) AddMetadata(ctx ExecutionContext) {
+	ctx.SetValue(ContextKey(KeyEvent), *j)
+}
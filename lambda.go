@@ -18,6 +18,14 @@ type LambdaFunction struct {
 	Scope      Group
 	Statements []Action
 	Params     []LambdaParam
+	// Result is a prototype of the return value,
+	// nil if function has no return values
+	Result interface{}
+}
+
+// Children returns statements of the function
+func (f *LambdaFunction) Children() []interface{} {
+	return actionNodes(f.Statements)
 }
 
 func (f *LambdaFunction) NewInstance(group Group) (Group, interface{}) {
@@ -28,6 +36,30 @@ func (f *LambdaFunction) Type() interface{} {
 	return f
 }
 
+// ResultType returns a type the function evaluates to,
+// declared return value type, or the type of the last statement
+func (f *LambdaFunction) ResultType() interface{} {
+	if f.Result != nil {
+		return ExpressionType(f.Result)
+	}
+	if len(f.Statements) == 0 {
+		return nil
+	}
+	return f.Statements[len(f.Statements)-1].Type()
+}
+
+// CheckReturn checks that function with return value
+// ends with the return statement
+func (f *LambdaFunction) CheckReturn() error {
+	if f.Result == nil {
+		return nil
+	}
+	if len(f.Statements) == 0 || !terminates(f.Statements[len(f.Statements)-1]) {
+		return trace.BadParameter("missing return at the end of function")
+	}
+	return nil
+}
+
 // NewCall creates a new call
 func (f *LambdaFunction) NewCall() (*LambdaFunctionCall, error) {
 	// lambda function definition can be run as action,
@@ -67,9 +99,21 @@ func (f *LambdaFunction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 		if i != 0 {
 			io.WriteString(buf, ", ")
 		}
-		fmt.Fprintf(buf, "%v %v", param.Name, reflect.TypeOf(param).Name())
+		paramType, err := typeName(param.Prototype)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		fmt.Fprintf(buf, "%v %v", param.Name, paramType)
+	}
+	io.WriteString(buf, ")")
+	if f.Result != nil {
+		resultType, err := typeName(f.Result)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		io.WriteString(buf, " "+resultType)
 	}
-	io.WriteString(buf, "){")
+	io.WriteString(buf, "{")
 	for _, statement := range f.Statements {
 		data, err := statement.MarshalCode(ctx)
 		if err != nil {
@@ -88,6 +132,26 @@ func (f *LambdaFunction) Eval(ctx ExecutionContext) (interface{}, error) {
 	return f.EvalWithScope(WithRuntimeScope(ctx))
 }
 
+// typeName returns code representation of the prototype type
+func typeName(proto interface{}) (string, error) {
+	t := reflect.TypeOf(ExpressionType(proto))
+	switch t.Kind() {
+	case reflect.String, reflect.Int, reflect.Bool:
+		return t.String(), nil
+	case reflect.Slice:
+		if _, err := typeName(reflect.Zero(t.Elem()).Interface()); err == nil {
+			return t.String(), nil
+		}
+	case reflect.Map:
+		_, keyErr := typeName(reflect.Zero(t.Key()).Interface())
+		_, elemErr := typeName(reflect.Zero(t.Elem()).Interface())
+		if keyErr == nil && elemErr == nil {
+			return t.String(), nil
+		}
+	}
+	return "", trace.NotImplemented("can not marshal type %v", t)
+}
+
 // TypeChecker is used to test two types for equivalence
 type TypeChecker interface {
 	// ExpectEqualTypes returns nil if the types are identical
@@ -112,22 +176,31 @@ func (f *LambdaFunction) ExpectEqualTypes(i interface{}) error {
 			return trace.BadParameter("mismatch type for function parameters %v and %v: %v", paramA.Name, paramB.Name, err)
 		}
 	}
-	if len(f.Statements) != 0 && len(b.Statements) == 0 {
+	aResult, bResult := f.ResultType(), b.ResultType()
+	if aResult != nil && bResult == nil {
 		return nil
 	}
-	if len(f.Statements) == 0 || len(b.Statements) == 0 {
+	if aResult == nil || bResult == nil {
 		return trace.BadParameter("functions do not evaluate to the same type, one is empty")
 	}
-	return ExpectEqualTypes(f.Statements[len(f.Statements)-1], b.Statements[len(b.Statements)-1])
+	return ExpectEqualTypes(aResult, bResult)
 }
 
 // LambdaFunctionCall represents a call of a lambda function with arguments
 type LambdaFunctionCall struct {
+	// Name is a name of the called function,
+	// empty for anonymous functions
+	Name       string
 	Expression Expression
 	// Args defines arguments the lambda function has been called with
 	Arguments []interface{}
 }
 
+// Children returns called function and the arguments
+func (f *LambdaFunctionCall) Children() []interface{} {
+	return append([]interface{}{f.Expression}, f.Arguments...)
+}
+
 // CheckCall checks call type variables and parameters
 func (f *LambdaFunctionCall) CheckCall() error {
 	lambda, err := f.LambdaType()
@@ -179,13 +252,14 @@ func (f *LambdaFunctionCall) LambdaType() (*LambdaFunction, error) {
 	return ExpectLambdaFunction(f.Expression)
 }
 
-// LambdaFunctionCall evaluates to it's last statement
+// LambdaFunctionCall evaluates to it's return value
+// or to it's last statement
 func (f *LambdaFunctionCall) Type() interface{} {
 	lambda, err := f.LambdaType()
 	if err != nil {
 		panic(err)
 	}
-	return lambda.Statements[len(lambda.Statements)-1].Type()
+	return lambda.ResultType()
 }
 
 // EvalWithScope runs actions in sequence using the passed scope and evalates
@@ -199,7 +273,16 @@ func (f *LambdaFunctionCall) EvalWithScope(scope ExecutionContext) (interface{},
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	return seq.EvalWithScope(scope)
+	out, err := seq.EvalWithScope(scope)
+	if ret, ok := isReturn(err); ok {
+		// return statement without value evaluates
+		// to the zero value of the function type
+		if ret.value == nil {
+			return reflect.Zero(reflect.TypeOf(f.Type())).Interface(), nil
+		}
+		return ret.value, nil
+	}
+	return out, err
 }
 
 // MarshalCode marshals code
@@ -228,5 +311,17 @@ func (f *LambdaFunctionCall) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 // Eval runs the action in the context of the worker,
 // could modify the context to add metadata, fields or error
 func (f *LambdaFunctionCall) Eval(ctx ExecutionContext) (interface{}, error) {
-	return f.EvalWithScope(WithRuntimeScope(ctx))
+	ctx, span := StartSpan(ctx, f.spanName())
+	out, err := f.EvalWithScope(WithRuntimeScope(ctx))
+	FinishSpan(span, err)
+	return out, err
+}
+
+// spanName returns the name of the called function
+// used in the trace, anonymous functions are named func()
+func (f *LambdaFunctionCall) spanName() string {
+	if f.Name == "" {
+		return "func()"
+	}
+	return f.Name + "()"
 }
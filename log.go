@@ -44,6 +44,16 @@ func Log(ctx context.Context) Logger {
 	return l
 }
 
+// RunURL returns a URL of the page of the current run served
+// by the force server, if the server is not set up,
+// returns a URL for viewing the logs of the run
+func RunURL(ctx ExecutionContext) string {
+	if url, ok := ctx.Value(KeyRunURL).(string); ok && url != "" {
+		return url
+	}
+	return Log(ctx).URL(ctx)
+}
+
 // Writer returns a writer that ouptuts everything to logger
 func Writer(logger Logger) io.WriteCloser {
 	reader, writer := io.Pipe()
@@ -0,0 +1,390 @@
+package force
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// ResourceFile is a local file or directory
+	ResourceFile = "file"
+	// ResourceGitHubBranch is a branch of the GitHub repository,
+	// named "org/repo:branch", watched branches are named
+	// "org/repo:pattern", where pattern is the branch regular expression
+	ResourceGitHubBranch = "github-branch"
+	// ResourceProcessCompletion is a completion event of the process
+	// named after the process, every process produces its own completion
+	ResourceProcessCompletion = "process-completion"
+)
+
+// GitHubBranch returns the branch resource of the repository,
+// branch is a branch name or a branch pattern of the watched branch
+func GitHubBranch(repo, branch string) Resource {
+	return Resource{Kind: ResourceGitHubBranch, Name: repo + ":" + branch}
+}
+
+// ProcessCompletion returns the completion resource of the process
+func ProcessCompletion(process string) Resource {
+	return Resource{Kind: ResourceProcessCompletion, Name: process}
+}
+
+// Resource is an external resource, for example a file,
+// watched by channels and modified by actions, resources are
+// used to detect event loops
+type Resource struct {
+	// Kind is a resource kind, for example "file"
+	Kind string
+	// Name is a resource name, for example a file path
+	Name string
+}
+
+// String returns user friendly representation of the resource
+func (r Resource) String() string {
+	return fmt.Sprintf("%v %v", r.Kind, r.Name)
+}
+
+// Matches returns true if the watched resource matches
+// the produced resource, files match if one path contains the other,
+// branches match if the repositories are the same and the branch
+// matches the pattern of the watched branch
+func (r Resource) Matches(produced Resource) bool {
+	if r.Kind != produced.Kind {
+		return false
+	}
+	switch r.Kind {
+	case ResourceFile:
+		return containsPath(r.Name, produced.Name) || containsPath(produced.Name, r.Name)
+	case ResourceGitHubBranch:
+		return matchesBranch(r.Name, produced.Name)
+	}
+	return r.Name == produced.Name
+}
+
+// matchesBranch returns true if the branch "org/repo:branch"
+// matches the watched branch "org/repo:pattern"
+func matchesBranch(watched, produced string) bool {
+	watchedRepo, pattern := splitBranch(watched)
+	repo, branch := splitBranch(produced)
+	if !strings.EqualFold(watchedRepo, repo) {
+		return false
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return pattern == branch
+	}
+	return re.MatchString(branch)
+}
+
+// splitBranch splits "org/repo:branch" into the repository and the branch
+func splitBranch(name string) (string, string) {
+	parts := strings.SplitN(name, ":", 2)
+	if len(parts) != 2 {
+		return name, ""
+	}
+	return parts[0], parts[1]
+}
+
+// containsPath returns true if the path is the same
+// as the directory, or is inside the directory,
+// relative paths are relative to the current directory
+func containsPath(dir, path string) bool {
+	dir, path = absPath(dir), absPath(path)
+	return dir == path || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// absPath returns absolute path, or the clean path
+// if the absolute path could not be found
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// Watcher is a channel that reports resources it watches
+type Watcher interface {
+	// Watches returns watched resources
+	Watches() []Resource
+}
+
+// Producer is an action that reports resources it modifies
+type Producer interface {
+	// Produces returns modified resources, resources that
+	// are only known during execution are omitted
+	Produces() []Resource
+}
+
+// EvalKnownInto evaluates the struct into out before the execution,
+// fields that are only known during the execution, for example
+// computed from the event or variables, are left empty
+func EvalKnownInto(in, out interface{}) error {
+	val := reflect.ValueOf(in)
+	if val.Kind() == reflect.Struct {
+		known := reflect.New(val.Type()).Elem()
+		for i := 0; i < val.NumField(); i++ {
+			if !known.Field(i).CanSet() {
+				continue
+			}
+			field := val.Field(i).Interface()
+			if field == nil {
+				continue
+			}
+			if _, err := Eval(EmptyContext(), field); err == nil {
+				known.Field(i).Set(val.Field(i))
+			}
+		}
+		in = known.Interface()
+	}
+	return EvalInto(EmptyContext(), in, out)
+}
+
+// Composite is an action with inner actions or expressions,
+// it is used to walk the tree of actions
+type Composite interface {
+	// Children returns inner actions and expressions
+	Children() []interface{}
+}
+
+// Walk calls fn for the node and all its children depth first,
+// children of the node are skipped if fn returns false
+func Walk(node interface{}, fn func(node interface{}) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+	if c, ok := node.(Composite); ok {
+		for _, child := range c.Children() {
+			Walk(child, fn)
+		}
+	}
+}
+
+// Loop is an event loop, every process of the loop modifies
+// a resource watched by the next process, and the last process
+// modifies a resource watched by the first one
+type Loop struct {
+	// Processes are processes of the loop
+	Processes []Process
+	// Resources are resources modified by the process
+	// and watched by the next process of the loop
+	Resources []Resource
+}
+
+// String returns user friendly representation of the loop
+func (l Loop) String() string {
+	steps := make([]string, len(l.Processes))
+	for i, p := range l.Processes {
+		next := l.Processes[(i+1)%len(l.Processes)]
+		steps[i] = fmt.Sprintf("process %q modifies %v watched by process %q", p.Name(), l.Resources[i], next.Name())
+	}
+	return strings.Join(steps, ", ")
+}
+
+// Processes returns all processes defined by the nodes,
+// including the processes nested in the actions of other processes
+func Processes(nodes ...interface{}) []Process {
+	var processes []Process
+	seen := make(map[Process]bool)
+	var collect func(node interface{}) bool
+	collect = func(node interface{}) bool {
+		p, ok := node.(Process)
+		if !ok {
+			return true
+		}
+		if !seen[p] {
+			seen[p] = true
+			processes = append(processes, p)
+			Walk(p.Action(), collect)
+		}
+		return false
+	}
+	for _, n := range nodes {
+		Walk(n, collect)
+	}
+	return processes
+}
+
+// DetectLoops finds all processes defined by the nodes
+// and returns event loops between them
+func DetectLoops(nodes ...interface{}) []Loop {
+	processes := Processes(nodes...)
+
+	// edges[i][j] is a resource produced by process i and watched by process j
+	edges := make([]map[int]Resource, len(processes))
+	for i, p := range processes {
+		edges[i] = make(map[int]Resource)
+		produced := append(produces(p), ProcessCompletion(p.Name()))
+		for j, w := range processes {
+			watcher, ok := w.Channel().(Watcher)
+			if !ok {
+				continue
+			}
+		match:
+			for _, watched := range watcher.Watches() {
+				for _, r := range produced {
+					if watched.Matches(r) {
+						edges[i][j] = r
+						break match
+					}
+				}
+			}
+		}
+	}
+	return findLoops(processes, edges)
+}
+
+// produces returns resources modified by the process actions,
+// actions of the nested processes are not included
+func produces(p Process) []Resource {
+	var out []Resource
+	Walk(p.Action(), func(node interface{}) bool {
+		if _, ok := node.(Process); ok {
+			return false
+		}
+		if producer, ok := node.(Producer); ok {
+			out = append(out, producer.Produces()...)
+		}
+		return true
+	})
+	return out
+}
+
+// findLoops returns the shortest loop starting
+// at every process that is a part of any loop
+func findLoops(processes []Process, edges []map[int]Resource) []Loop {
+	var loops []Loop
+	seen := make(map[string]bool)
+	for _, component := range components(len(processes), edges) {
+		inComponent := make(map[int]bool, len(component))
+		for _, i := range component {
+			inComponent[i] = true
+		}
+		for _, start := range component {
+			path := shortestLoop(start, len(processes), edges, inComponent)
+			if len(path) == 0 {
+				continue
+			}
+			// rotate the loop to start with the first process
+			// to report every loop only once
+			first := 0
+			for k := range path {
+				if path[k] < path[first] {
+					first = k
+				}
+			}
+			path = append(path[first:], path[:first]...)
+			key := fmt.Sprintf("%v", path)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			var loop Loop
+			for k, i := range path {
+				loop.Processes = append(loop.Processes, processes[i])
+				loop.Resources = append(loop.Resources, edges[i][path[(k+1)%len(path)]])
+			}
+			loops = append(loops, loop)
+		}
+	}
+	return loops
+}
+
+// shortestLoop returns the shortest path from start back to start
+// using breadth first search over the nodes of the component
+func shortestLoop(start, count int, edges []map[int]Resource, inComponent map[int]bool) []int {
+	prev := map[int]int{}
+	queue := []int{start}
+	for len(queue) != 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for j := 0; j < count; j++ {
+			if _, ok := edges[i][j]; !ok || !inComponent[j] {
+				continue
+			}
+			if j == start {
+				path := []int{i}
+				for k := i; k != start; {
+					k = prev[k]
+					path = append([]int{k}, path...)
+				}
+				return path
+			}
+			if _, visited := prev[j]; !visited {
+				prev[j] = i
+				queue = append(queue, j)
+			}
+		}
+	}
+	return nil
+}
+
+// components returns strongly connected components of the graph
+// using Tarjan's algorithm, components and their nodes are sorted
+func components(count int, edges []map[int]Resource) [][]int {
+	index := make([]int, count)
+	low := make([]int, count)
+	onStack := make([]bool, count)
+	for i := range index {
+		index[i] = -1
+	}
+	var stack []int
+	var out [][]int
+	next := 0
+	var connect func(i int)
+	connect = func(i int) {
+		index[i], low[i] = next, next
+		next++
+		stack = append(stack, i)
+		onStack[i] = true
+		for j := 0; j < count; j++ {
+			if _, ok := edges[i][j]; !ok {
+				continue
+			}
+			if index[j] == -1 {
+				connect(j)
+				if low[j] < low[i] {
+					low[i] = low[j]
+				}
+			} else if onStack[j] && index[j] < low[i] {
+				low[i] = index[j]
+			}
+		}
+		if low[i] != index[i] {
+			return
+		}
+		var component []int
+		for {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[j] = false
+			component = append(component, j)
+			if j == i {
+				break
+			}
+		}
+		sort.Ints(component)
+		out = append(out, component)
+	}
+	for i := 0; i < count; i++ {
+		if index[i] == -1 {
+			connect(i)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i][0] < out[j][0]
+	})
+	return out
+}
+
+// actionNodes converts actions to nodes returned by Children
+func actionNodes(actions []Action) []interface{} {
+	out := make([]interface{}, len(actions))
+	for i := range actions {
+		out[i] = actions[i]
+	}
+	return out
+}
@@ -0,0 +1,254 @@
+package force
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/gravitational/trace"
+)
+
+// Map returns a new map literal, key and value types
+// are prototypes of the map key and value, for example:
+//
+// map[string]string{"app": "nginx", "env": event.Branch}
+//
+func Map(keyType, valueType interface{}, keys, values []Expression) (*MapLiteral, error) {
+	keyType, valueType = ExpressionType(keyType), ExpressionType(valueType)
+	for _, t := range []interface{}{keyType, valueType} {
+		switch t.(type) {
+		case string, int, bool:
+		default:
+			return nil, trace.BadParameter("map of %T is not supported", t)
+		}
+	}
+	if len(keys) != len(values) {
+		return nil, trace.BadParameter("expected the same number of keys and values, got %v and %v", len(keys), len(values))
+	}
+	for i := range keys {
+		if err := ExpectEqualTypes(keys[i], keyType); err != nil {
+			return nil, trace.BadParameter("map key: %v", err)
+		}
+		if err := ExpectEqualTypes(values[i], valueType); err != nil {
+			return nil, trace.BadParameter("map value: %v", err)
+		}
+	}
+	return &MapLiteral{
+		keyType:   keyType,
+		valueType: valueType,
+		keys:      keys,
+		values:    values,
+	}, nil
+}
+
+// MapLiteral is a map literal
+type MapLiteral struct {
+	keyType   interface{}
+	valueType interface{}
+	keys      []Expression
+	values    []Expression
+}
+
+// Type returns map type
+func (m *MapLiteral) Type() interface{} {
+	mapType := reflect.MapOf(reflect.TypeOf(m.keyType), reflect.TypeOf(m.valueType))
+	return reflect.Zero(mapType).Interface()
+}
+
+// Eval evaluates keys and values of the map
+func (m *MapLiteral) Eval(ctx ExecutionContext) (interface{}, error) {
+	out := reflect.MakeMapWithSize(reflect.TypeOf(m.Type()), len(m.keys))
+	for i := range m.keys {
+		key, err := m.keys[i].Eval(ctx)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		val, err := m.values[i].Eval(ctx)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if out.MapIndex(reflect.ValueOf(key)).IsValid() {
+			return nil, trace.BadParameter("duplicate key %v in map literal", key)
+		}
+		out.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(val))
+	}
+	return out.Interface(), nil
+}
+
+// MarshalCode marshals map into map literal code
+func (m *MapLiteral) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, reflect.TypeOf(m.Type()).String()+"{")
+	for i := range m.keys {
+		if i != 0 {
+			io.WriteString(buf, ", ")
+		}
+		for j, node := range []Expression{m.keys[i], m.values[i]} {
+			if j != 0 {
+				io.WriteString(buf, ": ")
+			}
+			data, err := MarshalCode(ctx, node)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			buf.Write(data)
+		}
+	}
+	io.WriteString(buf, "}")
+	return buf.Bytes(), nil
+}
+
+// StringMapVar is a map of strings variable, used in structs
+// in place of map[string]string fields, for example
+// labels or annotations
+type StringMapVar struct {
+	Expression
+}
+
+// Type returns map of strings type
+func (s StringMapVar) Type() interface{} {
+	return map[string]string{}
+}
+
+// Eval evaluates map of strings
+func (s StringMapVar) Eval(ctx ExecutionContext) (interface{}, error) {
+	if s.Expression != nil {
+		return s.Expression.Eval(ctx)
+	}
+	var m map[string]string
+	return m, nil
+}
+
+// Convert converts maps and expressions evaluating
+// to maps into map of strings variable
+func (s StringMapVar) Convert(i interface{}) (interface{}, error) {
+	switch v := i.(type) {
+	case StringMapVar:
+		return v, nil
+	case Expression:
+		if reflect.TypeOf(v.Type()).AssignableTo(reflect.TypeOf(map[string]string{})) {
+			return StringMapVar{Expression: v}, nil
+		}
+		return nil, trace.BadParameter("can not assign type %T to map of strings variable", v.Type())
+	}
+	// maps with inferred type, like Labels: _{app: "nginx"}
+	// and Go maps are converted to map literals
+	in := reflect.ValueOf(i)
+	if in.Kind() != reflect.Map || in.Type().Key().Kind() != reflect.String {
+		return nil, trace.BadParameter("can not assign type %T to map of strings variable", i)
+	}
+	keys := make([]string, 0, in.Len())
+	for _, key := range in.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	// sort keys to make generated code deterministic
+	sort.Strings(keys)
+	converter := NewTypeConverter(reflect.TypeOf(StringVar{}))
+	literal := &MapLiteral{
+		keyType:   "",
+		valueType: "",
+		keys:      make([]Expression, len(keys)),
+		values:    make([]Expression, len(keys)),
+	}
+	for i, key := range keys {
+		val, err := converter.Convert(in.MapIndex(reflect.ValueOf(key).Convert(in.Type().Key())), reflect.TypeOf(StringVar{}))
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		literal.keys[i] = String(key)
+		literal.values[i] = val.Interface().(StringVar)
+	}
+	return StringMapVar{Expression: literal}, nil
+}
+
+// Index returns index expression, x is either a map
+// or a list, missing map keys evaluate to zero values:
+//
+// labels["app"]
+// Strings("a", "b")[1]
+//
+func Index(x, index Expression) (Expression, error) {
+	xType := reflect.TypeOf(x.Type())
+	if xType == nil {
+		return nil, trace.BadParameter("can not index %v", x.Type())
+	}
+	switch xType.Kind() {
+	case reflect.Map:
+		if reflect.TypeOf(index.Type()) != xType.Key() {
+			return nil, trace.BadParameter("can not use %v as map key of type %v", index.Type(), xType.Key())
+		}
+	case reflect.Slice:
+		if err := ExpectInt(index); err != nil {
+			return nil, trace.BadParameter("list index: %v", err)
+		}
+	default:
+		return nil, trace.BadParameter("can not index %v", x.Type())
+	}
+	return &IndexExpr{
+		x:        x,
+		index:    index,
+		elemType: reflect.Zero(xType.Elem()).Interface(),
+	}, nil
+}
+
+// IndexExpr is a map or list index expression
+type IndexExpr struct {
+	x        Expression
+	index    Expression
+	elemType interface{}
+}
+
+// Type returns type of the map value or list element
+func (i *IndexExpr) Type() interface{} {
+	return i.elemType
+}
+
+// Eval evaluates map value or list element
+func (i *IndexExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	x, err := i.x.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	index, err := i.index.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	xVal := reflect.ValueOf(x)
+	switch xVal.Kind() {
+	case reflect.Map:
+		val := xVal.MapIndex(reflect.ValueOf(index))
+		if !val.IsValid() {
+			return i.elemType, nil
+		}
+		return val.Interface(), nil
+	case reflect.Slice:
+		idx, ok := index.(int)
+		if !ok {
+			return nil, trace.BadParameter("expected int index, got %T", index)
+		}
+		if idx < 0 || idx >= xVal.Len() {
+			return nil, trace.BadParameter("index %v out of range with length %v", idx, xVal.Len())
+		}
+		return xVal.Index(idx).Interface(), nil
+	}
+	return nil, trace.BadParameter("can not index %T", x)
+}
+
+// MarshalCode marshals index expression to code
+func (i *IndexExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	data, err := MarshalCode(ctx, i.x)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	buf.Write(data)
+	io.WriteString(buf, "[")
+	data, err = MarshalCode(ctx, i.index)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	buf.Write(data)
+	io.WriteString(buf, "]")
+	return buf.Bytes(), nil
+}
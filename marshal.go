@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/gravitational/trace"
 )
@@ -93,6 +94,12 @@ func MarshalCode(ctx ExecutionContext, iface interface{}) ([]byte, error) {
 		return []byte(fmt.Sprintf("%d", val)), nil
 	case string:
 		return []byte(fmt.Sprintf("%q", val)), nil
+	case float64:
+		return marshalFloat(val), nil
+	case time.Duration:
+		return Duration(val).MarshalCode(ctx)
+	case time.Time:
+		return Time(val).MarshalCode(ctx)
 	case []string:
 		call := &FnCall{
 			Fn:   Strings,
@@ -232,14 +239,15 @@ func OriginalType(t reflect.Type) reflect.Type {
 
 // StructPackageName returns originating package name of this struct
 func StructPackageName(t reflect.Type) string {
-	field, ok := t.FieldByName(metadataFieldName)
-	if !ok {
-		if t.PkgPath() != reflect.TypeOf(Spec{}).PkgPath() {
-			return filepath.Base(t.PkgPath())
-		}
+	pkgPath := t.PkgPath()
+	if field, ok := t.FieldByName(metadataFieldName); ok {
+		pkgPath = field.Type.PkgPath()
+	}
+	// structs of this package are builtins
+	if pkgPath == reflect.TypeOf(Spec{}).PkgPath() {
 		return ""
 	}
-	return filepath.Base(field.Type.PkgPath())
+	return filepath.Base(pkgPath)
 }
 
 // FunctionName returns function name
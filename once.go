@@ -2,6 +2,7 @@ package force
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -78,6 +79,15 @@ func (d *FanInChannel) fanIn(ctx context.Context, channel Channel) {
 	}
 }
 
+// Watches returns resources watched by all sub channels
+func (d *FanInChannel) Watches() []Resource {
+	var out []Resource
+	for _, in := range d.in {
+		out = append(out, watches(in)...)
+	}
+	return out
+}
+
 // Start starts all sub channels
 // and launches fan in and wait gorotouines
 func (d *FanInChannel) Start(ctx context.Context) error {
@@ -102,6 +112,21 @@ func (d *FanInChannel) Events() <-chan Event {
 	return d.eventsC
 }
 
+// UnmarshalEvent restores the event of the first sub channel that
+// restores it, events serialized the same way by several sub channels
+// are restored by the first of them
+func (d *FanInChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	var errors []error
+	for _, in := range d.in {
+		event, err := unmarshalEvent(ctx, in, data)
+		if err == nil {
+			return event, nil
+		}
+		errors = append(errors, err)
+	}
+	return nil, trace.NewAggregate(errors...)
+}
+
 // MarshalCode marshals channel to code
 func (d *FanInChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 	call := &FnCall{
@@ -135,6 +160,14 @@ func (d *DuplicateChannel) String() string {
 	return fmt.Sprintf("Duplicate(count=%v)", d.count)
 }
 
+// Watches returns resources watched by the duplicated channel
+func (d *DuplicateChannel) Watches() []Resource {
+	if w, ok := d.in.(Watcher); ok {
+		return w.Watches()
+	}
+	return nil
+}
+
 func (d *DuplicateChannel) Start(pctx context.Context) error {
 	go d.in.Start(pctx)
 	go func() {
@@ -168,6 +201,11 @@ func (d *DuplicateChannel) Events() <-chan Event {
 	return d.eventsC
 }
 
+// UnmarshalEvent restores the event of the duplicated channel
+func (d *DuplicateChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	return unmarshalEvent(ctx, d.in, data)
+}
+
 // MarshalCode marshals channel to code
 func (d *DuplicateChannel) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 	return NewFnCall(Duplicate, d.count).MarshalCode(ctx)
@@ -229,13 +267,14 @@ func (o *OneshotEvent) String() string {
 func (e OneshotEvent) AddMetadata(ctx ExecutionContext) {
 }
 
-// Ticker returns a channel that fires with period
-func Ticker(period String) (Channel, error) {
-	if period == "" {
-		return nil, trace.BadParameter(
-			`set duration parameter, for example Ticker("100s"), supported abbreviations: s (seconds), m (minutes), h (hours), d (days), for example "100m" is tick every 100 minutes`)
-	}
-	duration, err := time.ParseDuration(string(period))
+// Ticker returns a channel that fires with period,
+// period is a constant duration or a duration string, for example:
+//
+// Ticker("10m")
+// Ticker(ParseDuration("1h") + ParseDuration("30m"))
+//
+func Ticker(period Expression) (Channel, error) {
+	duration, err := evalPeriod("Ticker", period)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -284,6 +323,15 @@ func (o *TickerChannel) Events() <-chan Event {
 	return o.eventsC
 }
 
+// UnmarshalEvent restores tick event
+func (o *TickerChannel) UnmarshalEvent(ctx context.Context, data []byte) (Event, error) {
+	var event TickEvent
+	if err := json.Unmarshal(data, &event.Time); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &event, nil
+}
+
 func (o *TickerChannel) Done() <-chan struct{} {
 	return nil
 }
@@ -300,5 +348,10 @@ func (o *TickEvent) String() string {
 	return fmt.Sprintf("Tick(time=%v)", o.Time)
 }
 
+// MarshalEvent serializes tick event
+func (o *TickEvent) MarshalEvent() ([]byte, error) {
+	return json.Marshal(o.Time)
+}
+
 func (e TickEvent) AddMetadata(ctx ExecutionContext) {
 }
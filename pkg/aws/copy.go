@@ -124,11 +124,34 @@ type CopyAction struct {
 	dest      interface{}
 }
 
+// Produces returns the local destination of the copy,
+// if the destination is known before the execution
+func (s *CopyAction) Produces() []force.Resource {
+	zero, err := force.ZeroFromAST(s.dest)
+	if err != nil {
+		return nil
+	}
+	if _, ok := zero.(Local); !ok {
+		return nil
+	}
+	var local Local
+	if err := force.EvalInto(force.EmptyContext(), s.dest, &local); err != nil || local.Path == "" {
+		return nil
+	}
+	return []force.Resource{{Kind: force.ResourceFile, Name: local.Path}}
+}
+
 func (s *CopyAction) Type() interface{} {
 	return 0
 }
 
+// Eval copies the files
 func (s *CopyAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "aws.Copy", s.eval)
+}
+
+// eval copies the files
+func (s *CopyAction) eval(ctx force.ExecutionContext) (interface{}, error) {
 	log := force.Log(ctx)
 
 	pluginI, ok := ctx.Process().Group().GetPlugin(Key)
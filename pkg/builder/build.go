@@ -112,7 +112,13 @@ func (b *BuildAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
 	if !ok {
 		return nil, trace.NotFound("initialize Builder plugin in the setup section")
 	}
-	return pluginI.(*Builder).Eval(ctx, b.image)
+	ctx, span := force.StartSpan(ctx, "builder.Build")
+	out, err := pluginI.(*Builder).Eval(ctx, b.image)
+	if err == nil {
+		span.SetTag("image", out)
+	}
+	force.FinishSpan(span, err)
+	return out, err
 }
 
 func (b *BuildAction) String() string {
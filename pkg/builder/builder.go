@@ -138,6 +138,9 @@ type Image struct {
 	Secrets []Secret
 	// Args is a list of the build arguments
 	Args []Arg
+	// BuildArgs is a map of the build arguments,
+	// used in addition to Args
+	BuildArgs map[string]string
 }
 
 // Secret is a secret passed to docker builds
@@ -214,6 +217,11 @@ func (i *Image) CheckAndSetDefaults() error {
 			return trace.Wrap(err)
 		}
 	}
+	for key := range i.BuildArgs {
+		if key == "" {
+			return trace.BadParameter("missing Key value of the build argument")
+		}
+	}
 	return nil
 }
 
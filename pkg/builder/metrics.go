@@ -0,0 +1,11 @@
+package builder
+
+import (
+	"github.com/gravitational/force/pkg/metrics"
+)
+
+var solveDuration = metrics.NewHistogram(
+	"force_builder_solve_duration_seconds",
+	"Duration of the image build solves, status is succeeded or failed.",
+	metrics.DefaultBuckets,
+	"status")
@@ -53,6 +53,9 @@ func (b *Builder) Eval(ectx force.ExecutionContext, iface interface{}) (interfac
 	for _, a := range img.Args {
 		frontendAttrs["build-arg:"+a.Key] = a.Val
 	}
+	for key, val := range img.BuildArgs {
+		frontendAttrs["build-arg:"+key] = val
+	}
 
 	sess, sessDialer, err := b.Session(ectx, img)
 	if err != nil {
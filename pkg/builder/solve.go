@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/gravitational/force"
+
 	controlapi "github.com/moby/buildkit/api/services/control"
 	"github.com/moby/buildkit/control"
 	"github.com/moby/buildkit/frontend"
@@ -33,10 +35,15 @@ func (b *Builder) solve(ctx context.Context, req *controlapi.SolveRequest, ch ch
 				cancelStatus()
 			}()
 		}()
+		span := force.StartSpanFromContext(ctx, "builder.Solve")
+		start := time.Now()
 		_, err := b.controller.Solve(ctx, req)
+		force.FinishSpan(span, err)
 		if err != nil {
+			solveDuration.Observe(time.Since(start).Seconds(), "failed")
 			return trace.Wrap(err, "failed to parse dockerfile")
 		}
+		solveDuration.Observe(time.Since(start).Seconds(), "succeeded")
 		return nil
 	})
 
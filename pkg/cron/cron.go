@@ -0,0 +1,234 @@
+// Package cron parses cron schedules and computes
+// the times the schedules are activated at
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// maxYears limits the search of the next activation time,
+// schedules like "0 0 30 2 *" are never activated
+const maxYears = 5
+
+// descriptors are shortcuts for the common schedules
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is a range of values of the schedule field
+type field struct {
+	name     string
+	min, max int
+	// names are optional names of the values, e.g. "jan" or "mon"
+	names map[string]int
+}
+
+var (
+	minutes = field{name: "minute", min: 0, max: 59}
+	hours   = field{name: "hour", min: 0, max: 23}
+	days    = field{name: "day of month", min: 1, max: 31}
+	months  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// day of week 7 is Sunday, the same as 0
+	weekdays = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// Schedule is a parsed cron schedule
+type Schedule struct {
+	spec    string
+	minute  uint64
+	hour    uint64
+	day     uint64
+	month   uint64
+	weekday uint64
+	// anyDay and anyWeekday are set when the day of month
+	// or the day of week are not restricted
+	anyDay     bool
+	anyWeekday bool
+}
+
+// Parse parses the five field cron schedule:
+// minute, hour, day of month, month and day of week,
+// fields are values, ranges, lists and steps, e.g. "0 3 * * 1-5"
+// or "*/15 9-17 * * mon-fri", descriptors like @daily are supported
+func Parse(spec string) (*Schedule, error) {
+	expanded := strings.TrimSpace(spec)
+	if d, ok := descriptors[strings.ToLower(expanded)]; ok {
+		expanded = d
+	}
+	parts := strings.Fields(expanded)
+	if len(parts) != 5 {
+		return nil, trace.BadParameter(
+			"cron schedule %q should have 5 fields: minute, hour, day of month, month and day of week, e.g. \"0 3 * * 1-5\"", spec)
+	}
+	s := &Schedule{spec: spec}
+	var err error
+	if s.minute, err = minutes.parse(parts[0]); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if s.hour, err = hours.parse(parts[1]); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if s.day, err = days.parse(parts[2]); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if s.month, err = months.parse(parts[3]); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if s.weekday, err = weekdays.parse(parts[4]); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if s.weekday&(1<<7) != 0 {
+		s.weekday |= 1
+	}
+	// fields starting with * do not restrict the day, e.g. "*/2"
+	s.anyDay = strings.HasPrefix(parts[2], "*") || parts[2] == "?"
+	s.anyWeekday = strings.HasPrefix(parts[4], "*") || parts[4] == "?"
+	return s, nil
+}
+
+// String returns the original schedule
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first activation time after t in the location
+// of t, returns zero time if the schedule is never activated
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + maxYears
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for !has(s.month, int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for !has(s.hour, t.Hour()) {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for !has(s.minute, t.Minute()) {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
+
+// dayMatches returns true if the day matches the schedule,
+// if both day of month and day of week are set, either could match
+func (s *Schedule) dayMatches(t time.Time) bool {
+	day := has(s.day, t.Day())
+	weekday := has(s.weekday, int(t.Weekday()))
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// has returns true if the value is set in the bit set
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// parse parses the comma separated list of ranges into a bit set
+func (f field) parse(in string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(in, ",") {
+		bits, err := f.parseRange(item)
+		if err != nil {
+			return 0, trace.Wrap(err)
+		}
+		set |= bits
+	}
+	return set, nil
+}
+
+// parseRange parses a value, a range or a step, e.g. "5", "1-5", "*/10" or "10-40/5"
+func (f field) parseRange(in string) (uint64, error) {
+	rangePart, stepPart := in, ""
+	if i := strings.IndexByte(in, '/'); i >= 0 {
+		rangePart, stepPart = in[:i], in[i+1:]
+	}
+	start, end := f.min, f.max
+	switch {
+	case rangePart == "*" || rangePart == "?":
+	case strings.Contains(rangePart, "-"):
+		bounds := strings.SplitN(rangePart, "-", 2)
+		var err error
+		if start, err = f.value(bounds[0]); err != nil {
+			return 0, trace.Wrap(err)
+		}
+		if end, err = f.value(bounds[1]); err != nil {
+			return 0, trace.Wrap(err)
+		}
+	default:
+		var err error
+		if start, err = f.value(rangePart); err != nil {
+			return 0, trace.Wrap(err)
+		}
+		// a value with a step, e.g. 5/10, runs until the end of the range
+		if stepPart == "" {
+			end = start
+		}
+	}
+	if start > end {
+		return 0, trace.BadParameter("%v range %q starts after it ends", f.name, in)
+	}
+	step := 1
+	if stepPart != "" {
+		var err error
+		step, err = strconv.Atoi(stepPart)
+		if err != nil || step <= 0 {
+			return 0, trace.BadParameter("%v step %q should be a positive number", f.name, stepPart)
+		}
+	}
+	var set uint64
+	for v := start; v <= end; v += step {
+		set |= 1 << uint(v)
+	}
+	return set, nil
+}
+
+// value parses a number or a name of the value
+func (f field) value(in string) (int, error) {
+	if v, ok := f.names[strings.ToLower(in)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(in)
+	if err != nil {
+		return 0, trace.BadParameter("%v %q is not a number", f.name, in)
+	}
+	if v < f.min || v > f.max {
+		return 0, trace.BadParameter("%v %v is out of range %v-%v", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
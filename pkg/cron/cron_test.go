@@ -0,0 +1,79 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+// Bootstrap check
+func Test(t *testing.T) { check.TestingT(t) }
+
+type CronSuite struct{}
+
+var _ = check.Suite(&CronSuite{})
+
+func (s *CronSuite) TestNext(c *check.C) {
+	// Monday
+	start := time.Date(2019, 7, 1, 10, 30, 15, 0, time.UTC)
+	type testCase struct {
+		spec     string
+		from     time.Time
+		expected time.Time
+	}
+	testCases := []testCase{
+		{spec: "* * * * *", from: start, expected: time.Date(2019, 7, 1, 10, 31, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", from: start, expected: time.Date(2019, 7, 1, 10, 45, 0, 0, time.UTC)},
+		{spec: "0 3 * * *", from: start, expected: time.Date(2019, 7, 2, 3, 0, 0, 0, time.UTC)},
+		{spec: "0 3 * * 1-5", from: time.Date(2019, 7, 5, 4, 0, 0, 0, time.UTC), expected: time.Date(2019, 7, 8, 3, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * sat,sun", from: start, expected: time.Date(2019, 7, 6, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 9 * * 7", from: start, expected: time.Date(2019, 7, 7, 9, 0, 0, 0, time.UTC)},
+		{spec: "30 10 1 * *", from: start, expected: time.Date(2019, 8, 1, 10, 30, 0, 0, time.UTC)},
+		{spec: "0 0 1 jan *", from: start, expected: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", from: start, expected: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "@weekly", from: start, expected: time.Date(2019, 7, 7, 0, 0, 0, 0, time.UTC)},
+		{spec: "@hourly", from: start, expected: time.Date(2019, 7, 1, 11, 0, 0, 0, time.UTC)},
+		{spec: "10-40/10 12 * * *", from: start, expected: time.Date(2019, 7, 1, 12, 10, 0, 0, time.UTC)},
+		// either day of month or day of week matches when both are set
+		{spec: "0 0 15 * fri", from: start, expected: time.Date(2019, 7, 5, 0, 0, 0, 0, time.UTC)},
+		// never activated
+		{spec: "0 0 30 2 *", from: start, expected: time.Time{}},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %q", i, tc.spec)
+		schedule, err := Parse(tc.spec)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(schedule.Next(tc.from), check.DeepEquals, tc.expected, comment)
+	}
+
+	// activation times are computed in the location of the time
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	c.Assert(err, check.IsNil)
+	schedule, err := Parse("0 9 * * *")
+	c.Assert(err, check.IsNil)
+	next := schedule.Next(start.In(berlin))
+	c.Assert(next.Equal(time.Date(2019, 7, 2, 7, 0, 0, 0, time.UTC)), check.Equals, true, check.Commentf("%v", next))
+}
+
+func (s *CronSuite) TestParseErrors(c *check.C) {
+	errCases := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"@often",
+	}
+	for i, spec := range errCases {
+		_, err := Parse(spec)
+		c.Assert(err, check.NotNil, check.Commentf("test case %v %q", i, spec))
+	}
+}
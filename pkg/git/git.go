@@ -211,11 +211,27 @@ type CloneAction struct {
 	repo interface{}
 }
 
+// Produces returns the directory the repository is cloned into,
+// if the directory is known before the execution
+func (p *CloneAction) Produces() []force.Resource {
+	var repo Repo
+	if err := force.EvalKnownInto(p.repo, &repo); err != nil || repo.Into == "" {
+		return nil
+	}
+	return []force.Resource{{Kind: force.ResourceFile, Name: repo.Into}}
+}
+
 func (p *CloneAction) Type() interface{} {
 	return ""
 }
 
+// Eval clones the repository
 func (p *CloneAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "git.Clone", p.eval)
+}
+
+// eval clones the repository
+func (p *CloneAction) eval(ctx force.ExecutionContext) (interface{}, error) {
 	pluginI, ok := ctx.Process().Group().GetPlugin(Key)
 	if !ok {
 		return nil, trace.NotFound("initialize Git plugin in the setup section")
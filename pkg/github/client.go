@@ -49,6 +49,7 @@ func newGithubClient(ctx context.Context, cfg Config) (*GithubClient, error) {
 	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: string(cfg.Token)},
 	))
+	client.Transport = &instrumentedTransport{next: client.Transport}
 	return &GithubClient{
 		V3: github.NewClient(client),
 		V4: githubv4.NewClient(client),
@@ -2,6 +2,7 @@ package github
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
@@ -54,6 +55,11 @@ func (r *BranchWatcher) String() string {
 	return fmt.Sprintf("BranchWatcher(%v)", r.source.Repo)
 }
 
+// Watches returns the branches matching the branch pattern
+func (r *BranchWatcher) Watches() []force.Resource {
+	return []force.Resource{force.GitHubBranch(r.source.Repo, r.source.BranchPattern)}
+}
+
 // MarshalCode marshals things to code
 func (r *BranchWatcher) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
 	call := &force.FnCall{
@@ -216,6 +222,21 @@ func (r *BranchWatcher) Done() <-chan struct{} {
 	return nil
 }
 
+// UnmarshalEvent restores branch event
+func (r *BranchWatcher) UnmarshalEvent(ctx context.Context, data []byte) (force.Event, error) {
+	var e branchEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &BranchEvent{
+		Commit:  force.String(e.Commit),
+		Branch:  force.String(e.Branch),
+		branch:  e.BranchObject,
+		Source:  e.Source,
+		created: e.Created,
+	}, nil
+}
+
 // BranchEvent is a commit event
 type BranchEvent struct {
 	Commit  force.String
@@ -254,6 +275,26 @@ func (r *BranchEvent) AddMetadata(ctx force.ExecutionContext) {
 	ctx.SetValue(force.ContextKey(force.KeyEvent), *r)
 }
 
+// MarshalEvent serializes branch event
+func (r *BranchEvent) MarshalEvent() ([]byte, error) {
+	return json.Marshal(branchEventJSON{
+		Commit:       string(r.Commit),
+		Branch:       string(r.Branch),
+		BranchObject: r.branch,
+		Source:       r.Source,
+		Created:      r.created,
+	})
+}
+
+// branchEventJSON is a serialized branch event
+type branchEventJSON struct {
+	Commit       string    `json:"commit"`
+	Branch       string    `json:"branch"`
+	BranchObject Branch    `json:"branch_object"`
+	Source       Source    `json:"source"`
+	Created      time.Time `json:"created"`
+}
+
 func (r *BranchEvent) String() string {
 	return fmt.Sprintf("github branch %v, commit %v", r.Branch, r.Commit)
 }
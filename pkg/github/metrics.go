@@ -0,0 +1,38 @@
+package github
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gravitational/force/pkg/metrics"
+)
+
+var (
+	apiCalls = metrics.NewCounter(
+		"force_github_api_calls_total",
+		"GitHub API calls, code is an HTTP status code or error.",
+		"code")
+	rateLimitRemaining = metrics.NewGauge(
+		"force_github_rate_limit_remaining",
+		"GitHub API requests remaining in the current rate limit window.")
+)
+
+// instrumentedTransport counts GitHub API calls
+// and tracks the remaining rate limit
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip executes the request and updates the metrics
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	re, err := t.next.RoundTrip(req)
+	if err != nil {
+		apiCalls.Inc("error")
+		return nil, err
+	}
+	apiCalls.Inc(strconv.Itoa(re.StatusCode))
+	if remaining, err := strconv.Atoi(re.Header.Get("X-RateLimit-Remaining")); err == nil {
+		rateLimitRemaining.Set(float64(remaining))
+	}
+	return re, nil
+}
@@ -1,8 +1,10 @@
 package github
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gravitational/force"
 
@@ -69,8 +71,19 @@ func (p *PostStatusAction) Type() interface{} {
 	return p.status
 }
 
-// Run posts github status
+// Eval posts github status
 func (p *PostStatusAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "github.PostStatus", p.eval)
+}
+
+// eval posts github status
+func (p *PostStatusAction) eval(ctx force.ExecutionContext) (interface{}, error) {
+	return p.post(ctx, ctx)
+}
+
+// post posts github status using apiCtx for the API call,
+// so the status could be posted after ctx has been cancelled
+func (p *PostStatusAction) post(ctx force.ExecutionContext, apiCtx context.Context) (interface{}, error) {
 	event, ok := ctx.Event().(CommitGetter)
 	if !ok {
 		// it should be possible to execute post status
@@ -86,18 +99,23 @@ func (p *PostStatusAction) Eval(ctx force.ExecutionContext) (interface{}, error)
 	log := force.Log(ctx)
 	commitRef := event.GetCommit()
 
+	targetURL := p.status.URL
+	if targetURL == "" {
+		targetURL = force.RunURL(ctx)
+	}
+
 	if p.status.Context == "" {
 		p.status.Context = ctx.Process().Name()
 	}
 
 	_, _, err = p.plugin.client.V3.Repositories.CreateStatus(
-		ctx,
+		apiCtx,
 		repo.Owner,
 		repo.Name,
 		commitRef,
 		&github.RepoStatus{
 			State:       github.String(p.status.State),
-			TargetURL:   github.String(log.URL(ctx)),
+			TargetURL:   github.String(targetURL),
 			Description: github.String(p.status.Description),
 			Context:     github.String(p.status.Context),
 		},
@@ -126,6 +144,15 @@ type PostStatusOfAction struct {
 	actions []force.Action
 }
 
+// Children returns inner actions
+func (p *PostStatusOfAction) Children() []interface{} {
+	out := make([]interface{}, len(p.actions))
+	for i := range p.actions {
+		out[i] = p.actions[i]
+	}
+	return out
+}
+
 func (p *PostStatusOfAction) Type() interface{} {
 	return p.seq.Type()
 }
@@ -153,7 +180,17 @@ func (p *PostStatusOfAction) Eval(ctx force.ExecutionContext) (interface{}, erro
 		return nil, trace.Wrap(err)
 	}
 	out, err := p.seq.Eval(ctx)
-	if err != nil {
+	apiCtx := context.Context(ctx)
+	switch {
+	case err != nil && force.IsSuperseded(ctx):
+		// the context of the superseded run is cancelled,
+		// so the status is posted with a separate context
+		result.State = StateError
+		result.Description = "superseded by a newer run"
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), PostStatusTimeout)
+		defer cancel()
+		apiCtx = timeoutCtx
+	case err != nil:
 		result.State = StateFailure
 		result.Description = err.Error()
 	}
@@ -161,7 +198,7 @@ func (p *PostStatusOfAction) Eval(ctx force.ExecutionContext) (interface{}, erro
 		status: result,
 		plugin: p.plugin,
 	}
-	_, resultErr := postResult.Eval(ctx)
+	_, resultErr := postResult.post(ctx, apiCtx)
 	return out, trace.NewAggregate(err, resultErr)
 }
 
@@ -179,7 +216,8 @@ func (p *PostStatusOfAction) MarshalCode(ctx force.ExecutionContext) ([]byte, er
 type Status struct {
 	// State is a PR state
 	State string
-	// URL is a url of this web app, force should provide a web interface
+	// URL is a target url of the status, by default
+	// it is a page of the run served by the force server
 	URL string
 	// Description is an optional description
 	Description string
@@ -217,4 +255,8 @@ const (
 	DefaultContext = "Force CI"
 )
 
+// PostStatusTimeout is a timeout of posting the status
+// of the run superseded by a newer run
+const PostStatusTimeout = 30 * time.Second
+
 var allowedStates = []string{StateSuccess, StatePending, StateFailure, StateError}
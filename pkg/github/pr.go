@@ -2,6 +2,7 @@ package github
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
@@ -54,6 +55,12 @@ func (r *PullRequestWatcher) String() string {
 	return fmt.Sprintf("PullRequestWatcher(%v)", r.source.Repo)
 }
 
+// Watches returns all branches of the repository, because
+// a push to any branch could update a pull request
+func (r *PullRequestWatcher) Watches() []force.Resource {
+	return []force.Resource{force.GitHubBranch(r.source.Repo, ".*")}
+}
+
 // MarshalCode marshals things to code
 func (r *PullRequestWatcher) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
 	call := &force.FnCall{
@@ -332,6 +339,21 @@ func (r *PullRequestWatcher) Done() <-chan struct{} {
 	return nil
 }
 
+// UnmarshalEvent restores pull request event
+func (r *PullRequestWatcher) UnmarshalEvent(ctx context.Context, data []byte) (force.Event, error) {
+	var e pullRequestEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &PullRequestEvent{
+		PR:          force.Int(e.PullRequest.Number),
+		Commit:      force.String(e.PullRequest.LastCommit.OID),
+		Source:      e.Source,
+		PullRequest: e.PullRequest,
+		created:     e.Created,
+	}, nil
+}
+
 type CommitGetter interface {
 	// GetCommit returns commit associated with the event
 	GetCommit() string
@@ -377,6 +399,22 @@ func (r *PullRequestEvent) AddMetadata(ctx force.ExecutionContext) {
 	ctx.SetValue(force.ContextKey(force.KeyEvent), *r)
 }
 
+// MarshalEvent serializes pull request event
+func (r *PullRequestEvent) MarshalEvent() ([]byte, error) {
+	return json.Marshal(pullRequestEventJSON{
+		Source:      r.Source,
+		PullRequest: r.PullRequest,
+		Created:     r.created,
+	})
+}
+
+// pullRequestEventJSON is a serialized pull request event
+type pullRequestEventJSON struct {
+	Source      Source      `json:"source"`
+	PullRequest PullRequest `json:"pull_request"`
+	Created     time.Time   `json:"created"`
+}
+
 func (r *PullRequestEvent) String() string {
 	return fmt.Sprintf("github pr %v, commit %v, updated %v with comment %q by %v",
 		r.PullRequest.Number, r.PullRequest.LastCommit.OID[:9], r.PullRequest.LastUpdated().Format(force.HumanDateFormat),
@@ -0,0 +1,255 @@
+package http
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+)
+
+// Scope returns a new scope with all the functions and structs
+// defined, this is the entrypoint into plugin as far as force is concerned
+func Scope() (force.Group, error) {
+	scope := force.WithLexicalScope(nil)
+	err := force.ImportStructsIntoAST(scope,
+		reflect.TypeOf(Config{}),
+	)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	scope.AddDefinition(KeySetup, &Setup{})
+	scope.AddDefinition(KeyWebhook, &NewWebhook{})
+	return scope, nil
+}
+
+// Namespace is a wrapper around string to namespace a variable in the context
+type Namespace string
+
+// Key is a name of the plugin variable
+const Key = Namespace("http")
+
+const (
+	KeySetup   = "Setup"
+	KeyConfig  = "Config"
+	KeyWebhook = "Webhook"
+)
+
+// DefaultListen is a default address of the webhook listener
+const DefaultListen = ":8090"
+
+const (
+	// maxBodySize is the maximum size of the request body
+	maxBodySize = 10 * 1024 * 1024
+	// readHeaderTimeout is a timeout of reading the request headers
+	readHeaderTimeout = 10 * time.Second
+	// readTimeout is a timeout of reading the whole request
+	readTimeout = time.Minute
+)
+
+// Config is a webhook listener configuration
+type Config struct {
+	// Listen is an address to listen on, ":8090" by default
+	Listen string
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (cfg *Config) CheckAndSetDefaults() error {
+	if cfg.Listen == "" {
+		cfg.Listen = DefaultListen
+	}
+	if _, _, err := net.SplitHostPort(cfg.Listen); err != nil {
+		return trace.BadParameter("http.Config Listen %q should be host:port: %v", cfg.Listen, err)
+	}
+	return nil
+}
+
+// Plugin serves webhooks and routes the requests
+// to the webhook channels by the path
+type Plugin struct {
+	cfg   Config
+	clock clockwork.Clock
+	mutex sync.RWMutex
+	// hooks are the started webhook channels by the path
+	hooks map[string]map[*WebhookChannel]struct{}
+}
+
+// New returns a new plugin
+func New(cfg Config, clock clockwork.Clock) (*Plugin, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &Plugin{
+		cfg:   cfg,
+		clock: clock,
+		hooks: make(map[string]map[*WebhookChannel]struct{}),
+	}, nil
+}
+
+// subscribe adds the channel serving the path
+func (p *Plugin) subscribe(channel *WebhookChannel) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	path := channel.hook.Path
+	if p.hooks[path] == nil {
+		p.hooks[path] = make(map[*WebhookChannel]struct{})
+	}
+	p.hooks[path][channel] = struct{}{}
+}
+
+// unsubscribe removes the channel
+func (p *Plugin) unsubscribe(channel *WebhookChannel) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	path := channel.hook.Path
+	delete(p.hooks[path], channel)
+	if len(p.hooks[path]) == 0 {
+		delete(p.hooks, path)
+	}
+}
+
+// channels returns the channels serving the path
+func (p *Plugin) channels(path string) []*WebhookChannel {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	out := make([]*WebhookChannel, 0, len(p.hooks[path]))
+	for channel := range p.hooks[path] {
+		out = append(out, channel)
+	}
+	return out
+}
+
+// ServeHTTP sends the request to the channels serving the path,
+// the request is accepted if at least one channel has verified it
+func (p *Plugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	channels := p.channels(r.URL.Path)
+	if len(channels) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		http.Error(w, "failed to read the request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxBodySize {
+		http.Error(w, "request body is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	now := p.clock.Now().UTC()
+	// keys of the verified requests are reserved by the channels
+	// and released if the event has not reached the channel,
+	// so the request could be retried
+	verified := make(map[*WebhookChannel][]string)
+	for _, channel := range channels {
+		keys, err := channel.verify(r.Header, body, now)
+		if err != nil {
+			channel.log.Warningf("%v has rejected the request from %v: %v.", channel, r.RemoteAddr, trace.UserMessage(err))
+			continue
+		}
+		verified[channel] = keys
+	}
+	if len(verified) == 0 {
+		http.Error(w, "signature verification failed", http.StatusUnauthorized)
+		return
+	}
+	event, err := newWebhookEvent(r, body, now)
+	if err != nil {
+		for channel, keys := range verified {
+			channel.release(keys)
+		}
+		http.Error(w, trace.UserMessage(err), http.StatusBadRequest)
+		return
+	}
+	accepted := false
+	for channel, keys := range verified {
+		select {
+		case channel.eventsC <- event:
+			accepted = true
+		default:
+			channel.log.Warningf("Overflow, %v is dropping %v.", channel, event)
+			channel.release(keys)
+		}
+	}
+	if !accepted {
+		http.Error(w, "webhook queue is full", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Setup creates new plugin instances
+type Setup struct {
+	cfg interface{}
+}
+
+// NewInstance returns function creating new plugin bound to the process group
+// and registers plugin variable
+func (n *Setup) NewInstance(group force.Group) (force.Group, interface{}) {
+	return group, func(cfg interface{}) (force.Action, error) {
+		return &Setup{
+			cfg: cfg,
+		}, nil
+	}
+}
+
+// Type returns the type of the action result
+func (n *Setup) Type() interface{} {
+	return false
+}
+
+// Eval starts the webhook listener, the listener
+// is stopped when the process group exits
+func (n *Setup) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	var cfg Config
+	if err := force.EvalInto(ctx, n.cfg, &cfg); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	plugin, err := New(cfg, clockwork.NewRealClock())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	listener, err := net.Listen("tcp", plugin.cfg.Listen)
+	if err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	group := ctx.Process().Group()
+	server := &http.Server{
+		Handler:           plugin,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+	}
+	log := group.Logger()
+	go func() {
+		<-group.Context().Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Errorf("Webhook listener has failed.")
+		}
+	}()
+	log.Infof("Serving webhooks on %v.", listener.Addr())
+	group.SetPlugin(Key, plugin)
+	return true, nil
+}
+
+// MarshalCode marshals plugin code to representation
+func (n *Setup) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	call := &force.FnCall{
+		Package: string(Key),
+		FnName:  KeySetup,
+		Args:    []interface{}{n.cfg},
+	}
+	return call.MarshalCode(ctx)
+}
@@ -0,0 +1,451 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/gravitational/trace"
+)
+
+const (
+	// AlgorithmSHA256 is HMAC-SHA256 signature algorithm
+	AlgorithmSHA256 = "sha256"
+	// AlgorithmSHA1 is HMAC-SHA1 signature algorithm used
+	// by the GitHub X-Hub-Signature header
+	AlgorithmSHA1 = "sha1"
+	// DefaultMaxAge is a default maximum age of the signed timestamp
+	DefaultMaxAge = 5 * time.Minute
+	// DeliveryHeader is the header with the unique ID of the delivery
+	// GitHub sends, the redelivered requests keep the ID
+	DeliveryHeader = "X-GitHub-Delivery"
+	// DeliveryTTL is how long the delivery IDs of the accepted requests
+	// are remembered, GitHub redelivers the deliveries of the past 3 days
+	DeliveryTTL = 72 * time.Hour
+	// pruneInterval is how often the expired keys are removed
+	pruneInterval = time.Minute
+)
+
+// Webhook configures the webhook channel
+type Webhook struct {
+	// Path is the URL path of the webhook, e.g. "/hooks/deploy"
+	Path string
+	// Secret is the HMAC secret of the signature,
+	// required unless the webhook is Insecure
+	Secret string
+	// Insecure accepts the requests without verifying them,
+	// Secret can not be set for the insecure webhooks
+	Insecure bool
+	// SignatureHeader is the header with the hex encoded
+	// signature of the body, with optional "sha256=" prefix,
+	// "X-Hub-Signature-256" for sha256 and "X-Hub-Signature"
+	// for sha1 by default, the same headers GitHub sends
+	SignatureHeader string
+	// Algorithm is the signature algorithm, "sha256" (default) or "sha1"
+	Algorithm string
+	// TimestampHeader is the header with the unix time of the request,
+	// if set, the signature is computed over "<timestamp>.<body>"
+	// and the requests older than MaxAge or replayed are rejected
+	TimestampHeader string
+	// MaxAge is the maximum age of the timestamp, 5 minutes by default,
+	// signatures of the accepted requests are remembered
+	// for twice the MaxAge to reject the replayed requests
+	MaxAge time.Duration
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (w *Webhook) CheckAndSetDefaults() error {
+	if !strings.HasPrefix(w.Path, "/") {
+		return trace.BadParameter(`set Webhook{Path: "/hooks/name"} parameter, the path should start with /`)
+	}
+	w.Algorithm = strings.ToLower(w.Algorithm)
+	switch w.Algorithm {
+	case "":
+		w.Algorithm = AlgorithmSHA256
+	case AlgorithmSHA256, AlgorithmSHA1:
+	default:
+		return trace.BadParameter("unsupported Webhook Algorithm %q, use %q or %q", w.Algorithm, AlgorithmSHA256, AlgorithmSHA1)
+	}
+	if w.SignatureHeader == "" {
+		w.SignatureHeader = "X-Hub-Signature-256"
+		if w.Algorithm == AlgorithmSHA1 {
+			w.SignatureHeader = "X-Hub-Signature"
+		}
+	}
+	if w.MaxAge < 0 {
+		return trace.BadParameter("Webhook MaxAge can not be negative")
+	}
+	if w.MaxAge == 0 {
+		w.MaxAge = DefaultMaxAge
+	}
+	if w.Secret == "" && !w.Insecure {
+		return trace.BadParameter("set Webhook Secret to verify the requests, or set Insecure: true to accept unverified requests")
+	}
+	if w.Secret != "" && w.Insecure {
+		return trace.BadParameter("Webhook with Secret can not be Insecure")
+	}
+	if w.TimestampHeader != "" && w.Secret == "" {
+		return trace.BadParameter("Webhook TimestampHeader requires Secret, timestamps are verified as a part of the signature")
+	}
+	return nil
+}
+
+// newHash returns hash function of the algorithm
+func (w *Webhook) newHash() func() hash.Hash {
+	if w.Algorithm == AlgorithmSHA1 {
+		return sha1.New
+	}
+	return sha256.New
+}
+
+// Sign returns hex encoded signature of the body, the timestamp
+// is signed along with the body if the webhook uses timestamps
+func (w *Webhook) Sign(body []byte, timestamp string) string {
+	mac := hmac.New(w.newHash(), []byte(w.Secret))
+	if w.TimestampHeader != "" {
+		mac.Write([]byte(timestamp + "."))
+	}
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhook finds the initialized http plugin and returns a new webhook
+type NewWebhook struct {
+}
+
+// NewInstance returns a function creating webhook channels
+func (n *NewWebhook) NewInstance(group force.Group) (force.Group, interface{}) {
+	group.AddDefinition(force.KeyEvent, WebhookEvent{
+		Headers: map[string]string{},
+		Query:   map[string]string{},
+		Body:    map[string]string{},
+	})
+	return group, func(config interface{}) (force.Channel, error) {
+		pluginI, ok := group.GetPlugin(Key)
+		if !ok {
+			return nil, trace.NotFound("http plugin is not initialized, use http.Setup to initialize it")
+		}
+		var hook Webhook
+		if err := force.EvalInto(force.EmptyContext(), config, &hook); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if err := hook.CheckAndSetDefaults(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return &WebhookChannel{
+			plugin:  pluginI.(*Plugin),
+			config:  config,
+			hook:    hook,
+			seen:    make(map[string]time.Time),
+			eventsC: make(chan force.Event, force.DefaultChannelBufferSize),
+		}, nil
+	}
+}
+
+// WebhookChannel receives the verified requests to the webhook path
+type WebhookChannel struct {
+	plugin  *Plugin
+	config  interface{}
+	hook    Webhook
+	eventsC chan force.Event
+	log     force.Logger
+	mutex   sync.Mutex
+	// seen are the signatures of the accepted requests with timestamps
+	// and the delivery IDs with their expiration time,
+	// used to reject replayed requests
+	seen map[string]time.Time
+	// pruned is the time the expired keys were removed
+	pruned time.Time
+}
+
+// String returns user friendly representation of the channel
+func (w *WebhookChannel) String() string {
+	return fmt.Sprintf("Webhook(%v)", w.hook.Path)
+}
+
+// Start starts receiving the requests until the context is closed
+func (w *WebhookChannel) Start(pctx context.Context) error {
+	w.log = force.Log(pctx)
+	if w.hook.Insecure {
+		w.log.Warningf("%v is Insecure, requests are not verified.", w)
+	}
+	w.plugin.subscribe(w)
+	go func() {
+		<-pctx.Done()
+		w.plugin.unsubscribe(w)
+	}()
+	return nil
+}
+
+// verify verifies the request and reserves the signature of the timestamped
+// request and the delivery ID, so the replayed requests are rejected,
+// GitHub does not sign the delivery ID, so only the requests redelivered
+// or replayed as is are rejected by it, returns the reserved keys
+// that are released if the request is not accepted
+func (w *WebhookChannel) verify(header http.Header, body []byte, now time.Time) ([]string, error) {
+	var keys []string
+	if !w.hook.Insecure {
+		signature, err := w.verifySignature(header, body, now)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if w.hook.TimestampHeader != "" {
+			// requests older than twice the max age are rejected by the timestamp
+			if err := w.reserve(signature, now, now.Add(2*w.hook.MaxAge)); err != nil {
+				return nil, trace.Wrap(err)
+			}
+			keys = append(keys, signature)
+		}
+	}
+	if delivery := header.Get(DeliveryHeader); delivery != "" {
+		key := DeliveryHeader + ":" + delivery
+		if err := w.reserve(key, now, now.Add(DeliveryTTL)); err != nil {
+			w.release(keys)
+			return nil, trace.Wrap(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// verifySignature verifies the signature and the timestamp
+// of the request and returns the signature
+func (w *WebhookChannel) verifySignature(header http.Header, body []byte, now time.Time) (string, error) {
+	signature := header.Get(w.hook.SignatureHeader)
+	if signature == "" {
+		return "", trace.AccessDenied("missing %v header", w.hook.SignatureHeader)
+	}
+	signature = strings.ToLower(strings.TrimPrefix(signature, w.hook.Algorithm+"="))
+	timestamp := header.Get(w.hook.TimestampHeader)
+	if w.hook.TimestampHeader != "" {
+		if timestamp == "" {
+			return "", trace.AccessDenied("missing %v header", w.hook.TimestampHeader)
+		}
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return "", trace.AccessDenied("%v header should be unix time in seconds", w.hook.TimestampHeader)
+		}
+		age := now.Sub(time.Unix(seconds, 0))
+		if age > w.hook.MaxAge || age < -w.hook.MaxAge {
+			return "", trace.AccessDenied("timestamp %v is outside of the %v window", timestamp, w.hook.MaxAge)
+		}
+	}
+	if !hmac.Equal([]byte(signature), []byte(w.hook.Sign(body, timestamp))) {
+		return "", trace.AccessDenied("signature does not match")
+	}
+	return signature, nil
+}
+
+// reserve records the signature or the delivery ID of the request until
+// it expires, returns error if the request with the same key has been accepted
+func (w *WebhookChannel) reserve(key string, now, expires time.Time) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if now.Sub(w.pruned) > pruneInterval {
+		for k, e := range w.seen {
+			if now.After(e) {
+				delete(w.seen, k)
+			}
+		}
+		w.pruned = now
+	}
+	if e, ok := w.seen[key]; ok && !now.After(e) {
+		return trace.AccessDenied("request has been replayed")
+	}
+	w.seen[key] = expires
+	return nil
+}
+
+// release removes the keys of the request that has not been accepted,
+// so the request could be retried
+func (w *WebhookChannel) release(keys []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, key := range keys {
+		delete(w.seen, key)
+	}
+}
+
+// Events returns webhook events
+func (w *WebhookChannel) Events() <-chan force.Event {
+	return w.eventsC
+}
+
+// Done returns nil, webhook channel never completes
+func (w *WebhookChannel) Done() <-chan struct{} {
+	return nil
+}
+
+// UnmarshalEvent restores webhook event
+func (w *WebhookChannel) UnmarshalEvent(ctx context.Context, data []byte) (force.Event, error) {
+	var e webhookEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &WebhookEvent{
+		Method:  force.String(e.Method),
+		Path:    force.String(e.Path),
+		Headers: e.Headers,
+		Query:   e.Query,
+		Body:    e.Body,
+		RawBody: force.String(e.RawBody),
+		created: e.Created,
+	}, nil
+}
+
+// MarshalCode marshals channel to code
+func (w *WebhookChannel) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	call := &force.FnCall{
+		Package: string(Key),
+		FnName:  KeyWebhook,
+		Args:    []interface{}{w.config},
+	}
+	return call.MarshalCode(ctx)
+}
+
+// newWebhookEvent returns the event of the request, JSON body is decoded,
+// as well as JSON sent in the payload field of the form the way GitHub does
+func newWebhookEvent(r *http.Request, body []byte, now time.Time) (*WebhookEvent, error) {
+	e := &WebhookEvent{
+		Method:  force.String(r.Method),
+		Path:    force.String(r.URL.Path),
+		Headers: make(map[string]string, len(r.Header)),
+		Query:   make(map[string]string),
+		Body:    make(map[string]string),
+		RawBody: force.String(body),
+		created: now,
+	}
+	for key := range r.Header {
+		e.Headers[key] = r.Header.Get(key)
+	}
+	query := r.URL.Query()
+	for key := range query {
+		e.Query[key] = query.Get(key)
+	}
+	payload := bytes.TrimSpace(body)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(payload))
+		if err == nil && values.Get("payload") != "" {
+			payload = bytes.TrimSpace([]byte(values.Get("payload")))
+		}
+	}
+	// clients like curl send JSON as a form by default,
+	// so bodies looking like JSON objects or lists are decoded too
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	if len(payload) == 0 || (!isJSON && payload[0] != '{' && payload[0] != '[') {
+		return e, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, trace.BadParameter("failed to decode JSON body: %v", err)
+	}
+	flatten("", value, e.Body)
+	return e, nil
+}
+
+// flatten adds the values of the decoded JSON to the map,
+// the keys of nested values are joined with dots and list
+// elements use indexes, e.g. "commits.0.id"
+func flatten(prefix string, value interface{}, out map[string]string) {
+	key := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			flatten(key(k), val, out)
+		}
+	case []interface{}:
+		for i, val := range v {
+			flatten(key(strconv.Itoa(i)), val, out)
+		}
+	case string:
+		out[prefix] = v
+	case json.Number:
+		out[prefix] = v.String()
+	case bool:
+		out[prefix] = strconv.FormatBool(v)
+	case nil:
+		out[prefix] = ""
+	}
+}
+
+// WebhookEvent is the request received by the webhook
+type WebhookEvent struct {
+	// Method is the request method
+	Method force.String
+	// Path is the request path
+	Path force.String
+	// Headers are the request headers in canonical form,
+	// e.g. "X-Github-Event", with the first value of the header
+	Headers map[string]string
+	// Query are the query parameters with the first value of the parameter
+	Query map[string]string
+	// Body is the decoded JSON body, the keys of nested values are
+	// joined with dots, e.g. "repository.full_name" or "commits.0.id"
+	Body map[string]string
+	// RawBody is the request body
+	RawBody force.String
+	created time.Time
+}
+
+// Created returns the time the request was received
+func (e *WebhookEvent) Created() time.Time {
+	return e.created
+}
+
+// String returns user friendly representation of the event
+func (e *WebhookEvent) String() string {
+	return fmt.Sprintf("Webhook(%v %v)", e.Method, e.Path)
+}
+
+// MarshalEvent serializes webhook event
+func (e *WebhookEvent) MarshalEvent() ([]byte, error) {
+	return json.Marshal(webhookEventJSON{
+		Method:  string(e.Method),
+		Path:    string(e.Path),
+		Headers: e.Headers,
+		Query:   e.Query,
+		Body:    e.Body,
+		RawBody: string(e.RawBody),
+		Created: e.created,
+	})
+}
+
+// AddMetadata sets the event in the context,
+// so the body is available as event.Body
+func (e *WebhookEvent) AddMetadata(ctx force.ExecutionContext) {
+	ctx.SetValue(force.ContextKey(force.KeyEvent), *e)
+}
+
+// webhookEventJSON is a serialized webhook event
+type webhookEventJSON struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Query   map[string]string `json:"query"`
+	Body    map[string]string `json:"body"`
+	RawBody string            `json:"raw_body"`
+	Created time.Time         `json:"created"`
+}
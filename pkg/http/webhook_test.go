@@ -0,0 +1,288 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/jonboulle/clockwork"
+	"gopkg.in/check.v1"
+)
+
+// Bootstrap check
+func Test(t *testing.T) { check.TestingT(t) }
+
+type WebhookSuite struct {
+	clock  clockwork.FakeClock
+	plugin *Plugin
+	server *httptest.Server
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ = check.Suite(&WebhookSuite{})
+
+func (s *WebhookSuite) SetUpTest(c *check.C) {
+	s.clock = clockwork.NewFakeClockAt(time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC))
+	var err error
+	s.plugin, err = New(Config{}, s.clock)
+	c.Assert(err, check.IsNil)
+	s.server = httptest.NewServer(s.plugin)
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+}
+
+func (s *WebhookSuite) TearDownTest(c *check.C) {
+	s.cancel()
+	s.server.Close()
+}
+
+// start starts a new webhook channel
+func (s *WebhookSuite) start(c *check.C, hook Webhook) *WebhookChannel {
+	c.Assert(hook.CheckAndSetDefaults(), check.IsNil)
+	channel := &WebhookChannel{
+		plugin:  s.plugin,
+		config:  hook,
+		hook:    hook,
+		seen:    make(map[string]time.Time),
+		eventsC: make(chan force.Event, 10),
+	}
+	c.Assert(channel.Start(s.ctx), check.IsNil)
+	return channel
+}
+
+// post sends the request and returns the response status
+func (s *WebhookSuite) post(c *check.C, path, contentType, body string, headers map[string]string) int {
+	req, err := http.NewRequest(http.MethodPost, s.server.URL+path, strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", contentType)
+	for key, val := range headers {
+		req.Header.Set(key, val)
+	}
+	re, err := http.DefaultClient.Do(req)
+	c.Assert(err, check.IsNil)
+	re.Body.Close()
+	return re.StatusCode
+}
+
+// receive returns the event received by the channel
+func receive(c *check.C, channel *WebhookChannel) *WebhookEvent {
+	select {
+	case event := <-channel.Events():
+		return event.(*WebhookEvent)
+	case <-time.After(time.Second):
+		c.Fatalf("timeout waiting for event")
+		return nil
+	}
+}
+
+func (s *WebhookSuite) TestSignatures(c *check.C) {
+	github := s.start(c, Webhook{Path: "/github", Secret: "secret"})
+	legacy := s.start(c, Webhook{Path: "/legacy", Secret: "secret", Algorithm: "sha1"})
+	generic := s.start(c, Webhook{Path: "/generic", Secret: "secret", SignatureHeader: "X-Signature"})
+
+	body := `{"ref": "refs/heads/master"}`
+	status := s.post(c, "/github", "application/json", body, map[string]string{
+		"X-Hub-Signature-256": "sha256=" + github.hook.Sign([]byte(body), ""),
+	})
+	c.Assert(status, check.Equals, http.StatusAccepted)
+	c.Assert(string(receive(c, github).Body["ref"]), check.Equals, "refs/heads/master")
+
+	status = s.post(c, "/legacy", "application/json", body, map[string]string{
+		"X-Hub-Signature": "sha1=" + legacy.hook.Sign([]byte(body), ""),
+	})
+	c.Assert(status, check.Equals, http.StatusAccepted)
+	receive(c, legacy)
+
+	// sha256 signature is rejected by sha1 webhook
+	status = s.post(c, "/legacy", "application/json", body, map[string]string{
+		"X-Hub-Signature": "sha1=" + github.hook.Sign([]byte(body), ""),
+	})
+	c.Assert(status, check.Equals, http.StatusUnauthorized)
+
+	status = s.post(c, "/generic", "application/json", body, map[string]string{
+		"X-Signature": generic.hook.Sign([]byte(body), ""),
+	})
+	c.Assert(status, check.Equals, http.StatusAccepted)
+	receive(c, generic)
+
+	// signature of the other body is rejected
+	status = s.post(c, "/generic", "application/json", `{"ref": "refs/heads/dev"}`, map[string]string{
+		"X-Signature": generic.hook.Sign([]byte(body), ""),
+	})
+	c.Assert(status, check.Equals, http.StatusUnauthorized)
+
+	status = s.post(c, "/generic", "application/json", body, nil)
+	c.Assert(status, check.Equals, http.StatusUnauthorized)
+
+	status = s.post(c, "/missing", "application/json", body, nil)
+	c.Assert(status, check.Equals, http.StatusNotFound)
+	c.Assert(len(generic.Events()), check.Equals, 0)
+}
+
+func (s *WebhookSuite) TestTimestamps(c *check.C) {
+	hook := s.start(c, Webhook{
+		Path:            "/hook",
+		Secret:          "secret",
+		SignatureHeader: "X-Signature",
+		TimestampHeader: "X-Timestamp",
+		MaxAge:          time.Minute,
+	})
+	body := `{"status": "ok"}`
+	headers := func(t time.Time) map[string]string {
+		timestamp := strconv.FormatInt(t.Unix(), 10)
+		return map[string]string{
+			"X-Timestamp": timestamp,
+			"X-Signature": hook.hook.Sign([]byte(body), timestamp),
+		}
+	}
+
+	signed := headers(s.clock.Now())
+	c.Assert(s.post(c, "/hook", "application/json", body, signed), check.Equals, http.StatusAccepted)
+	receive(c, hook)
+
+	// replayed request is rejected
+	c.Assert(s.post(c, "/hook", "application/json", body, signed), check.Equals, http.StatusUnauthorized)
+
+	// timestamp is signed along with the body
+	replaced := headers(s.clock.Now().Add(time.Second))
+	replaced["X-Signature"] = signed["X-Signature"]
+	c.Assert(s.post(c, "/hook", "application/json", body, replaced), check.Equals, http.StatusUnauthorized)
+
+	c.Assert(s.post(c, "/hook", "application/json", body, headers(s.clock.Now().Add(-2*time.Minute))), check.Equals, http.StatusUnauthorized)
+	c.Assert(s.post(c, "/hook", "application/json", body, headers(s.clock.Now().Add(2*time.Minute))), check.Equals, http.StatusUnauthorized)
+
+	// request is accepted within the window
+	c.Assert(s.post(c, "/hook", "application/json", body, headers(s.clock.Now().Add(-30*time.Second))), check.Equals, http.StatusAccepted)
+	receive(c, hook)
+
+	// the remembered signatures expire
+	s.clock.Advance(3 * time.Minute)
+	c.Assert(s.post(c, "/hook", "application/json", body, headers(s.clock.Now())), check.Equals, http.StatusAccepted)
+	receive(c, hook)
+	c.Assert(len(hook.seen), check.Equals, 1)
+}
+
+func (s *WebhookSuite) TestDeliveries(c *check.C) {
+	github := s.start(c, Webhook{Path: "/github", Secret: "secret"})
+	insecure := s.start(c, Webhook{Path: "/insecure", Insecure: true})
+
+	body := `{"ref": "refs/heads/master"}`
+	headers := map[string]string{
+		"X-Hub-Signature-256": "sha256=" + github.hook.Sign([]byte(body), ""),
+		"X-GitHub-Delivery":   "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+	}
+	c.Assert(s.post(c, "/github", "application/json", body, headers), check.Equals, http.StatusAccepted)
+	receive(c, github)
+
+	// redelivered request is rejected
+	c.Assert(s.post(c, "/github", "application/json", body, headers), check.Equals, http.StatusUnauthorized)
+
+	// other deliveries of the same body are accepted
+	headers["X-GitHub-Delivery"] = "8ab3162e-cc78-11e3-81ab-4c9367dc0958"
+	c.Assert(s.post(c, "/github", "application/json", body, headers), check.Equals, http.StatusAccepted)
+	receive(c, github)
+
+	// deliveries are checked by the insecure webhooks too
+	c.Assert(s.post(c, "/insecure", "application/json", body, headers), check.Equals, http.StatusAccepted)
+	receive(c, insecure)
+	c.Assert(s.post(c, "/insecure", "application/json", body, headers), check.Equals, http.StatusUnauthorized)
+
+	// deliveries are remembered longer than the signatures
+	s.clock.Advance(2*DefaultMaxAge + time.Second)
+	c.Assert(s.post(c, "/github", "application/json", body, headers), check.Equals, http.StatusUnauthorized)
+
+	// the remembered deliveries expire
+	s.clock.Advance(DeliveryTTL)
+	c.Assert(s.post(c, "/github", "application/json", body, headers), check.Equals, http.StatusAccepted)
+	receive(c, github)
+	c.Assert(len(github.seen), check.Equals, 1)
+
+	// deliveries that have not been accepted could be retried
+	headers["X-GitHub-Delivery"] = "9cb3162e-cc78-11e3-81ab-4c9367dc0958"
+	eventsC := github.eventsC
+	github.eventsC = make(chan force.Event)
+	c.Assert(s.post(c, "/github", "application/json", body, headers), check.Equals, http.StatusServiceUnavailable)
+	github.eventsC = eventsC
+	c.Assert(s.post(c, "/github", "application/json", body, headers), check.Equals, http.StatusAccepted)
+	receive(c, github)
+
+	invalid := "{"
+	headers = map[string]string{
+		"X-Hub-Signature-256": "sha256=" + github.hook.Sign([]byte(invalid), ""),
+		"X-GitHub-Delivery":   "adb3162e-cc78-11e3-81ab-4c9367dc0958",
+	}
+	c.Assert(s.post(c, "/github", "application/json", invalid, headers), check.Equals, http.StatusBadRequest)
+	_, ok := github.seen[DeliveryHeader+":"+headers["X-GitHub-Delivery"]]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *WebhookSuite) TestEvent(c *check.C) {
+	hook := s.start(c, Webhook{Path: "/hook", Insecure: true})
+
+	body := `{"repository": {"full_name": "gravitational/force"}, "commits": [{"id": "abc", "distinct": true}], "size": 1, "before": null}`
+	c.Assert(s.post(c, "/hook?env=prod", "application/json", body, map[string]string{"X-GitHub-Event": "push"}), check.Equals, http.StatusAccepted)
+	event := receive(c, hook)
+	c.Assert(string(event.Method), check.Equals, http.MethodPost)
+	c.Assert(string(event.Path), check.Equals, "/hook")
+	c.Assert(event.Headers["X-Github-Event"], check.Equals, "push")
+	c.Assert(event.Query["env"], check.Equals, "prod")
+	c.Assert(event.Body, check.DeepEquals, map[string]string{
+		"repository.full_name": "gravitational/force",
+		"commits.0.id":         "abc",
+		"commits.0.distinct":   "true",
+		"size":                 "1",
+		"before":               "",
+	})
+	c.Assert(string(event.RawBody), check.Equals, body)
+
+	// JSON is decoded from the payload of the form
+	form := url.Values{"payload": []string{`{"action": "opened"}`}}.Encode()
+	c.Assert(s.post(c, "/hook", "application/x-www-form-urlencoded", form, nil), check.Equals, http.StatusAccepted)
+	c.Assert(receive(c, hook).Body, check.DeepEquals, map[string]string{"action": "opened"})
+
+	// JSON sent as a form is decoded
+	c.Assert(s.post(c, "/hook", "application/x-www-form-urlencoded", `{"version": "1.2.0"}`, nil), check.Equals, http.StatusAccepted)
+	c.Assert(receive(c, hook).Body, check.DeepEquals, map[string]string{"version": "1.2.0"})
+
+	// other bodies are not decoded
+	c.Assert(s.post(c, "/hook", "text/plain", "hello", nil), check.Equals, http.StatusAccepted)
+	event = receive(c, hook)
+	c.Assert(event.Body, check.HasLen, 0)
+	c.Assert(string(event.RawBody), check.Equals, "hello")
+
+	c.Assert(s.post(c, "/hook", "application/json", "{", nil), check.Equals, http.StatusBadRequest)
+
+	// event is restored from the journal
+	data, err := event.MarshalEvent()
+	c.Assert(err, check.IsNil)
+	restored, err := hook.UnmarshalEvent(s.ctx, data)
+	c.Assert(err, check.IsNil)
+	c.Assert(restored, check.DeepEquals, event)
+}
+
+func (s *WebhookSuite) TestCheckAndSetDefaults(c *check.C) {
+	hook := Webhook{Path: "/hook", Secret: "secret", Algorithm: "SHA1"}
+	c.Assert(hook.CheckAndSetDefaults(), check.IsNil)
+	c.Assert(hook.Algorithm, check.Equals, AlgorithmSHA1)
+	c.Assert(hook.SignatureHeader, check.Equals, "X-Hub-Signature")
+	c.Assert(hook.MaxAge, check.Equals, DefaultMaxAge)
+
+	for _, hook := range []Webhook{
+		{},
+		{Path: "hook", Secret: "secret"},
+		{Path: "/hook"},
+		{Path: "/hook", Secret: "secret", Insecure: true},
+		{Path: "/hook", Secret: "secret", Algorithm: "md5"},
+		{Path: "/hook", Secret: "secret", MaxAge: -time.Second},
+		{Path: "/hook", Insecure: true, TimestampHeader: "X-Timestamp"},
+	} {
+		c.Assert(hook.CheckAndSetDefaults(), check.NotNil, check.Commentf("%#v", hook))
+	}
+}
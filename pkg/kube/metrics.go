@@ -0,0 +1,10 @@
+package kube
+
+import (
+	"github.com/gravitational/force/pkg/metrics"
+)
+
+var jobOutcomes = metrics.NewCounter(
+	"force_kube_jobs_total",
+	"Jobs run by kube.Run, status is succeeded, failed or cancelled.",
+	"status")
@@ -42,6 +42,14 @@ func (r *RunAction) Type() interface{} {
 
 // Eval runs kubernetes job
 func (r *RunAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	ctx, span := force.StartSpan(ctx, "kube.Run")
+	out, err := r.run(ctx)
+	force.FinishSpan(span, err)
+	return out, err
+}
+
+// run creates the job, streams the logs and waits for the job to complete
+func (r *RunAction) run(ctx force.ExecutionContext) (interface{}, error) {
 	pluginI, ok := ctx.Process().Group().GetPlugin(Key)
 	if !ok {
 		return nil, trace.BadParameter("initialize kube plugin")
@@ -63,6 +71,21 @@ func (r *RunAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
 		return nil, trace.Wrap(err)
 	}
 	log.Infof("Created job %v in namespace %v.", spec.Name, spec.Namespace)
+	force.ActiveSpan(ctx).SetTag("job", job.Name)
+	// jobs of cancelled actions, for example timed out
+	// or superseded by a newer run, are deleted with their pods
+	defer func() {
+		if ctx.Err() == nil {
+			return
+		}
+		policy := metav1.DeletePropagationForeground
+		err := jobs.Delete(job.Name, &metav1.DeleteOptions{PropagationPolicy: &policy})
+		if err != nil {
+			log.WithError(err).Warningf("Failed to delete cancelled job %v.", job.Name)
+			return
+		}
+		log.Infof("Deleted cancelled job %v.", job.Name)
+	}()
 	writer := force.Writer(log.AddFields(map[string]interface{}{"job": job.Name}))
 	defer writer.Close()
 
@@ -86,11 +109,20 @@ func (r *RunAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
 
 	select {
 	case err := <-waitC:
+		switch {
+		case ctx.Err() != nil:
+			jobOutcomes.Inc("cancelled")
+		case err != nil:
+			jobOutcomes.Inc("failed")
+		default:
+			jobOutcomes.Inc("succeeded")
+		}
 		if err != nil {
 			return nil, trace.Wrap(err)
 		}
 		return 0, nil
 	case <-ctx.Done():
+		jobOutcomes.Inc("cancelled")
 		return nil, ctx.Err()
 	}
 }
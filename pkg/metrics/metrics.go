@@ -0,0 +1,251 @@
+// Package metrics implements counters, gauges and histograms
+// exported in the Prometheus text format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefaultBuckets are histogram buckets in seconds
+// suitable for the durations of the actions
+var DefaultBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+// registry is a registry of all metrics
+var registry = struct {
+	sync.Mutex
+	families map[string]*family
+}{families: make(map[string]*family)}
+
+// register registers a new metric family, panics
+// if the metric with the same name is registered
+func register(f *family) *family {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.families[f.name]; ok {
+		panic(fmt.Sprintf("metric %v is already registered", f.name))
+	}
+	f.series = make(map[string]*series)
+	registry.families[f.name] = f
+	return f
+}
+
+// family is a metric with all its labeled series
+type family struct {
+	sync.Mutex
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+// series is a metric value with a set of label values
+type series struct {
+	values []string
+	value  float64
+	// counts are cumulative counts of the histogram buckets
+	counts []uint64
+	count  uint64
+}
+
+// with returns the series with the label values, panics
+// if the number of values does not match the labels
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %v expects %v label values, got %v", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values, counts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+// NewCounter registers a new counter with the label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{f: register(&family{name: name, help: help, kind: typeCounter, labels: labels})}
+}
+
+// Counter is a metric that only goes up
+type Counter struct {
+	f *family
+}
+
+// Inc increments the counter with the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds the non-negative value to the counter with the label values
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.Lock()
+	defer c.f.Unlock()
+	c.f.with(values).value += v
+}
+
+// NewGauge registers a new gauge with the label names
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{f: register(&family{name: name, help: help, kind: typeGauge, labels: labels})}
+}
+
+// Gauge is a metric that could go up and down
+type Gauge struct {
+	f *family
+}
+
+// Set sets the gauge with the label values
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.Lock()
+	defer g.f.Unlock()
+	g.f.with(values).value = v
+}
+
+// Add adds the value to the gauge with the label values
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.Lock()
+	defer g.f.Unlock()
+	g.f.with(values).value += v
+}
+
+// NewHistogram registers a new histogram with the upper bounds
+// of the buckets sorted in increasing order and the label names
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{f: register(&family{name: name, help: help, kind: typeHistogram, labels: labels, buckets: buckets})}
+}
+
+// Histogram counts observed values in the buckets
+type Histogram struct {
+	f *family
+}
+
+// Observe adds the value to the histogram with the label values
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.Lock()
+	defer h.f.Unlock()
+	s := h.f.with(values)
+	for i, bound := range h.f.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// Handler returns HTTP handler serving all metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+}
+
+// Write writes all metrics in the Prometheus text format,
+// metrics are sorted by name
+func Write(w io.Writer) error {
+	registry.Lock()
+	families := make([]*family, 0, len(registry.families))
+	for _, f := range registry.families {
+		families = append(families, f)
+	}
+	registry.Unlock()
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	out := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(out)
+	}
+	return out.Flush()
+}
+
+// write writes the metric family, series are sorted by label values
+func (f *family) write(w io.Writer) {
+	f.Lock()
+	defer f.Unlock()
+	fmt.Fprintf(w, "# HELP %v %v\n", f.name, escape(f.help, false))
+	fmt.Fprintf(w, "# TYPE %v %v\n", f.name, f.kind)
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != typeHistogram {
+			fmt.Fprintf(w, "%v%v %v\n", f.name, formatLabels(f.labels, s.values), formatFloat(s.value))
+			continue
+		}
+		labels := concat(f.labels, "le")
+		for i, bound := range f.buckets {
+			fmt.Fprintf(w, "%v_bucket%v %v\n", f.name,
+				formatLabels(labels, concat(s.values, formatFloat(bound))), s.counts[i])
+		}
+		fmt.Fprintf(w, "%v_bucket%v %v\n", f.name,
+			formatLabels(labels, concat(s.values, "+Inf")), s.count)
+		fmt.Fprintf(w, "%v_sum%v %v\n", f.name, formatLabels(f.labels, s.values), formatFloat(s.value))
+		fmt.Fprintf(w, "%v_count%v %v\n", f.name, formatLabels(f.labels, s.values), s.count)
+	}
+}
+
+// concat returns a new slice with the value appended
+func concat(values []string, value string) []string {
+	out := make([]string, len(values), len(values)+1)
+	copy(out, values)
+	return append(out, value)
+}
+
+// formatLabels formats label pairs, e.g. {process="ci"}
+func formatLabels(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i := range labels {
+		pairs[i] = fmt.Sprintf(`%v="%v"`, labels[i], escape(values[i], true))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatFloat formats the value the way Prometheus expects it
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// escape escapes backslashes and new lines,
+// quotes are escaped in the label values
+func escape(s string, quotes bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if quotes {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+	return s
+}
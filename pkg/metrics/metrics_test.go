@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+// Bootstrap check
+func Test(t *testing.T) { check.TestingT(t) }
+
+type MetricsSuite struct{}
+
+var _ = check.Suite(&MetricsSuite{})
+
+// output returns the lines of the metric in the exported metrics
+func output(c *check.C, name string) []string {
+	buf := &bytes.Buffer{}
+	c.Assert(Write(buf), check.IsNil)
+	var out []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, name) || strings.HasPrefix(line, "# HELP "+name+" ") || strings.HasPrefix(line, "# TYPE "+name+" ") {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+func (s *MetricsSuite) TestCounterAndGauge(c *check.C) {
+	counter := NewCounter("test_events_total", "Events received.", "process")
+	counter.Inc("b")
+	counter.Inc("a")
+	counter.Add(2, "a")
+	// counters never go down
+	counter.Add(-1, "a")
+	c.Assert(output(c, "test_events_total"), check.DeepEquals, []string{
+		"# HELP test_events_total Events received.",
+		"# TYPE test_events_total counter",
+		`test_events_total{process="a"} 3`,
+		`test_events_total{process="b"} 1`,
+	})
+
+	gauge := NewGauge("test_queue_depth", "Queue depth.")
+	gauge.Set(3)
+	gauge.Add(-1)
+	c.Assert(output(c, "test_queue_depth"), check.DeepEquals, []string{
+		"# HELP test_queue_depth Queue depth.",
+		"# TYPE test_queue_depth gauge",
+		"test_queue_depth 2",
+	})
+
+	// label values are escaped
+	escaped := NewCounter("test_escaped_total", "Escaped\nhelp.", "name")
+	escaped.Inc(`a"b\`)
+	c.Assert(output(c, "test_escaped_total"), check.DeepEquals, []string{
+		`# HELP test_escaped_total Escaped\nhelp.`,
+		"# TYPE test_escaped_total counter",
+		`test_escaped_total{name="a\"b\\"} 1`,
+	})
+}
+
+func (s *MetricsSuite) TestHistogram(c *check.C) {
+	h := NewHistogram("test_duration_seconds", "Durations.", []float64{1, 5}, "status")
+	h.Observe(0.5, "ok")
+	h.Observe(3, "ok")
+	h.Observe(10, "ok")
+	c.Assert(output(c, "test_duration_seconds"), check.DeepEquals, []string{
+		"# HELP test_duration_seconds Durations.",
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{status="ok",le="1"} 1`,
+		`test_duration_seconds_bucket{status="ok",le="5"} 2`,
+		`test_duration_seconds_bucket{status="ok",le="+Inf"} 3`,
+		`test_duration_seconds_sum{status="ok"} 13.5`,
+		`test_duration_seconds_count{status="ok"} 3`,
+	})
+}
@@ -0,0 +1,494 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestCron(c *check.C) {
+	parseChannel := func(code string) (force.Channel, error) {
+		return parseChannel(c, code)
+	}
+	stateFile := filepath.Join(c.MkDir(), "cron")
+	code := `Cron("* * * * *", CronOptions{Timezone: "Europe/Berlin", Jitter: "1m", CatchUpMissed: true, StateFile: "` + stateFile + `"})`
+	channel, err := parseChannel(code)
+	c.Assert(err, check.IsNil)
+
+	// the channel is marshaled back into Cron call
+	data, err := force.MarshalCode(force.EmptyContext(), channel)
+	c.Assert(err, check.IsNil)
+	_, err = parseChannel(string(data))
+	c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+
+	// the latest schedule missed since the recorded fire time
+	// is caught up right away and the fire time is recorded
+	last := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Minute)
+	c.Assert(ioutil.WriteFile(stateFile, []byte(last.Format(time.RFC3339)+"\n"), 0600), check.IsNil)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	c.Assert(channel.Start(ctx), check.IsNil)
+	var event *force.CronEvent
+	select {
+	case e := <-channel.Events():
+		event = e.(*force.CronEvent)
+	case <-time.After(5 * time.Second):
+		c.Fatalf("timeout waiting for the missed event")
+	}
+	c.Assert(bool(event.Missed), check.Equals, true)
+	scheduled := time.Time(event.Scheduled)
+	c.Assert(scheduled.After(last), check.Equals, true)
+	c.Assert(scheduled.Before(time.Now()), check.Equals, true)
+	c.Assert(scheduled.Location().String(), check.Equals, "Europe/Berlin")
+	cancel()
+
+	// events are restored from the journal
+	data, err = event.MarshalEvent()
+	c.Assert(err, check.IsNil)
+	restored, err := channel.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+	c.Assert(err, check.IsNil)
+	c.Assert(restored.Created().Equal(scheduled), check.Equals, true)
+
+	// the scheduled time is available in the process
+	g := newTestParser(c)
+	_, err = parseExpr(g, `Process(Spec{Watch: Cron("@daily"), Run: func(){ Infof("Scheduled at %v", event.Scheduled) }})`)
+	c.Assert(err, check.IsNil)
+
+	errCases := []string{
+		`Cron("0 3 * *")`,
+		`Cron("0 3 * * 1-5", CronOptions{Timezone: "Mars/Olympus"})`,
+		`Cron("0 3 * * 1-5", CronOptions{CatchUpMissed: true})`,
+		`Cron("0 3 * * 1-5", CronOptions{}, CronOptions{})`,
+		`Cron(1)`,
+	}
+	for i, code := range errCases {
+		_, err := parseChannel(code)
+		c.Assert(err, check.NotNil, check.Commentf("test case %v %v", i, code))
+	}
+}
+
+func (s *ParserSuite) TestFilterMap(c *check.C) {
+	// missedCron returns a cron channel catching up the missed schedule
+	// right away, so the test does not wait for the schedule
+	missedCron := func() string {
+		stateFile := filepath.Join(c.MkDir(), "cron")
+		last := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Minute)
+		c.Assert(ioutil.WriteFile(stateFile, []byte(last.Format(time.RFC3339)+"\n"), 0600), check.IsNil)
+		return `Cron("* * * * *", CronOptions{CatchUpMissed: true, StateFile: "` + stateFile + `"})`
+	}
+	receive := func(channel force.Channel, timeout time.Duration) force.Event {
+		select {
+		case e := <-channel.Events():
+			return e
+		case <-time.After(timeout):
+			return nil
+		}
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	// events matching the predicate are passed
+	channel, err := parseChannel(c, `Filter(`+missedCron()+`, func() bool { return event.Missed })`)
+	c.Assert(err, check.IsNil)
+	c.Assert(channel.Start(ctx), check.IsNil)
+	event := receive(channel, 5*time.Second)
+	c.Assert(event, check.FitsTypeOf, &force.CronEvent{})
+
+	// the other events are dropped
+	channel, err = parseChannel(c, `Filter(`+missedCron()+`, func() bool { return event.Missed == false })`)
+	c.Assert(err, check.IsNil)
+	c.Assert(channel.Start(ctx), check.IsNil)
+	c.Assert(receive(channel, 300*time.Millisecond), check.IsNil)
+
+	// events are converted by the function
+	channel, err = parseChannel(c, `Map(`+missedCron()+`, func() string { return FormatTime(event.Scheduled, "2006-01-02T15:04") })`)
+	c.Assert(err, check.IsNil)
+	c.Assert(channel.Start(ctx), check.IsNil)
+	event = receive(channel, 5*time.Second)
+	c.Assert(event, check.FitsTypeOf, &force.MappedEvent{})
+	mapped := event.(*force.MappedEvent)
+	scheduled := mapped.Event.(*force.CronEvent).Scheduled
+	c.Assert(fmt.Sprintf("%v", mapped.Value), check.Equals, time.Time(scheduled).Format("2006-01-02T15:04"))
+
+	// mapped events are restored from the journal and converted again
+	data, err := mapped.MarshalEvent()
+	c.Assert(err, check.IsNil)
+	restored, err := channel.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+	c.Assert(err, check.IsNil)
+	c.Assert(restored.(*force.MappedEvent).Value, check.DeepEquals, mapped.Value)
+
+	// channels are marshaled back into Filter and Map calls
+	for _, code := range []string{
+		`Filter(Cron("@daily"), func() bool { return event.Missed })`,
+		`Map(Cron("@daily"), func() string { return "daily" })`,
+	} {
+		channel, err := parseChannel(c, code)
+		c.Assert(err, check.IsNil)
+		data, err := force.MarshalCode(force.EmptyContext(), channel)
+		c.Assert(err, check.IsNil)
+		_, err = parseChannel(c, string(data))
+		c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+	}
+
+	// the result of Map is the event of the process
+	g := newTestParser(c)
+	_, err = parseExpr(g, `Process(Spec{
+		Watch: Filter(Map(Cron("@daily"), func() string { return FormatTime(event.Scheduled, "Monday") }), func() bool { return event != "Sunday" }),
+		Run: func(){ Infof("Today is %v", event) },
+	})`)
+	c.Assert(err, check.IsNil)
+
+	errCases := []string{
+		// predicate should return bool
+		`Filter(Cron("@daily"), func() string { return "yes" })`,
+		// functions do not accept arguments
+		`Filter(Cron("@daily"), func(e string) bool { return true })`,
+		`Map(Cron("@daily"), func(e string) string { return e })`,
+		// function should return a value
+		`Map(Cron("@daily"), func() { Infof("no value") })`,
+		// unknown event field
+		`Filter(Cron("@daily"), func() bool { return event.Branch == "master" })`,
+		`Filter(Cron("@daily"), "yes")`,
+	}
+	for i, code := range errCases {
+		_, err := parseChannel(c, code)
+		c.Assert(err, check.NotNil, check.Commentf("test case %v %v", i, code))
+	}
+}
+
+func (s *ParserSuite) TestDebounceThrottleBatch(c *check.C) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	// collect returns the events received until the timeout
+	collect := func(channel force.Channel, timeout time.Duration) []force.Event {
+		var out []force.Event
+		deadline := time.After(timeout)
+		for {
+			select {
+			case e := <-channel.Events():
+				out = append(out, e)
+			case <-deadline:
+				return out
+			}
+		}
+	}
+	tick := func(i int) force.Event {
+		return &force.TickEvent{Time: time.Date(2019, 7, 1, 0, 0, i, 0, time.UTC)}
+	}
+
+	// the burst of events is debounced into the last event
+	in := newTestChannel()
+	channel, err := force.Debounce(in, force.String("100ms"))
+	c.Assert(err, check.IsNil)
+	c.Assert(channel.Start(ctx), check.IsNil)
+	for i := 0; i < 5; i++ {
+		in.eventsC <- tick(i)
+		time.Sleep(10 * time.Millisecond)
+	}
+	events := collect(channel, 400*time.Millisecond)
+	c.Assert(events, check.DeepEquals, []force.Event{tick(4)})
+
+	// the first event is sent right away, and the last one
+	// when the period ends, the rest are dropped
+	in = newTestChannel()
+	channel, err = force.Throttle(in, force.String("200ms"))
+	c.Assert(err, check.IsNil)
+	c.Assert(channel.Start(ctx), check.IsNil)
+	for i := 0; i < 5; i++ {
+		in.eventsC <- tick(i)
+	}
+	c.Assert(collect(channel, 100*time.Millisecond), check.DeepEquals, []force.Event{tick(0)})
+	c.Assert(collect(channel, 300*time.Millisecond), check.DeepEquals, []force.Event{tick(4)})
+
+	// the batch is sent once it is full, or when the window ends
+	in = newTestChannel()
+	channel, err = force.Batch(in, force.BatchConfig{Max: 3, Window: 200 * time.Millisecond})
+	c.Assert(err, check.IsNil)
+	c.Assert(channel.Start(ctx), check.IsNil)
+	for i := 0; i < 5; i++ {
+		in.eventsC <- tick(i)
+	}
+	events = collect(channel, 100*time.Millisecond)
+	c.Assert(events, check.HasLen, 1)
+	c.Assert(events[0].(*force.BatchEvent).Events, check.DeepEquals, []force.Event{tick(0), tick(1), tick(2)})
+	events = collect(channel, 300*time.Millisecond)
+	c.Assert(events, check.HasLen, 1)
+	batch := events[0].(*force.BatchEvent)
+	c.Assert(batch.Events, check.DeepEquals, []force.Event{tick(3), tick(4)})
+
+	// events without definition are listed as strings
+	execCtx := force.NewContext(force.ContextConfig{Parent: &force.WrapContext{Context: ctx}, Event: batch})
+	batch.AddMetadata(execCtx)
+	value := execCtx.Value(force.ContextKey(force.KeyEvent)).(force.BatchValue)
+	c.Assert(value.Count, check.Equals, force.Int(2))
+	c.Assert(value.Events, check.DeepEquals, []force.String{force.String(fmt.Sprintf("%v", tick(3))), force.String(fmt.Sprintf("%v", tick(4)))})
+
+	// batched events are listed in the process with the type of the event
+	// of the batched channel, and are restored from the journal
+	channel, err = parseChannel(c, `Batch(Cron("@daily"), BatchConfig{Max: 10, Window: "1m"})`)
+	c.Assert(err, check.IsNil)
+	scheduled := time.Date(2019, 7, 1, 3, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(map[string]interface{}{
+		"created": scheduled,
+		"events":  []interface{}{map[string]interface{}{"scheduled": scheduled}, map[string]interface{}{"scheduled": scheduled, "missed": true}},
+	})
+	c.Assert(err, check.IsNil)
+	restored, err := channel.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+	c.Assert(err, check.IsNil)
+	execCtx = force.NewContext(force.ContextConfig{Parent: &force.WrapContext{Context: ctx}, Event: restored})
+	restored.AddMetadata(execCtx)
+	value = execCtx.Value(force.ContextKey(force.KeyEvent)).(force.BatchValue)
+	c.Assert(value.Count, check.Equals, force.Int(2))
+	cronEvents := value.Events.([]force.CronEvent)
+	c.Assert(time.Time(cronEvents[0].Scheduled).Equal(scheduled), check.Equals, true)
+	c.Assert(bool(cronEvents[1].Missed), check.Equals, true)
+	data, err = restored.(force.SerializableEvent).MarshalEvent()
+	c.Assert(err, check.IsNil)
+	_, err = channel.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+	c.Assert(err, check.IsNil)
+
+	// channels are marshaled back into function calls
+	for _, code := range []string{
+		`Debounce(Cron("@daily"), "2s")`,
+		`Throttle(Cron("@daily"), "1m")`,
+		`Batch(Cron("@daily"), BatchConfig{Max: 10, Window: "1m"})`,
+	} {
+		channel, err := parseChannel(c, code)
+		c.Assert(err, check.IsNil)
+		data, err := force.MarshalCode(force.EmptyContext(), channel)
+		c.Assert(err, check.IsNil)
+		_, err = parseChannel(c, string(data))
+		c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+	}
+
+	// events of the channels are used in the process
+	for _, code := range []string{
+		`Process(Spec{Watch: Debounce(Cron("@daily"), "2s"), Run: func(){ Infof("%v", event.Scheduled) }})`,
+		`Process(Spec{Watch: Throttle(Cron("@daily"), "1m"), Run: func(){ Infof("%v", event.Scheduled) }})`,
+		`Process(Spec{Watch: Batch(Cron("@daily"), BatchConfig{Window: "1m"}), Run: func(){
+			Infof("%v events", event.Count)
+			for _, e := range event.Events {
+				Infof("%v", e.Scheduled)
+			}
+		}})`,
+		`Process(Spec{Watch: Batch(Files("."), BatchConfig{Window: "1m"}), Run: func(){
+			for _, e := range event.Events {
+				Infof("%v", e)
+			}
+		}})`,
+	} {
+		g := newTestParser(c)
+		_, err = parseExpr(g, code)
+		c.Assert(err, check.IsNil, check.Commentf("%v", code))
+	}
+
+	errCases := []string{
+		`Debounce(Cron("@daily"), "")`,
+		`Debounce(Cron("@daily"), "-1s")`,
+		`Throttle(Cron("@daily"), 1)`,
+		`Batch(Cron("@daily"), BatchConfig{Max: 10})`,
+		`Batch(Cron("@daily"), BatchConfig{Max: -1, Window: "1m"})`,
+	}
+	for i, code := range errCases {
+		_, err := parseChannel(c, code)
+		c.Assert(err, check.NotNil, check.Commentf("test case %v %v", i, code))
+	}
+}
+
+// testChannel is a channel sending events written by the test
+type testChannel struct {
+	eventsC chan force.Event
+}
+
+// newTestChannel returns a new test channel
+func newTestChannel() *testChannel {
+	return &testChannel{eventsC: make(chan force.Event, 1024)}
+}
+
+// Start does nothing, the events are written by the test
+func (t *testChannel) Start(ctx context.Context) error {
+	return nil
+}
+
+// Events returns the events written by the test
+func (t *testChannel) Events() <-chan force.Event {
+	return t.eventsC
+}
+
+// Done returns nil, test channel never completes
+func (t *testChannel) Done() <-chan struct{} {
+	return nil
+}
+
+// MarshalCode is not supported by the test channel
+func (t *testChannel) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	return nil, trace.NotImplemented("test channel can not be marshaled")
+}
+
+func (s *ParserSuite) TestCompletedJoin(c *check.C) {
+	g := newTestParser(c)
+	parseChannel := func(code string) (force.Channel, error) {
+		f := token.NewFileSet()
+		expr, err := parser.ParseExprFrom(f, "", []byte(code), 0)
+		c.Assert(err, check.IsNil)
+		out, err := g.parseExpr(f, g.runner, expr)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return out.(force.Channel), nil
+	}
+	newProcess := func(name, code string) *LocalProcess {
+		run, err := parseExpr(g, code)
+		c.Assert(err, check.IsNil)
+		proc, err := NewLocalProcess(context.TODO(), g.runner.Logger(), force.Spec{
+			Name:  force.String(name),
+			Run:   run,
+			Group: g.runner,
+		})
+		c.Assert(err, check.IsNil)
+		return proc
+	}
+	execCtx := force.WithRuntimeScope(g.scope)
+	execute := func(proc *LocalProcess, commit string) {
+		e, err := proc.newExecution(execCtx, &testCommitEvent{commit: commit})
+		c.Assert(err, check.IsNil)
+		proc.execute(e)
+	}
+	receive := func(channel force.Channel) force.Event {
+		select {
+		case e := <-channel.Events():
+			return e
+		case <-time.After(5 * time.Second):
+			c.Fatalf("timeout waiting for the event of %v", channel)
+			return nil
+		}
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	completed, err := parseChannel(`Completed("unit")`)
+	c.Assert(err, check.IsNil)
+	c.Assert(completed.Start(ctx), check.IsNil)
+	join, err := parseChannel(`Join(Completed("unit"), Completed("integration"))`)
+	c.Assert(err, check.IsNil)
+	c.Assert(join.Start(ctx), check.IsNil)
+
+	unit := newProcess("unit", `func(){ Command("true") }`)
+	integration := newProcess("integration", `func(){ Command("false") }`)
+
+	// completion event has the status and the commit of the run
+	execute(unit, "a")
+	event := receive(completed).(*force.CompletedEvent)
+	c.Assert(string(event.Process), check.Equals, "unit")
+	c.Assert(string(event.Status), check.Equals, force.CompletedSucceeded)
+	c.Assert(string(event.Commit), check.Equals, "a")
+	c.Assert(event.ID, check.Not(check.Equals), force.String(""))
+
+	// runs are joined by the commit of the triggering event,
+	// the join fails if any of the runs has failed
+	execute(integration, "b")
+	execute(unit, "b")
+	joinEvent := receive(join).(*force.JoinEvent)
+	c.Assert(string(joinEvent.Key), check.Equals, "b")
+	c.Assert(bool(joinEvent.Succeeded), check.Equals, false)
+	c.Assert(joinEvent.Completed, check.HasLen, 2)
+	c.Assert(string(joinEvent.Completed[0].Process), check.Equals, "unit")
+	c.Assert(string(joinEvent.Completed[1].Status), check.Equals, force.CompletedFailed)
+	c.Assert(string(joinEvent.Completed[1].Error), check.Not(check.Equals), "")
+
+	integration.action, err = parseExpr(g, `func(){ Command("true") }`)
+	c.Assert(err, check.IsNil)
+	execute(integration, "a")
+	joinEvent = receive(join).(*force.JoinEvent)
+	c.Assert(string(joinEvent.Key), check.Equals, "a")
+	c.Assert(bool(joinEvent.Succeeded), check.Equals, true)
+
+	// events are restored from the journal
+	data, err := joinEvent.MarshalEvent()
+	c.Assert(err, check.IsNil)
+	restored, err := join.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+	c.Assert(err, check.IsNil)
+	c.Assert(restored.(*force.JoinEvent).Completed, check.DeepEquals, joinEvent.Completed)
+	data, err = event.MarshalEvent()
+	c.Assert(err, check.IsNil)
+	restored, err = completed.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+	c.Assert(err, check.IsNil)
+	c.Assert(restored, check.DeepEquals, event)
+
+	// completion events are queued, not dropped, if the channel is not read
+	queued, err := parseChannel(`Completed("queued")`)
+	c.Assert(err, check.IsNil)
+	c.Assert(queued.Start(ctx), check.IsNil)
+	completions, err := force.GetCompletions(g.runner)
+	c.Assert(err, check.IsNil)
+	count := force.DefaultChannelBufferSize + 10
+	for i := 0; i < count; i++ {
+		completions.Publish(ctx, force.NewCompletedEvent("queued", fmt.Sprintf("%v", i), &testCommitEvent{commit: "c"}, nil))
+	}
+	for i := 0; i < count; i++ {
+		c.Assert(string(receive(queued).(*force.CompletedEvent).ID), check.Equals, fmt.Sprintf("%v", i))
+	}
+
+	// channels are marshaled back into function calls
+	for _, channel := range []force.Channel{completed, join} {
+		data, err := force.MarshalCode(force.EmptyContext(), channel)
+		c.Assert(err, check.IsNil)
+		_, err = parseChannel(string(data))
+		c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+	}
+
+	// events are used in the processes
+	for _, code := range []string{
+		`Process(Spec{Watch: Completed("unit", "integration"), Run: func(){ Infof("%v has %v", event.Process, event.Status) }})`,
+		`Process(Spec{Watch: Join(Completed("unit"), Completed("integration")), Run: func(){
+			If(event.Succeeded, Command("make deploy"))
+			for _, run := range event.Completed {
+				Infof("%v %v", run.Process, run.Status)
+			}
+		}})`,
+		`Process(Spec{Watch: Filter(Join(Completed("unit"), Completed("integration")), func() bool { return event.Succeeded }), Run: func(){ Infof("Deploying %v", event.Key) }})`,
+	} {
+		_, err = parseExpr(newTestParser(c), code)
+		c.Assert(err, check.IsNil, check.Commentf("%v", code))
+	}
+
+	errCases := []string{
+		`Completed()`,
+		`Completed("")`,
+		`Completed(1)`,
+		`Join(Completed("unit"))`,
+	}
+	for i, code := range errCases {
+		_, err := parseChannel(code)
+		c.Assert(err, check.NotNil, check.Commentf("test case %v %v", i, code))
+	}
+}
+
+// testCommitEvent is an event associated with a commit
+type testCommitEvent struct {
+	commit string
+}
+
+// AddMetadata does nothing
+func (e *testCommitEvent) AddMetadata(ctx force.ExecutionContext) {
+}
+
+// Created returns zero time
+func (e *testCommitEvent) Created() time.Time {
+	return time.Time{}
+}
+
+// GetCommit returns the commit of the event
+func (e *testCommitEvent) GetCommit() string {
+	return e.commit
+}
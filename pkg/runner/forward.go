@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/gravitational/force"
+)
+
+// queuePolicy is implemented by the processes
+// that set the size and the overflow mode of their queues
+type queuePolicy interface {
+	// queuePolicy returns the queue size and the overflow mode
+	queuePolicy() (int, string)
+}
+
+// newForwarder returns a new forwarder of the events to the process,
+// the forwarder queue has the size and the overflow mode of the process queue
+func newForwarder(proc force.Process) *forwarder {
+	size, overflow := force.DefaultQueueSize, force.OverflowDrop
+	if p, ok := proc.(queuePolicy); ok {
+		size, overflow = p.queuePolicy()
+	}
+	return &forwarder{
+		proc:     proc,
+		overflow: overflow,
+		eventsC:  make(chan force.Event, size),
+	}
+}
+
+// forwarder forwards events to the process in the order they were sent,
+// events wait in the forwarder until the process receives them
+type forwarder struct {
+	proc     force.Process
+	overflow string
+	// eventsC holds the events waiting to be received by the process
+	eventsC chan force.Event
+}
+
+// push adds the event to the events waiting for the process, in block mode
+// waits until there is space, otherwise never blocks, returns the event
+// dropped because of the overflow, returns false if the context is done
+func (f *forwarder) push(ctx context.Context, event force.Event) (force.Event, bool) {
+	switch f.overflow {
+	case force.OverflowBlock:
+		select {
+		case f.eventsC <- event:
+			return nil, true
+		case <-f.proc.Done():
+			return nil, true
+		case <-ctx.Done():
+			return nil, false
+		}
+	case force.OverflowReplaceOldest:
+		var dropped force.Event
+		for {
+			select {
+			case f.eventsC <- event:
+				return dropped, true
+			default:
+			}
+			// forwarder is full, the process could have
+			// received the oldest event in the meantime
+			select {
+			case dropped = <-f.eventsC:
+			default:
+			}
+		}
+	default:
+		select {
+		case f.eventsC <- event:
+			return nil, true
+		default:
+			return event, true
+		}
+	}
+}
+
+// run sends the waiting events to the process until
+// the process or the context is done
+func (f *forwarder) run(ctx context.Context, log force.Logger) {
+	for {
+		var event force.Event
+		select {
+		case event = <-f.eventsC:
+		case <-f.proc.Done():
+			return
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case f.proc.Events() <- event:
+			log.Debugf("%v triggered by %v", f.proc, event)
+		case <-f.proc.Done():
+			log.Debugf("%v has exited, skipping %v", f.proc, event)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"gopkg.in/check.v1"
+)
+
+// TestFanOut checks that the blocked process does not
+// hold up delivery of the events to the other processes
+func (s *ParserSuite) TestFanOut(c *check.C) {
+	g := newTestParser(c)
+	defer g.runner.cancel()
+	logger := g.runner.Logger()
+	spec := force.Spec{Run: force.Exit()}
+	// processes are not started, so the events are received by the test
+	blocked, err := NewLocalProcess(g.runner.ctx, logger, spec)
+	c.Assert(err, check.IsNil)
+	ready, err := NewLocalProcess(g.runner.ctx, logger, spec)
+	c.Assert(err, check.IsNil)
+	g.runner.processes = []force.Process{blocked, ready}
+
+	events := []force.Event{
+		&force.OneshotEvent{Time: time.Unix(1, 0)},
+		&force.OneshotEvent{Time: time.Unix(2, 0)},
+	}
+	for _, event := range events {
+		c.Assert(g.runner.sendEvent(event), check.Equals, true)
+	}
+	receive := func(proc *LocalProcess) force.Event {
+		select {
+		case event := <-proc.eventsC:
+			return event
+		case <-time.After(time.Second):
+			c.Fatalf("timeout waiting for event")
+			return nil
+		}
+	}
+	for _, event := range events {
+		c.Assert(receive(ready), check.Equals, event)
+	}
+	for _, event := range events {
+		c.Assert(receive(blocked), check.Equals, event)
+	}
+
+	// forwarder is removed with the process
+	c.Assert(g.runner.forwarders, check.HasLen, 2)
+	blocked.cancel()
+	c.Assert(g.runner.remove(blocked), check.Equals, true)
+	c.Assert(g.runner.forwarders, check.HasLen, 1)
+}
+
+// TestForwarderOverflow checks that forwarders are bounded
+// by the process queue size and apply the overflow mode
+func (s *ParserSuite) TestForwarderOverflow(c *check.C) {
+	g := newTestParser(c)
+	defer g.runner.cancel()
+	events := []force.Event{
+		&force.OneshotEvent{Time: time.Unix(1, 0)},
+		&force.OneshotEvent{Time: time.Unix(2, 0)},
+	}
+	newForwarderWith := func(overflow string) *forwarder {
+		proc, err := NewLocalProcess(g.runner.ctx, g.runner.Logger(), force.Spec{
+			Run:       force.Exit(),
+			QueueSize: 1,
+			Overflow:  force.String(overflow),
+		})
+		c.Assert(err, check.IsNil)
+		return newForwarder(proc)
+	}
+
+	f := newForwarderWith(force.OverflowDrop)
+	dropped, ok := f.push(context.TODO(), events[0])
+	c.Assert(ok, check.Equals, true)
+	c.Assert(dropped, check.IsNil)
+	dropped, ok = f.push(context.TODO(), events[1])
+	c.Assert(ok, check.Equals, true)
+	c.Assert(dropped, check.Equals, events[1])
+	c.Assert(<-f.eventsC, check.Equals, events[0])
+
+	f = newForwarderWith(force.OverflowReplaceOldest)
+	f.push(context.TODO(), events[0])
+	dropped, ok = f.push(context.TODO(), events[1])
+	c.Assert(ok, check.Equals, true)
+	c.Assert(dropped, check.Equals, events[0])
+	c.Assert(<-f.eventsC, check.Equals, events[1])
+
+	// block mode waits for space in the forwarder
+	f = newForwarderWith(force.OverflowBlock)
+	f.push(context.TODO(), events[0])
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	_, ok = f.push(ctx, events[1])
+	c.Assert(ok, check.Equals, false)
+	c.Assert(f.eventsC, check.HasLen, 1)
+}
@@ -0,0 +1,470 @@
+package runner
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/gravitational/trace"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// historyBucket is a name of the bolt bucket with the runs
+	historyBucket = []byte("runs")
+	// logsBucket is a name of the bolt bucket with the captured logs,
+	// logs are kept apart from the runs, so listing runs does not read them
+	logsBucket = []byte("logs")
+	// startedBucket is a name of the bolt bucket indexing
+	// the runs by the start time, so the oldest runs are pruned first
+	startedBucket = []byte("started")
+)
+
+const (
+	// RunRunning is a status of the run in progress
+	RunRunning = "running"
+	// RunSucceeded is a status of the successful run
+	RunSucceeded = "succeeded"
+	// RunFailed is a status of the failed run
+	RunFailed = "failed"
+	// RunSuperseded is a status of the run cancelled by a newer run
+	RunSuperseded = "superseded"
+	// MaxCapturedLog is a maximum size of the log captured for the run,
+	// only the last lines are kept for longer logs
+	MaxCapturedLog = 256 * 1024
+	// DefaultHistoryMaxRuns is a default maximum number of runs kept in the history
+	DefaultHistoryMaxRuns = 10000
+	// DefaultHistoryMaxAge is a default maximum age of runs kept in the history
+	DefaultHistoryMaxAge = 30 * 24 * time.Hour
+	// historyPruneInterval is a period of pruning the runs over the limits
+	historyPruneInterval = time.Minute
+)
+
+// Run is a record of the process execution
+type Run struct {
+	// ID is a unique execution id
+	ID string `json:"id"`
+	// Process is a name of the process
+	Process string `json:"process"`
+	// Event is a summary of the event that triggered the run
+	Event string `json:"event"`
+	// Status is a status of the run
+	Status string `json:"status"`
+	// Started is a time when the run has started
+	Started time.Time `json:"started"`
+	// Duration is a duration of the completed run
+	Duration time.Duration `json:"duration"`
+	// Error is an error the run has failed with
+	Error string `json:"error,omitempty"`
+	// Log is a log output captured during the run
+	Log string `json:"log,omitempty"`
+}
+
+// HistoryFilter filters runs
+type HistoryFilter struct {
+	// Process filters runs by the process name
+	Process string
+	// Failed filters out runs that have not failed
+	Failed bool
+	// Event filters runs by the text in the event summary,
+	// for example a commit or a branch name
+	Event string
+}
+
+// Match returns true if the run matches the filter
+func (f HistoryFilter) Match(r Run) bool {
+	if f.Process != "" && f.Process != r.Process {
+		return false
+	}
+	if f.Failed && r.Status != RunFailed {
+		return false
+	}
+	if f.Event != "" && !strings.Contains(r.Event, f.Event) {
+		return false
+	}
+	return true
+}
+
+// NewHistory returns the execution history stored in the database file
+// for reading, the database is opened only for the time of the operation,
+// use OpenHistory to record runs
+func NewHistory(path string) (*History, error) {
+	if path == "" {
+		return nil, trace.BadParameter("missing history database path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	return &History{
+		path:    path,
+		MaxRuns: DefaultHistoryMaxRuns,
+		MaxAge:  DefaultHistoryMaxAge,
+	}, nil
+}
+
+// OpenHistory opens the execution history database for recording runs,
+// the database is kept open until the history is closed, runs left running
+// by force that has not exited gracefully are marked as failed
+func OpenHistory(path string) (*History, error) {
+	h, err := NewHistory(path)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{historyBucket, logsBucket, startedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return failInterrupted(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, trace.Wrap(err)
+	}
+	h.db = db
+	return h, nil
+}
+
+// History is an execution history
+type History struct {
+	// mutex serializes access to the database
+	// within the process, as bolt locks the file
+	mutex sync.Mutex
+	path  string
+	// db is the database open for recording runs
+	db *bolt.DB
+	// pruned is the last time the runs were pruned
+	pruned time.Time
+	// MaxRuns is a maximum number of runs kept in the history,
+	// the oldest runs are pruned as new runs are recorded
+	MaxRuns int
+	// MaxAge is a maximum age of runs kept in the history
+	MaxAge time.Duration
+}
+
+// Close closes the database open for recording runs
+func (h *History) Close() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.db == nil {
+		return nil
+	}
+	err := h.db.Close()
+	h.db = nil
+	return trace.Wrap(err)
+}
+
+// update runs the read-write transaction
+func (h *History) update(fn func(*bolt.Tx) error) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.db == nil {
+		return trace.BadParameter("history %v is not open for recording", h.path)
+	}
+	return trace.Wrap(h.db.Update(fn))
+}
+
+// view runs the read only transaction, the database is opened
+// in read only mode unless the history is open for recording
+func (h *History) view(fn func(*bolt.Tx) error) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.db != nil {
+		return trace.Wrap(h.db.View(fn))
+	}
+	if _, err := os.Stat(h.path); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	db, err := bolt.Open(h.path, 0600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		if err == bolt.ErrTimeout {
+			return trace.ConnectionProblem(err, "history %v is locked by the running force, see the runs on its web interface", h.path)
+		}
+		return trace.Wrap(err)
+	}
+	defer db.Close()
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(historyBucket) == nil {
+			return nil
+		}
+		return fn(tx)
+	})
+	return trace.Wrap(err)
+}
+
+// Record creates or updates the run and periodically
+// prunes the runs that are over the limits of the history
+func (h *History) Record(r Run) error {
+	log := r.Log
+	r.Log = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return h.update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(historyBucket).Put([]byte(r.ID), data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(startedBucket).Put(startedKey(r), []byte(r.ID)); err != nil {
+			return err
+		}
+		if log != "" {
+			if err := tx.Bucket(logsBucket).Put([]byte(r.ID), []byte(log)); err != nil {
+				return err
+			}
+		}
+		// pruning scans the runs, so it is done periodically
+		now := time.Now().UTC()
+		if now.Sub(h.pruned) < historyPruneInterval {
+			return nil
+		}
+		h.pruned = now
+		return h.prune(tx, now)
+	})
+}
+
+// prune deletes the runs older than MaxAge and the oldest
+// runs over MaxRuns along with their logs
+func (h *History) prune(tx *bolt.Tx, now time.Time) error {
+	started := tx.Bucket(startedBucket)
+	c := started.Cursor()
+	extra := 0
+	if h.MaxRuns > 0 {
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			extra++
+		}
+		extra -= h.MaxRuns
+	}
+	var cutoff time.Time
+	if h.MaxAge > 0 {
+		cutoff = now.Add(-h.MaxAge)
+	}
+	var keys, ids [][]byte
+	for k, id := c.First(); k != nil; k, id = c.Next() {
+		if extra <= 0 && !startedTime(k).Before(cutoff) {
+			break
+		}
+		keys = append(keys, append([]byte(nil), k...))
+		ids = append(ids, append([]byte(nil), id...))
+		extra--
+	}
+	for i := range keys {
+		if err := started.Delete(keys[i]); err != nil {
+			return err
+		}
+		if err := tx.Bucket(historyBucket).Delete(ids[i]); err != nil {
+			return err
+		}
+		if err := tx.Bucket(logsBucket).Delete(ids[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failInterrupted marks the runs left running by force
+// that has not exited gracefully as failed
+func failInterrupted(tx *bolt.Tx) error {
+	runs := tx.Bucket(historyBucket)
+	var interrupted []Run
+	err := runs.ForEach(func(k, v []byte) error {
+		var r Run
+		if err := json.Unmarshal(v, &r); err != nil {
+			return err
+		}
+		if r.Status == RunRunning {
+			interrupted = append(interrupted, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range interrupted {
+		r.Status = RunFailed
+		r.Error = "force has exited before the run has completed"
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if err := runs.Put([]byte(r.ID), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startedKey returns a key of the run in the start time index,
+// big endian keys keep the runs sorted by the start time
+func startedKey(r Run) []byte {
+	key := make([]byte, 8, 8+len(r.ID))
+	binary.BigEndian.PutUint64(key, uint64(r.Started.UnixNano()))
+	return append(key, r.ID...)
+}
+
+// startedTime returns the start time of the run from the index key
+func startedTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key[:8]))).UTC()
+}
+
+// Get returns the run by id along with the captured log
+func (h *History) Get(id string) (*Run, error) {
+	var run *Run
+	err := h.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(historyBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		run = &Run{}
+		if err := json.Unmarshal(data, run); err != nil {
+			return err
+		}
+		if logs := tx.Bucket(logsBucket); logs != nil {
+			run.Log = string(logs.Get([]byte(id)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if run == nil {
+		return nil, trace.NotFound("run %v is not found", id)
+	}
+	return run, nil
+}
+
+// List returns the runs matching the filter, the latest runs go first,
+// captured logs are not returned
+func (h *History) List(filter HistoryFilter) ([]Run, error) {
+	var runs []Run
+	err := h.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(historyBucket).ForEach(func(k, v []byte) error {
+			var r Run
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if !filter.Match(r) {
+				return nil
+			}
+			runs = append(runs, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Started.After(runs[j].Started)
+	})
+	return runs, nil
+}
+
+// newLogCapture returns a new log capture
+func newLogCapture() *logCapture {
+	return &logCapture{}
+}
+
+// logCapture captures log lines of the run
+type logCapture struct {
+	mutex sync.Mutex
+	buf   strings.Builder
+	// discarded is a number of bytes discarded
+	// from the beginning of the log
+	discarded int
+}
+
+// add adds the log line, if the captured log is too long,
+// the first lines are discarded
+func (c *logCapture) add(level, message string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	line := fmt.Sprintf("%v %v %v", time.Now().UTC().Format(time.RFC3339), level, message)
+	if err != nil {
+		line = fmt.Sprintf("%v error: %v", line, err)
+	}
+	c.buf.WriteString(line)
+	c.buf.WriteString("\n")
+	if c.buf.Len() <= MaxCapturedLog {
+		return
+	}
+	out := c.buf.String()
+	out = out[len(out)-MaxCapturedLog:]
+	if i := strings.IndexByte(out, '\n'); i >= 0 {
+		out = out[i+1:]
+	}
+	c.discarded += c.buf.Len() - len(out)
+	c.buf.Reset()
+	c.buf.WriteString(out)
+}
+
+// since returns the log captured after the offset
+// and the offset of the end of the captured log
+func (c *logCapture) since(offset int) (string, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	out := c.buf.String()
+	start := offset - c.discarded
+	if start < 0 {
+		start = 0
+	}
+	if start > len(out) {
+		start = len(out)
+	}
+	return out[start:], c.discarded + len(out)
+}
+
+// String returns the captured log
+func (c *logCapture) String() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.buf.String()
+}
+
+// capturingLogger is a logger capturing log lines of the run,
+// debug lines are not captured
+type capturingLogger struct {
+	force.Logger
+	capture *logCapture
+	err     error
+}
+
+// WithError returns a logger bound to an error
+func (l *capturingLogger) WithError(err error) force.Logger {
+	return &capturingLogger{Logger: l.Logger.WithError(err), capture: l.capture, err: err}
+}
+
+// AddFields adds fields to the logger
+func (l *capturingLogger) AddFields(fields map[string]interface{}) force.Logger {
+	return &capturingLogger{Logger: l.Logger.AddFields(fields), capture: l.capture, err: l.err}
+}
+
+// Infof logs and captures info message
+func (l *capturingLogger) Infof(format string, args ...interface{}) {
+	l.capture.add("INFO", fmt.Sprintf(format, args...), l.err)
+	l.Logger.Infof(format, args...)
+}
+
+// Warningf logs and captures warning message
+func (l *capturingLogger) Warningf(format string, args ...interface{}) {
+	l.capture.add("WARN", fmt.Sprintf(format, args...), l.err)
+	l.Logger.Warningf(format, args...)
+}
+
+// Errorf logs and captures error message
+func (l *capturingLogger) Errorf(format string, args ...interface{}) {
+	l.capture.add("ERRO", fmt.Sprintf(format, args...), l.err)
+	l.Logger.Errorf(format, args...)
+}
@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gravitational/trace"
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestHistory(c *check.C) {
+	path := filepath.Join(c.MkDir(), "history.db")
+	reader, err := NewHistory(path)
+	c.Assert(err, check.IsNil)
+	_, err = reader.List(HistoryFilter{})
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+	c.Assert(reader.Record(Run{ID: "a"}), check.NotNil)
+
+	history, err := OpenHistory(path)
+	c.Assert(err, check.IsNil)
+	defer history.Close()
+
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	runs := []Run{
+		{ID: "a", Process: "build", Status: RunSucceeded, Started: start, Event: "commit 8a1f3c2"},
+		{ID: "b", Process: "build", Status: RunFailed, Started: start.Add(time.Minute), Error: "exit status 1", Log: "failed", Event: "commit 5d0e9b7"},
+		{ID: "c", Process: "deploy", Status: RunFailed, Started: start.Add(2 * time.Minute), Event: "commit 8a1f3c2"},
+	}
+	for _, run := range runs {
+		c.Assert(history.Record(run), check.IsNil)
+	}
+
+	type testCase struct {
+		filter   HistoryFilter
+		expected []string
+	}
+	testCases := []testCase{
+		{filter: HistoryFilter{}, expected: []string{"c", "b", "a"}},
+		{filter: HistoryFilter{Process: "build"}, expected: []string{"b", "a"}},
+		{filter: HistoryFilter{Failed: true}, expected: []string{"c", "b"}},
+		{filter: HistoryFilter{Process: "build", Failed: true}, expected: []string{"b"}},
+		{filter: HistoryFilter{Event: "8a1f3c2"}, expected: []string{"c", "a"}},
+		{filter: HistoryFilter{Event: "8a1f3c2", Failed: true}, expected: []string{"c"}},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v", i)
+		out, err := history.List(tc.filter)
+		c.Assert(err, check.IsNil, comment)
+		var ids []string
+		for _, run := range out {
+			ids = append(ids, run.ID)
+		}
+		c.Assert(ids, check.DeepEquals, tc.expected, comment)
+	}
+
+	run, err := history.Get("b")
+	c.Assert(err, check.IsNil)
+	c.Assert(*run, check.DeepEquals, runs[1])
+	_, err = history.Get("d")
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+
+	// logs are returned only with the run
+	listed, err := history.List(HistoryFilter{Process: "build", Failed: true})
+	c.Assert(err, check.IsNil)
+	c.Assert(listed[0].Log, check.Equals, "")
+
+	// runs over the limits are pruned periodically along with their logs
+	history.MaxRuns = 2
+	history.MaxAge = 30 * time.Minute
+	history.pruned = time.Time{}
+	c.Assert(history.Record(Run{ID: "d", Process: "deploy", Status: RunRunning, Started: start.Add(45 * time.Minute)}), check.IsNil)
+	listed, err = history.List(HistoryFilter{})
+	c.Assert(err, check.IsNil)
+	c.Assert(listed, check.HasLen, 1)
+	c.Assert(listed[0].ID, check.Equals, "d")
+	history.MaxAge = 0
+	for _, id := range []string{"e", "f"} {
+		c.Assert(history.Record(Run{ID: id, Process: "deploy", Status: RunRunning, Started: start.Add(50 * time.Minute)}), check.IsNil)
+	}
+	// runs are not pruned until the next prune interval
+	listed, err = history.List(HistoryFilter{})
+	c.Assert(err, check.IsNil)
+	c.Assert(listed, check.HasLen, 3)
+	history.pruned = time.Time{}
+	c.Assert(history.Record(Run{ID: "f", Process: "deploy", Status: RunRunning, Started: start.Add(50 * time.Minute)}), check.IsNil)
+	listed, err = history.List(HistoryFilter{})
+	c.Assert(err, check.IsNil)
+	c.Assert(listed, check.HasLen, 2)
+	_, err = history.Get("d")
+	c.Assert(trace.IsNotFound(err), check.Equals, true)
+	err = history.view(func(tx *bolt.Tx) error {
+		c.Assert(tx.Bucket(logsBucket).Stats().KeyN, check.Equals, 0)
+		c.Assert(tx.Bucket(startedBucket).Stats().KeyN, check.Equals, 2)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+
+	// runs left running are marked as failed when the history is opened again
+	c.Assert(history.Close(), check.IsNil)
+	history, err = OpenHistory(path)
+	c.Assert(err, check.IsNil)
+	run, err = history.Get("f")
+	c.Assert(err, check.IsNil)
+	c.Assert(run.Status, check.Equals, RunFailed)
+	c.Assert(run.Error, check.Not(check.Equals), "")
+	c.Assert(history.Close(), check.IsNil)
+
+	// only the last lines of long logs are captured
+	capture := newLogCapture()
+	line := strings.Repeat("x", 1024)
+	for i := 0; i < 2*MaxCapturedLog/len(line); i++ {
+		capture.add("INFO", line, nil)
+	}
+	capture.add("INFO", "last", nil)
+	out := capture.String()
+	c.Assert(len(out) <= MaxCapturedLog, check.Equals, true)
+	c.Assert(strings.HasSuffix(out, "INFO last\n"), check.Equals, true)
+}
@@ -52,6 +52,11 @@ func (s *IncludeAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
 		if err != nil {
 			return nil, trace.Wrap(convertScanError(err, script))
 		}
+		// included scripts are parsed, but not evaluated
+		// when the code is checked
+		if s.g.vet {
+			continue
+		}
 		if proc, ok := actionI.(force.Process); ok {
 			tempContext := force.NewContext(force.ContextConfig{
 				Parent:  ctx,
@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/gravitational/trace"
+	bolt "go.etcd.io/bbolt"
+)
+
+// journalBucket is a name of the bolt bucket with the journal entries
+var journalBucket = []byte("events")
+
+// journalEntry is an event received by the process
+type journalEntry struct {
+	// ID is a unique entry id
+	ID uint64 `json:"id"`
+	// Process is a name of the process that received the event
+	Process string `json:"process"`
+	// Event is a serialized event
+	Event json.RawMessage `json:"event"`
+	// Received is a time when the event was received
+	Received time.Time `json:"received"`
+}
+
+// journaledEvent is an event recorded in the journal on its way
+// to the process, the execution of the event completes the journal entry
+type journaledEvent struct {
+	force.Event
+	// journalID is an id of the unfinished journal entry
+	journalID uint64
+}
+
+// String returns user friendly description of the event
+func (j *journaledEvent) String() string {
+	return fmt.Sprintf("%v", j.Event)
+}
+
+// eventRecorder is implemented by processes recording
+// the events they receive in the journal
+type eventRecorder interface {
+	// receive records the event received by the process,
+	// returns nil if the event is not recorded
+	receive(event force.Event) *journaledEvent
+	// discard completes the journal entry of the event
+	// dropped before it has reached the process
+	discard(event force.Event)
+}
+
+// openJournal opens the event journal
+func openJournal(path string) (*journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(journalBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, trace.Wrap(err)
+	}
+	return &journal{db: db}, nil
+}
+
+// journal records events received by processes until they are processed,
+// so events that have not been processed are delivered to the processes
+// again after restart
+type journal struct {
+	db *bolt.DB
+}
+
+// record records the event received by the process and returns the entry id
+func (j *journal) record(process string, event force.SerializableEvent) (uint64, error) {
+	data, err := event.MarshalEvent()
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	var id uint64
+	err = j.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(journalBucket)
+		id, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return j.put(bucket, journalEntry{
+			ID:       id,
+			Process:  process,
+			Event:    data,
+			Received: time.Now().UTC(),
+		})
+	})
+	return id, trace.Wrap(err)
+}
+
+// complete removes the entry of the processed event
+func (j *journal) complete(id uint64) error {
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(journalBucket)
+		if bucket.Get(entryKey(id)) == nil {
+			return trace.NotFound("journal entry %v is not found", id)
+		}
+		return bucket.Delete(entryKey(id))
+	})
+	return trace.Wrap(err)
+}
+
+// unfinished returns entries that have not been completed
+func (j *journal) unfinished() ([]journalEntry, error) {
+	var out []journalEntry
+	err := j.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(journalBucket).ForEach(func(k, v []byte) error {
+			var e journalEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			out = append(out, e)
+			return nil
+		})
+	})
+	return out, trace.Wrap(err)
+}
+
+// put saves the entry in the bucket
+func (j *journal) put(bucket *bolt.Bucket, e journalEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(entryKey(e.ID), data)
+}
+
+// Close closes the journal
+func (j *journal) Close() error {
+	return j.db.Close()
+}
+
+// entryKey returns a key of the entry, big endian
+// keys keep the entries sorted in the order they were received
+func entryKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
@@ -0,0 +1,164 @@
+package runner
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestJournal(c *check.C) {
+	path := filepath.Join(c.MkDir(), "journal.db")
+	j, err := openJournal(path)
+	c.Assert(err, check.IsNil)
+
+	ticker, err := force.Ticker(force.String("1s"))
+	c.Assert(err, check.IsNil)
+	events := []*force.TickEvent{
+		{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Time: time.Date(2019, 1, 1, 0, 0, 1, 0, time.UTC)},
+	}
+	var ids []uint64
+	for _, event := range events {
+		id, err := j.record("proc", event)
+		c.Assert(err, check.IsNil)
+		ids = append(ids, id)
+	}
+	c.Assert(j.complete(ids[0]), check.IsNil)
+	c.Assert(j.Close(), check.IsNil)
+
+	// unfinished events are restored after reopening the journal
+	j, err = openJournal(path)
+	c.Assert(err, check.IsNil)
+	defer j.Close()
+	entries, err := j.unfinished()
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].ID, check.Equals, ids[1])
+	c.Assert(entries[0].Process, check.Equals, "proc")
+
+	event, err := ticker.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), entries[0].Event)
+	c.Assert(err, check.IsNil)
+	c.Assert(event.Created().Equal(events[1].Time), check.Equals, true)
+}
+
+// TestReplay checks that the replayed event completes
+// the existing journal entry when its execution completes
+func (s *ParserSuite) TestReplay(c *check.C) {
+	g := newTestParser(c)
+	defer g.runner.cancel()
+	j, err := openJournal(filepath.Join(c.MkDir(), "journal.db"))
+	c.Assert(err, check.IsNil)
+	defer j.Close()
+	g.runner.journal = j
+
+	ticker, err := force.Ticker(force.String("1s"))
+	c.Assert(err, check.IsNil)
+	// process is not started, so the events are received by the test
+	proc, err := NewLocalProcess(g.runner.ctx, g.runner.Logger(), force.Spec{
+		Name:  "proc",
+		Watch: ticker,
+		Run:   force.Exit(),
+	})
+	c.Assert(err, check.IsNil)
+	proc.journal = j
+	g.runner.processes = []force.Process{proc}
+
+	id, err := j.record("proc", &force.TickEvent{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)})
+	c.Assert(err, check.IsNil)
+	entries, err := j.unfinished()
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+
+	go g.runner.replay()
+	var event force.Event
+	select {
+	case event = <-proc.eventsC:
+	case <-time.After(time.Second):
+		c.Fatalf("timeout waiting for event")
+	}
+
+	// the entry is not re-recorded and stays unfinished until the execution completes
+	e, err := proc.newExecution(force.WithRuntimeScope(g.scope), event)
+	c.Assert(err, check.IsNil)
+	c.Assert(e.journalID, check.Equals, id)
+	_, ok := e.event.(*force.TickEvent)
+	c.Assert(ok, check.Equals, true)
+	unfinished, err := j.unfinished()
+	c.Assert(err, check.IsNil)
+	c.Assert(unfinished, check.DeepEquals, entries)
+
+	proc.complete(e)
+	unfinished, err = j.unfinished()
+	c.Assert(err, check.IsNil)
+	c.Assert(unfinished, check.HasLen, 0)
+}
+
+// TestJournalReceive checks that events are recorded when received,
+// and the entries of the dropped events are removed
+func (s *ParserSuite) TestJournalReceive(c *check.C) {
+	g := newTestParser(c)
+	defer g.runner.cancel()
+	j, err := openJournal(filepath.Join(c.MkDir(), "journal.db"))
+	c.Assert(err, check.IsNil)
+	defer j.Close()
+	g.runner.journal = j
+
+	ticker, err := force.Ticker(force.String("1s"))
+	c.Assert(err, check.IsNil)
+	proc, err := g.runner.Process(force.Spec{
+		Name:  "proc",
+		Watch: ticker,
+		Run:   force.Exit(),
+	})
+	c.Assert(err, check.IsNil)
+	g.runner.processes = []force.Process{proc}
+
+	// the event is recorded before it waits in the runner queue
+	event := g.runner.receive(&force.TickEvent{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)})
+	entries, err := j.unfinished()
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 1)
+	c.Assert(entries[0].Process, check.Equals, "proc")
+
+	journaled, ok := event.(*broadcastEvent).eventFor(proc).(*journaledEvent)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(journaled.journalID, check.Equals, entries[0].ID)
+
+	// events that can not be serialized are not recorded
+	oneshot := &force.OneshotEvent{Time: time.Now().UTC()}
+	c.Assert(g.runner.receive(oneshot), check.Equals, force.Event(oneshot))
+
+	proc.(*LocalProcess).discard(journaled)
+	entries, err = j.unfinished()
+	c.Assert(err, check.IsNil)
+	c.Assert(entries, check.HasLen, 0)
+}
+
+// TestUnmarshalCombinators checks that events of the combined
+// channels are restored by the first channel restoring them
+func (s *ParserSuite) TestUnmarshalCombinators(c *check.C) {
+	oneshot, err := force.Oneshot()
+	c.Assert(err, check.IsNil)
+	ticker, err := force.Ticker(force.String("1s"))
+	c.Assert(err, check.IsNil)
+	fanIn, err := force.FanIn(oneshot, ticker)
+	c.Assert(err, check.IsNil)
+
+	tick := &force.TickEvent{Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+	data, err := tick.MarshalEvent()
+	c.Assert(err, check.IsNil)
+	for _, channel := range []force.Channel{fanIn, force.Duplicate(ticker, 2)} {
+		event, err := channel.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+		c.Assert(err, check.IsNil)
+		c.Assert(event.Created().Equal(tick.Time), check.Equals, true)
+	}
+
+	fanIn, err = force.FanIn(oneshot)
+	c.Assert(err, check.IsNil)
+	_, err = fanIn.(force.EventUnmarshaler).UnmarshalEvent(context.TODO(), data)
+	c.Assert(err, check.NotNil)
+}
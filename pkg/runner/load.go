@@ -47,13 +47,19 @@ func (s *LoadAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
 	}
 
 	runnerCtx, cancel := context.WithCancel(ctx)
+	// processes of the loaded runners publish
+	// completion events to the same completions
+	plugins := make(map[interface{}]interface{})
+	if completions, ok := s.g.runner.GetPlugin(force.KeyCompletions); ok {
+		plugins[force.KeyCompletions] = completions
+	}
 	runner := &Runner{
 		LexScope:      force.WithLexicalScope(nil),
 		debugOverride: s.g.runner.debugOverride,
 		cancel:        cancel,
 		ctx:           runnerCtx,
 		eventsC:       make(chan force.Event, cap(s.g.runner.eventsC)),
-		plugins:       make(map[interface{}]interface{}),
+		plugins:       plugins,
 		logger:        s.g.runner.Logger(),
 	}
 
@@ -5,9 +5,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/gravitational/force"
+	"github.com/gravitational/force/pkg/tracing"
 
 	"github.com/gravitational/trace"
 )
@@ -19,23 +23,94 @@ func NewLocalProcess(ctx context.Context, logger force.Logger, spec force.Spec)
 	if err := spec.CheckAndSetDefaults(); err != nil {
 		return nil, trace.Wrap(err)
 	}
+	action, err := processAction(spec)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
 	cancelCtx, cancel := context.WithCancel(ctx)
 	return &LocalProcess{
-		logger:  logger,
-		ctx:     cancelCtx,
-		cancel:  cancel,
-		Spec:    spec,
-		eventsC: make(chan force.Event, 32),
+		logger: logger,
+		ctx:    cancelCtx,
+		cancel: cancel,
+		Spec:   spec,
+		action: action,
+		// events are queued by the process,
+		// so the senders are blocked in the Block overflow mode
+		eventsC: make(chan force.Event),
+		groups:  make(map[string]*execution),
 	}, nil
 }
 
+// processAction returns the action evaluated by every run
+// of the process, the action is limited by the timeout
+// and retried according to the retry policy of the spec
+func processAction(spec force.Spec) (force.Action, error) {
+	action := spec.Run
+	var err error
+	if spec.Timeout != nil {
+		action, err = force.Timeout(spec.Timeout, action)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	if spec.Retry != nil {
+		action, err = force.Retry(spec.Retry, action)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return action, nil
+}
+
 // LocalProcess implements a process interface
 type LocalProcess struct {
 	force.Spec
+	// action is the action of the spec
+	// wrapped in the timeout and retries
+	action  force.Action
 	eventsC chan force.Event
 	ctx     context.Context
 	cancel  context.CancelFunc
 	logger  force.Logger
+	// mutex protects groups
+	mutex sync.Mutex
+	// groups tracks the latest execution
+	// of every concurrency group
+	groups map[string]*execution
+	// journal is an optional event journal
+	journal *journal
+	// history is an optional execution history
+	history *History
+	// runs tracks recent runs shown by the server
+	runs *runTracker
+	// traces is an optional directory to write traces of the runs to
+	traces string
+}
+
+// execution is a run of the process action triggered by the event
+type execution struct {
+	event  force.Event
+	ctx    *force.Context
+	cancel context.CancelFunc
+	// key is a concurrency key of the execution
+	key string
+	// journalID is an id of the event journal entry
+	journalID uint64
+	// capture captures the log of the execution
+	// recorded in the history
+	capture *logCapture
+	// recorder records spans of the execution
+	recorder *tracing.Recorder
+}
+
+// String returns user friendly execution description
+func (e *execution) String() string {
+	return fmt.Sprintf("%v", e.event)
+}
+
+// queuePolicy returns the queue size and the overflow mode of the process
+func (l *LocalProcess) queuePolicy() (int, string) {
+	return int(l.QueueSize), string(l.Overflow)
 }
 
 // EventSource returns channel
@@ -62,6 +137,10 @@ func (l *LocalProcess) fanInEvents(ctx force.ExecutionContext) {
 		case <-ctx.Done():
 			return
 		case event := <-l.Channel().Events():
+			if journaled := l.receive(event); journaled != nil {
+				event = journaled
+			}
+			// overflow is handled by the process queue
 			select {
 			case l.eventsC <- event:
 				log.Debugf("Fan in received event %v.", event)
@@ -69,8 +148,6 @@ func (l *LocalProcess) fanInEvents(ctx force.ExecutionContext) {
 				return
 			case <-ctx.Done():
 				return
-			default:
-				log.Warningf("Overflow, dropping event %v.", event)
 			}
 		}
 	}
@@ -128,7 +205,11 @@ func ShortID() string {
 	return hex.EncodeToString(b)
 }
 
+// triggerActions queues received events, queued events
+// are processed by the workers
 func (l *LocalProcess) triggerActions(ctx force.ExecutionContext) {
+	queue := newExecutionQueue(int(l.QueueSize), string(l.Overflow))
+	l.startWorkers(queue)
 	for {
 		select {
 		case <-l.ctx.Done():
@@ -139,43 +220,334 @@ func (l *LocalProcess) triggerActions(ctx force.ExecutionContext) {
 			return
 		case event := <-l.eventsC:
 			l.logger.Debugf("%v has received %v.", l, event)
+			processEvents.Inc(l.Name())
 			if force.IsExit(event) {
 				l.logger.Debugf("Has received an exit event, exiting.")
 				l.logger.Debugf("%v has triggered an exit event, exiting.", l)
 				l.cancel()
 				return
 			}
-			go func() {
-				execContext := force.NewContext(force.ContextConfig{
-					Parent:  ctx,
-					Process: l,
-					Event:   event,
-					ID:      ShortID(),
-				})
-				logger := l.logger.AddFields(map[string]interface{}{
-					force.KeyID: execContext.ID(),
-				})
-				// add a process logger to the context
-				force.SetLog(execContext, logger)
-				// add optional data from the event
-				event.AddMetadata(execContext)
-				start := time.Now()
-				lambda, ok := l.Run.(*force.LambdaFunction)
-				var err error
-				if ok {
-					// normally, lambda function evaluates to itself,
-					// in Process{Run: func(){}) has to be explicitly callled,
-					//
-					_, err = lambda.Call(execContext)
-				} else {
-					_, err = l.Run.Eval(execContext)
+			e, err := l.newExecution(ctx, event)
+			if err != nil {
+				l.logger.WithError(err).Errorf("Failed to evaluate concurrency key of %v.", event)
+				continue
+			}
+			if !l.enqueue(queue, e) {
+				l.logger.Debugf("This process has exited, returning.")
+				return
+			}
+		}
+	}
+}
+
+// enqueue queues the execution, the queued execution supersedes
+// the previous execution with the same concurrency key, the execution
+// dropped because of the overflow supersedes nothing,
+// returns false if the process has exited
+func (l *LocalProcess) enqueue(queue *executionQueue, e *execution) bool {
+	dropped, ok := queue.push(l.ctx, e)
+	if !ok {
+		return false
+	}
+	processQueueDepth.Set(float64(queue.len()), l.Name())
+	if dropped != e {
+		l.supersede(e)
+	}
+	if dropped != nil {
+		l.logger.Warningf("Queue is full, dropping event %v.", dropped)
+		processEventsDropped.Inc(l.Name())
+		l.complete(dropped)
+	}
+	return true
+}
+
+// newExecution returns a new execution triggered by the event
+// and evaluates the concurrency key of the execution
+func (l *LocalProcess) newExecution(ctx force.ExecutionContext, event force.Event) (*execution, error) {
+	var journalID uint64
+	if journaled, ok := event.(*journaledEvent); ok {
+		event, journalID = journaled.Event, journaled.journalID
+	}
+	// executions are cancelled when superseded
+	cancelCtx, cancel := force.WithCancel(ctx)
+	execContext := force.NewContext(force.ContextConfig{
+		Parent:  cancelCtx,
+		Process: l,
+		Event:   event,
+		ID:      ShortID(),
+	})
+	logger := l.logger.AddFields(map[string]interface{}{
+		force.KeyID: execContext.ID(),
+	})
+	e := &execution{
+		event:     event,
+		ctx:       execContext,
+		cancel:    cancel,
+		journalID: journalID,
+	}
+	serving := l.runs != nil && l.runs.enabled()
+	if l.history != nil || serving {
+		e.capture = newLogCapture()
+		logger = &capturingLogger{Logger: logger, capture: e.capture}
+	}
+	if serving {
+		execContext.SetValue(force.KeyRunURL, l.runs.runURL(execContext.ID()))
+	}
+	if l.traces != "" {
+		e.recorder = tracing.NewRecorder()
+		execContext.SetValue(force.KeyTracer, e.recorder)
+	}
+	// add a process logger to the context
+	force.SetLog(execContext, logger)
+	// add optional data from the event
+	event.AddMetadata(execContext)
+	if l.ConcurrencyKey != nil {
+		key, err := force.EvalString(execContext, l.ConcurrencyKey)
+		if err != nil {
+			cancel()
+			return nil, trace.Wrap(err)
+		}
+		e.key = key
+	}
+	return e, nil
+}
+
+// receive records the event received by the process in the journal,
+// so the event waiting for the process is delivered again after restart,
+// returns nil if there is no journal or the event can not be serialized
+func (l *LocalProcess) receive(event force.Event) *journaledEvent {
+	if l.journal == nil {
+		return nil
+	}
+	serializable, ok := event.(force.SerializableEvent)
+	if !ok {
+		return nil
+	}
+	id, err := l.journal.record(l.Name(), serializable)
+	if err != nil {
+		// events wrapping events that can not be serialized are not recorded
+		if !trace.IsNotImplemented(err) {
+			l.logger.WithError(err).Warningf("Failed to record %v in the journal.", event)
+		}
+		return nil
+	}
+	return &journaledEvent{Event: event, journalID: id}
+}
+
+// discard completes the journal entry of the event
+// dropped before it has reached the process
+func (l *LocalProcess) discard(event force.Event) {
+	if journaled, ok := event.(*journaledEvent); ok {
+		l.completeEntry(journaled.journalID, journaled.Event)
+	}
+}
+
+// completeEntry removes the journal entry of the processed event
+func (l *LocalProcess) completeEntry(id uint64, event force.Event) {
+	if err := l.journal.complete(id); err != nil {
+		l.logger.WithError(err).Warningf("Failed to complete %v in the journal.", event)
+	}
+}
+
+// supersede cancels the previous execution
+// with the same concurrency key as the new execution,
+// the new execution is queued, so it could have completed already
+func (l *LocalProcess) supersede(e *execution) {
+	if e.key == "" || !l.CancelInProgress {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if prev, ok := l.groups[e.key]; ok {
+		force.Log(prev.ctx).Infof("%v is superseded by %v.", prev, e)
+		force.Supersede(prev.ctx)
+		prev.cancel()
+		delete(l.groups, e.key)
+	}
+	// completed executions are cancelled
+	if e.ctx.Err() == nil {
+		l.groups[e.key] = e
+	}
+}
+
+// complete releases resources of the execution and removes
+// the journal entry of its event, events of executions
+// interrupted by the shutdown are delivered again after restart
+func (l *LocalProcess) complete(e *execution) {
+	e.cancel()
+	if e.journalID != 0 && l.ctx.Err() == nil {
+		l.completeEntry(e.journalID, e.event)
+	}
+	if e.key == "" {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.groups[e.key] == e {
+		delete(l.groups, e.key)
+	}
+}
+
+// startWorkers starts MaxConcurrent workers running the queued executions,
+// if MaxConcurrent is not set, every execution is run right away
+func (l *LocalProcess) startWorkers(queue *executionQueue) {
+	if l.MaxConcurrent == 0 {
+		go func() {
+			for {
+				e, ok := queue.pop(l.ctx)
+				if !ok {
+					return
 				}
-				if err != nil {
-					logger.WithError(err).Errorf("%v failed after running for %v.", l, time.Now().Sub(start))
-				} else {
-					logger.Debugf("%v completed successfully in %v.", l, time.Now().Sub(start))
+				processQueueDepth.Set(float64(queue.len()), l.Name())
+				go l.execute(e)
+			}
+		}()
+		return
+	}
+	for i := 0; i < int(l.MaxConcurrent); i++ {
+		go func() {
+			for {
+				e, ok := queue.pop(l.ctx)
+				if !ok {
+					return
 				}
-			}()
+				processQueueDepth.Set(float64(queue.len()), l.Name())
+				l.execute(e)
+			}
+		}()
+	}
+}
+
+// execute runs the process action
+func (l *LocalProcess) execute(e *execution) {
+	defer l.complete(e)
+	logger := force.Log(e.ctx)
+	// executions superseded while waiting in the queue are skipped
+	if force.IsSuperseded(e.ctx) {
+		logger.Infof("%v was superseded before it has started.", l)
+		return
+	}
+	executionsStarted.Inc(l.Name())
+	start := time.Now()
+	run := Run{
+		ID:      e.ctx.ID(),
+		Process: l.Name(),
+		Event:   fmt.Sprintf("%v", e.event),
+		Status:  RunRunning,
+		Started: start.UTC(),
+	}
+	l.recordRun(run)
+	if l.runs != nil {
+		l.runs.start(run, e.capture)
+	}
+	ctx, span := force.StartSpan(e.ctx, l.Name())
+	span.SetTag("run.id", run.ID)
+	span.SetTag("event", run.Event)
+	err := evalAction(ctx, l.action)
+	if !force.IsSuperseded(e.ctx) {
+		l.runHook(ctx, err)
+	}
+	force.FinishSpan(span, err)
+	l.writeTrace(e)
+	run.Duration = time.Now().Sub(start)
+	switch {
+	case err != nil && force.IsSuperseded(e.ctx):
+		run.Status = RunSuperseded
+		logger.Infof("%v was superseded after running for %v.", l, run.Duration)
+	case err != nil:
+		run.Status = RunFailed
+		run.Error = err.Error()
+		logger.WithError(err).Errorf("%v failed after running for %v.", l, run.Duration)
+	default:
+		run.Status = RunSucceeded
+		logger.Debugf("%v completed successfully in %v.", l, run.Duration)
+	}
+	if run.Status != RunSuperseded {
+		l.publish(e, err)
+	}
+	executionsCompleted.Inc(l.Name(), run.Status)
+	executionDuration.Observe(run.Duration.Seconds(), l.Name(), run.Status)
+	if e.capture != nil {
+		run.Log = e.capture.String()
+	}
+	l.recordRun(run)
+	if l.runs != nil {
+		l.runs.finish(run)
+	}
+}
+
+// publish publishes the completion event of the execution
+// to the processes watching it with Completed channels
+func (l *LocalProcess) publish(e *execution, err error) {
+	if l.Group() == nil {
+		return
+	}
+	completions, cerr := force.GetCompletions(l.Group())
+	if cerr != nil {
+		l.logger.Debugf("Completion events are not published: %v.", cerr)
+		return
+	}
+	completions.Publish(e.ctx, force.NewCompletedEvent(l.Name(), e.ctx.ID(), e.event, err))
+}
+
+// runHook evaluates OnFailure hook of the failed run,
+// or OnSuccess hook of the successful run, hook errors are logged
+func (l *LocalProcess) runHook(ctx force.ExecutionContext, err error) {
+	hook, name := l.OnSuccess, "OnSuccess"
+	if err != nil {
+		hook, name = l.OnFailure, "OnFailure"
+	}
+	if hook == nil {
+		return
+	}
+	hookCtx, span := force.StartSpan(force.WithRuntimeScope(ctx), name)
+	force.SetError(hookCtx, err)
+	herr := evalAction(hookCtx, hook)
+	force.FinishSpan(span, herr)
+	if herr != nil {
+		force.Log(ctx).WithError(herr).Errorf("%v %v has failed.", l, name)
+	}
+}
+
+// evalAction evaluates the action, normally, lambda function
+// evaluates to itself, in Process{Run: func(){}) has to be explicitly called
+func evalAction(ctx force.ExecutionContext, action force.Action) error {
+	if lambda, ok := action.(*force.LambdaFunction); ok {
+		_, err := lambda.Call(ctx)
+		return err
+	}
+	_, err := action.Eval(ctx)
+	return err
+}
+
+// writeTrace writes the trace of the execution
+// in the Chrome trace format to the traces directory
+func (l *LocalProcess) writeTrace(e *execution) {
+	if e.recorder == nil {
+		return
+	}
+	path := filepath.Join(l.traces, fmt.Sprintf("%v-%v.json", l.Name(), e.ctx.ID()))
+	err := func() error {
+		f, err := os.Create(path)
+		if err != nil {
+			return trace.ConvertSystemError(err)
 		}
+		defer f.Close()
+		return e.recorder.WriteChromeTrace(f)
+	}()
+	if err != nil {
+		l.logger.WithError(err).Warningf("Failed to write trace %v.", path)
+		return
+	}
+	force.Log(e.ctx).Debugf("Wrote trace to %v.", path)
+}
+
+// recordRun records the run in the execution history
+func (l *LocalProcess) recordRun(run Run) {
+	if l.history == nil {
+		return
+	}
+	if err := l.history.Record(run); err != nil {
+		l.logger.WithError(err).Warningf("Failed to record run %v in the history.", run.ID)
 	}
 }
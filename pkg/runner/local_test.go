@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestSupersede(c *check.C) {
+	g := newTestParser(c)
+	proc, err := NewLocalProcess(context.TODO(), g.runner.Logger(), force.Spec{
+		Run:              force.Exit(),
+		ConcurrencyKey:   force.String("pr-1"),
+		CancelInProgress: true,
+	})
+	c.Assert(err, check.IsNil)
+	ctx := force.WithRuntimeScope(g.scope)
+
+	first, err := proc.newExecution(ctx, &force.OneshotEvent{Time: time.Unix(1, 0)})
+	c.Assert(err, check.IsNil)
+	c.Assert(first.key, check.Equals, "pr-1")
+	proc.supersede(first)
+	c.Assert(force.IsSuperseded(first.ctx), check.Equals, false)
+
+	// the new execution with the same key cancels the previous one
+	second, err := proc.newExecution(ctx, &force.OneshotEvent{Time: time.Unix(2, 0)})
+	c.Assert(err, check.IsNil)
+	proc.supersede(second)
+	c.Assert(force.IsSuperseded(first.ctx), check.Equals, true)
+	c.Assert(first.ctx.Err(), check.Equals, context.Canceled)
+	c.Assert(force.IsSuperseded(second.ctx), check.Equals, false)
+	c.Assert(second.ctx.Err(), check.IsNil)
+
+	// completed executions are removed from the group
+	proc.complete(first)
+	c.Assert(proc.groups["pr-1"], check.Equals, second)
+	proc.complete(second)
+	c.Assert(proc.groups, check.HasLen, 0)
+}
+
+// TestSupersedeDropped checks that the execution dropped
+// because of the full queue does not supersede the running one
+func (s *ParserSuite) TestSupersedeDropped(c *check.C) {
+	g := newTestParser(c)
+	proc, err := NewLocalProcess(context.TODO(), g.runner.Logger(), force.Spec{
+		Run:              force.Exit(),
+		ConcurrencyKey:   force.String("pr-1"),
+		CancelInProgress: true,
+		QueueSize:        1,
+	})
+	c.Assert(err, check.IsNil)
+	ctx := force.WithRuntimeScope(g.scope)
+	queue := newExecutionQueue(int(proc.QueueSize), string(proc.Overflow))
+	newExecution := func(sec int64) *execution {
+		e, err := proc.newExecution(ctx, &force.OneshotEvent{Time: time.Unix(sec, 0)})
+		c.Assert(err, check.IsNil)
+		return e
+	}
+
+	running := newExecution(1)
+	c.Assert(proc.enqueue(queue, running), check.Equals, true)
+	popped, ok := queue.pop(context.TODO())
+	c.Assert(ok, check.Equals, true)
+	c.Assert(popped, check.Equals, running)
+
+	// the queued execution supersedes the running one
+	queued := newExecution(2)
+	c.Assert(proc.enqueue(queue, queued), check.Equals, true)
+	c.Assert(force.IsSuperseded(running.ctx), check.Equals, true)
+	c.Assert(proc.groups["pr-1"], check.Equals, queued)
+
+	// the dropped execution is completed and supersedes nothing
+	dropped := newExecution(3)
+	c.Assert(proc.enqueue(queue, dropped), check.Equals, true)
+	c.Assert(force.IsSuperseded(queued.ctx), check.Equals, false)
+	c.Assert(queued.ctx.Err(), check.IsNil)
+	c.Assert(dropped.ctx.Err(), check.Equals, context.Canceled)
+	c.Assert(proc.groups["pr-1"], check.Equals, queued)
+}
+
+func (s *ParserSuite) TestProcessHooks(c *check.C) {
+	g := newTestParser(c)
+	dir := c.MkDir()
+	parse := func(code string) force.Expression {
+		expr, err := parseExpr(g, code)
+		c.Assert(err, check.IsNil, check.Commentf("%v", code))
+		return expr
+	}
+	attempts := filepath.Join(dir, "attempts")
+	failure := filepath.Join(dir, "failure")
+	success := filepath.Join(dir, "success")
+	proc, err := NewLocalProcess(context.TODO(), g.runner.Logger(), force.Spec{
+		Name:      force.String("deploy"),
+		Run:       parse(`func(){ Command("echo attempt >> ` + attempts + `; sleep 10") }`),
+		Timeout:   force.String("100ms"),
+		Retry:     force.RetryPolicy{Attempts: 2, Initial: time.Millisecond},
+		OnFailure: parse(`func(){ Command(Sprintf("echo %v >> ` + failure + `", ID())); Infof("Error: %v", Error()) }`),
+		OnSuccess: parse(`func(){ Command("touch ` + success + `") }`),
+	})
+	c.Assert(err, check.IsNil)
+	ctx := force.WithRuntimeScope(g.scope)
+
+	// every attempt is cancelled after the timeout,
+	// failure hook is called once the attempts are exhausted
+	start := time.Now()
+	e, err := proc.newExecution(ctx, &force.OneshotEvent{Time: time.Unix(1, 0)})
+	c.Assert(err, check.IsNil)
+	proc.execute(e)
+	c.Assert(time.Since(start) < 5*time.Second, check.Equals, true)
+	data, err := ioutil.ReadFile(attempts)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, "attempt\nattempt\n")
+	data, err = ioutil.ReadFile(failure)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, e.ctx.ID()+"\n")
+	_, err = os.Stat(success)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+
+	// success hook is called after the successful run
+	proc.action = parse(`func(){ Command("true") }`)
+	e, err = proc.newExecution(ctx, &force.OneshotEvent{Time: time.Unix(2, 0)})
+	c.Assert(err, check.IsNil)
+	proc.execute(e)
+	_, err = os.Stat(success)
+	c.Assert(err, check.IsNil)
+
+	// timeout should be a duration
+	spec := force.Spec{Run: force.Exit(), Timeout: force.String("ten minutes")}
+	c.Assert(spec.CheckAndSetDefaults(), check.NotNil)
+}
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"github.com/gravitational/force/pkg/metrics"
+)
+
+var (
+	runnerEvents = metrics.NewCounter(
+		"force_runner_events_total",
+		"Events broadcast by the runner to the processes.")
+	processEvents = metrics.NewCounter(
+		"force_process_events_total",
+		"Events received by the process.",
+		"process")
+	processEventsDropped = metrics.NewCounter(
+		"force_process_events_dropped_total",
+		"Events dropped by the process because of the queue overflow.",
+		"process")
+	processQueueDepth = metrics.NewGauge(
+		"force_process_queue_depth",
+		"Events waiting in the process queue.",
+		"process")
+	executionsStarted = metrics.NewCounter(
+		"force_process_executions_started_total",
+		"Executions started by the process.",
+		"process")
+	executionsCompleted = metrics.NewCounter(
+		"force_process_executions_completed_total",
+		"Executions completed by the process, status is succeeded, failed or superseded.",
+		"process", "status")
+	executionDuration = metrics.NewHistogram(
+		"force_process_execution_duration_seconds",
+		"Duration of the process executions.",
+		metrics.DefaultBuckets,
+		"process", "status")
+)
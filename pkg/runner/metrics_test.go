@@ -0,0 +1,33 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/gravitational/force"
+	"github.com/gravitational/force/pkg/metrics"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestMetrics(c *check.C) {
+	g := newTestParser(c)
+	proc, err := g.runner.Process(force.Spec{Name: force.String("metrics"), Run: force.Exit()})
+	c.Assert(err, check.IsNil)
+	local := proc.(*LocalProcess)
+	e, err := local.newExecution(force.WithRuntimeScope(g.scope), &force.OneshotEvent{Time: time.Unix(1, 0)})
+	c.Assert(err, check.IsNil)
+	local.execute(e)
+
+	buf := &bytes.Buffer{}
+	c.Assert(metrics.Write(buf), check.IsNil)
+	out := buf.String()
+	for _, line := range []string{
+		`force_process_executions_started_total{process="metrics"} 1`,
+		`force_process_executions_completed_total{process="metrics",status="succeeded"} 1`,
+		`force_process_execution_duration_seconds_count{process="metrics",status="succeeded"} 1`,
+	} {
+		c.Assert(strings.Contains(out, line+"\n"), check.Equals, true, check.Commentf("missing %v", line))
+	}
+}
@@ -20,10 +20,12 @@ import (
 	"github.com/gravitational/force/pkg/builder"
 	"github.com/gravitational/force/pkg/git"
 	"github.com/gravitational/force/pkg/github"
+	"github.com/gravitational/force/pkg/http"
 	"github.com/gravitational/force/pkg/kube"
 	"github.com/gravitational/force/pkg/log"
 	"github.com/gravitational/force/pkg/slack"
 	"github.com/gravitational/force/pkg/ssh"
+	"github.com/gravitational/force/pkg/state"
 
 	"github.com/gravitational/trace"
 )
@@ -49,6 +51,17 @@ type Input struct {
 	Context context.Context
 	// Debug turns on global debug mode
 	Debug bool
+	// Journal is an optional path to the event journal,
+	// events that have not been processed are delivered again after restart
+	Journal string
+	// History is an optional path to the execution history database
+	History string
+	// HistoryMaxRuns is a maximum number of runs kept in the history
+	HistoryMaxRuns int
+	// HistoryMaxAge is a maximum age of runs kept in the history
+	HistoryMaxAge time.Duration
+	// Traces is an optional directory to write traces of the runs to
+	Traces string
 }
 
 // CheckAndSetDefaults checks and sets default values
@@ -62,6 +75,9 @@ func (i *Input) CheckAndSetDefaults() error {
 	if len(i.Script.Content) == 0 {
 		return trace.BadParameter("missing parameter Script")
 	}
+	if i.HistoryMaxRuns < 0 || i.HistoryMaxAge < 0 {
+		return trace.BadParameter("history limits can not be negative")
+	}
 	return nil
 }
 
@@ -99,22 +115,37 @@ func Parse(i Input) (*Runner, error) {
 	if err := i.CheckAndSetDefaults(); err != nil {
 		return nil, trace.Wrap(err)
 	}
-	ctx, cancel := context.WithCancel(i.Context)
-	runner := &Runner{
-		runners:       make(map[string]*Runner),
-		LexScope:      force.WithLexicalScope(nil),
-		debugOverride: i.Debug,
-		cancel:        cancel,
-		ctx:           ctx,
-		eventsC:       make(chan force.Event, 1024),
-		plugins:       make(map[interface{}]interface{}),
-	}
-
+	runner := newRunner(i)
 	g, err := newParser(i.ID, runner)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 
+	if i.Journal != "" {
+		runner.journal, err = openJournal(i.Journal)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	if i.History != "" {
+		runner.history, err = OpenHistory(i.History)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if i.HistoryMaxRuns != 0 {
+			runner.history.MaxRuns = i.HistoryMaxRuns
+		}
+		if i.HistoryMaxAge != 0 {
+			runner.history.MaxAge = i.HistoryMaxAge
+		}
+	}
+	if i.Traces != "" {
+		if err := os.MkdirAll(i.Traces, 0700); err != nil {
+			return nil, trace.ConvertSystemError(err)
+		}
+		runner.traces = i.Traces
+	}
+
 	// Setup the runner
 	if i.Setup.Content != "" {
 		f := token.NewFileSet()
@@ -177,27 +208,69 @@ func Parse(i Input) (*Runner, error) {
 	runner.Logger().Debugf("Add event source %v.", proc.Channel())
 	runner.AddChannel(proc.Channel())
 
+	for _, loop := range force.DetectLoops(proc) {
+		runner.Logger().Warningf("Detected event loop: %v.", loop)
+	}
+	if err := checkCompletions(runner.Logger(), proc); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	return runner, nil
 }
 
+// newRunner returns a new runner for the parser input
+func newRunner(i Input) *Runner {
+	ctx, cancel := context.WithCancel(i.Context)
+	return &Runner{
+		runners:       make(map[string]*Runner),
+		LexScope:      force.WithLexicalScope(nil),
+		debugOverride: i.Debug,
+		cancel:        cancel,
+		ctx:           ctx,
+		eventsC:       make(chan force.Event, 1024),
+		plugins: map[interface{}]interface{}{
+			force.KeyCompletions: force.NewCompletions(),
+		},
+		runs: newRunTracker(),
+	}
+}
+
 func newParser(runID string, runner *Runner) (*gParser, error) {
 	var builtinFunctions = map[string]force.Function{
 		// Standard library functions
 		"Process": &NewProcess{runner: runner},
 		"Setup":   &NewSetupProcess{runner: runner},
+		"Server":  &NewServer{runner: runner},
 
 		// Action runners
-		"Sequence": &force.NewSequence{},
-		"Parallel": &force.NewParallel{},
-		"Defer":    &force.NopScope{Func: force.Defer},
-		"If":       &force.NewIf{},
+		"Sequence":     &force.NewSequence{},
+		"Parallel":     &force.NewParallel{},
+		"ParallelWith": &force.NewParallelWith{},
+		"Defer":        &force.NopScope{Func: force.Defer},
+		"If":           &force.NewIf{},
+		"ForEach":      &force.NewForEach{},
+		"Try":          &force.NopScope{Func: force.Try},
+		"Catch":        &force.NopScope{Func: force.Catch},
+		"Finally":      &force.NopScope{Func: force.Finally},
+		"Error":        &force.NopScope{Func: force.LastError},
+		"Retry":        &force.NopScope{Func: force.Retry},
+		"Attempt":      &force.NopScope{Func: force.Attempt},
+		"Timeout":      &force.NopScope{Func: force.Timeout},
 
 		// Builtin event generator channels
 		"Oneshot":   &force.NopScope{Func: force.Oneshot},
 		"Ticker":    &force.NopScope{Func: force.Ticker},
+		"Cron":      &force.NewCron{},
 		"Duplicate": &force.NopScope{Func: force.Duplicate},
 		"Files":     &force.NopScope{Func: force.Files},
 		"FanIn":     &force.NopScope{Func: force.FanIn},
+		"Filter":    &force.NewFilter{},
+		"Map":       &force.NewMapEvents{},
+		"Debounce":  &force.NewDebounce{},
+		"Throttle":  &force.NewThrottle{},
+		"Batch":     &force.NewBatch{},
+		"Completed": &force.NewCompleted{},
+		"Join":      &force.NewJoin{},
 
 		// Variable-related functions
 		// Define defines a variable in a lexical scope
@@ -220,6 +293,14 @@ func newParser(runID string, runner *Runner) (*gParser, error) {
 		"Marshal":  &force.NopScope{Func: force.Marshal},
 		"Unquote":  &force.NopScope{Func: force.Unquote},
 		"Contains": &force.NopScope{Func: force.Contains},
+
+		// Time functions
+		"Now":           &force.NopScope{Func: force.Now},
+		"Created":       &force.NopScope{Func: force.Created},
+		"Since":         &force.NopScope{Func: force.Since},
+		"ParseDuration": &force.NopScope{Func: force.ParseDuration},
+		"ParseTime":     &force.NopScope{Func: force.ParseTime},
+		"FormatTime":    &force.NopScope{Func: force.FormatTime},
 	}
 
 	var builtinStructs = []interface{}{force.Spec{}, force.Test{}, force.Script{}}
@@ -244,6 +325,8 @@ func newParser(runID string, runner *Runner) (*gParser, error) {
 		string(kube.Key):    kube.Scope,
 		string(ssh.Key):     ssh.Scope,
 		string(aws.Key):     aws.Scope,
+		string(state.Key):   state.Scope,
+		string(http.Key):    http.Scope,
 	}
 	for key, plugin := range plugins {
 		scope, err := plugin()
@@ -285,6 +368,10 @@ func newParser(runID string, runner *Runner) (*gParser, error) {
 	for _, st := range builtinStructs {
 		g.runner.AddDefinition(force.StructName(reflect.TypeOf(st)), reflect.TypeOf(st))
 	}
+	err := force.ImportStructsIntoAST(g.runner.LexScope, reflect.TypeOf(force.RetryPolicy{}), reflect.TypeOf(force.ParallelConfig{}), reflect.TypeOf(force.ServerConfig{}), reflect.TypeOf(force.CronOptions{}), reflect.TypeOf(force.BatchConfig{}), reflect.TypeOf(http.Webhook{}))
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
 	return g, nil
 }
 
@@ -292,6 +379,16 @@ func newParser(runID string, runner *Runner) (*gParser, error) {
 func convertScanError(e error, script Script) error {
 	switch err := trace.Unwrap(e).(type) {
 	case *force.CodeError:
+		// errors of the statements are collected
+		// when the code is checked
+		if _, ok := trace.Unwrap(err.Err).(trace.Aggregate); ok {
+			err.Err = convertScanError(err.Err, script)
+			return e
+		}
+		// errors in the included scripts are already captured
+		if err.Snippet.Text != "" {
+			return e
+		}
 		err.Snippet.Pos.Filename = script.Filename
 		err.Snippet = force.CaptureSnippet(err.Snippet.Pos, script.Content)
 		return e
@@ -304,6 +401,12 @@ func convertScanError(e error, script Script) error {
 				&force.CodeError{Err: trace.BadParameter(sub.Msg), Snippet: snippet})
 		}
 		return trace.NewAggregate(errors...)
+	case trace.Aggregate:
+		var errors []error
+		for _, sub := range err.Errors() {
+			errors = append(errors, convertScanError(sub, script))
+		}
+		return trace.NewAggregate(errors...)
 	default:
 		return e
 	}
@@ -314,6 +417,9 @@ type gParser struct {
 	runner  *Runner
 	scope   *force.RuntimeScope
 	plugins map[string]force.Group
+	// vet is set when the parser only checks the code,
+	// included scripts are parsed, but not evaluated
+	vet bool
 }
 
 // Reload action parses the process at a given file
@@ -384,7 +490,12 @@ func (g *gParser) parseArguments(f *token.FileSet, scope force.Group, nodes []as
 		} else {
 			argType = argumentTypes[i]
 		}
-		val, err := g.parseExpr(f, force.WithParent(scope, argType), n)
+		argScope := force.WithParent(scope, argType)
+		if shared, ok := scope.(*force.ArgumentScope); ok {
+			shared.SetParent(argType)
+			argScope = shared.LexScope
+		}
+		val, err := g.parseExpr(f, argScope, n)
 		if err != nil {
 			return nil, wrap(f, n, trace.Wrap(err))
 		}
@@ -395,9 +506,16 @@ func (g *gParser) parseArguments(f *token.FileSet, scope force.Group, nodes []as
 
 func (g *gParser) parseStatements(f *token.FileSet, scope force.Group, nodes []ast.Node) ([]force.Action, error) {
 	out := make([]force.Action, len(nodes))
+	var errors []error
 	for i, n := range nodes {
 		val, err := g.parseExpr(f, scope, n)
 		if err != nil {
+			// when the code is checked, the rest of the statements
+			// are parsed as well to report all errors at once
+			if g.vet {
+				errors = append(errors, wrap(f, n, err))
+				continue
+			}
 			return nil, trace.Wrap(err)
 		}
 		statement, ok := val.(force.Action)
@@ -406,6 +524,9 @@ func (g *gParser) parseStatements(f *token.FileSet, scope force.Group, nodes []a
 		}
 		out[i] = statement
 	}
+	if len(errors) != 0 {
+		return nil, trace.NewAggregate(errors...)
+	}
 	return out, nil
 }
 
@@ -509,6 +630,11 @@ func (g *gParser) parseExpr(f *token.FileSet, scope force.Group, n ast.Node) (in
 				} else {
 					protoType = parentType
 				}
+				// map of strings with inferred type, e.g. Labels: _{app: "nginx"},
+				// is converted by the map of strings variable
+				if protoType == reflect.TypeOf(force.StringMapVar{}) {
+					protoType = reflect.TypeOf(map[string]force.StringVar{})
+				}
 			} else {
 				out, err := g.runner.GetDefinition(literal.Name)
 				if err != nil {
@@ -547,7 +673,36 @@ func (g *gParser) parseExpr(f *token.FileSet, scope force.Group, n ast.Node) (in
 				return nil, wrap(f, n,
 					trace.BadParameter("can not convert type %v to map or struct", protoType.Kind()))
 			}
+		case *ast.MapType:
+			keyType, valueType, err := g.parseMapType(f, literal)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			keys := make([]force.Expression, len(l.Elts))
+			values := make([]force.Expression, len(l.Elts))
+			for i, el := range l.Elts {
+				kv, ok := el.(*ast.KeyValueExpr)
+				if !ok {
+					return nil, wrap(f, el, trace.BadParameter("expected key value expression, got %T", el))
+				}
+				keys[i], err = g.parseOperand(f, scope, kv.Key)
+				if err != nil {
+					return nil, trace.Wrap(err)
+				}
+				values[i], err = g.parseOperand(f, scope, kv.Value)
+				if err != nil {
+					return nil, trace.Wrap(err)
+				}
+			}
+			m, err := force.Map(keyType, valueType, keys, values)
+			if err != nil {
+				return nil, wrap(f, n, err)
+			}
+			return m, nil
 		case *ast.ArrayType:
+			if id, ok := literal.Elt.(*ast.Ident); ok && isLiteralType(id.Name) {
+				return g.parseLiteralSlice(f, scope, id.Name, l.Elts)
+			}
 			var structProto interface{}
 			var err error
 			switch arrayType := literal.Elt.(type) {
@@ -636,8 +791,11 @@ func (g *gParser) parseExpr(f *token.FileSet, scope force.Group, n ast.Node) (in
 		var err error
 		var newScope force.Group
 		var fn interface{}
+		// fnName is a name of the called function, empty for function literals
+		var fnName string
 		switch call := l.Fun.(type) {
 		case *ast.Ident:
+			fnName = call.Name
 			newFn, err = g.getFunction(scope, call.Name)
 			if err != nil {
 				return nil, trace.Wrap(err)
@@ -724,6 +882,7 @@ func (g *gParser) parseExpr(f *token.FileSet, scope force.Group, n ast.Node) (in
 			return callFunction(fn, arguments)
 		}
 		call := &force.LambdaFunctionCall{
+			Name:       fnName,
 			Expression: lambdaExpression,
 			Arguments:  arguments,
 		}
@@ -758,13 +917,43 @@ func (g *gParser) parseExpr(f *token.FileSet, scope force.Group, n ast.Node) (in
 		ptr := reflect.New(reflect.TypeOf(expr))
 		ptr.Elem().Set(reflect.ValueOf(expr))
 		return ptr.Interface(), nil
-	case *ast.FuncLit:
-		if l.Type.Results != nil && len(l.Type.Results.List) != 0 {
-			return nil, wrap(f, n, trace.BadParameter("functions with return values are not supported"))
+	case *ast.BinaryExpr:
+		x, err := g.parseOperand(f, scope, l.X)
+		if err != nil {
+			return nil, trace.Wrap(err)
 		}
+		y, err := g.parseOperand(f, scope, l.Y)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		expr, err := force.Binary(l.Op, x, y)
+		if err != nil {
+			return nil, wrap(f, n, err)
+		}
+		return expr, nil
+	case *ast.FuncLit:
 		lambda := &force.LambdaFunction{
 			Scope: force.WithLexicalScope(scope),
 		}
+		if l.Type.Results != nil && len(l.Type.Results.List) != 0 {
+			results := l.Type.Results.List
+			if len(results) != 1 || len(results[0].Names) > 1 {
+				return nil, wrap(f, n, trace.BadParameter("functions with multiple return values are not supported"))
+			}
+			if len(results[0].Names) != 0 {
+				return nil, wrap(f, n, trace.BadParameter("named return values are not supported"))
+			}
+			result, err := g.evalFunctionArg(f, lambda.Scope, results[0].Type)
+			if err != nil {
+				return nil, wrap(f, n, trace.Wrap(err))
+			}
+			lambda.Result = result
+		}
+		// return statements in the function body use the function
+		// definition to check the returned values
+		if err := lambda.Scope.AddDefinition(force.KeyReturn, lambda); err != nil {
+			return nil, wrap(f, n, trace.Wrap(err))
+		}
 		if l.Type.Params != nil && len(l.Type.Params.List) != 0 {
 			for i, p := range l.Type.Params.List {
 				if len(p.Names) != 1 {
@@ -791,9 +980,40 @@ func (g *gParser) parseExpr(f *token.FileSet, scope force.Group, n ast.Node) (in
 		if err != nil {
 			return nil, wrap(f, n, trace.Wrap(err))
 		}
+		if err := lambda.CheckReturn(); err != nil {
+			return nil, wrap(f, n, err)
+		}
 		return lambda, nil
 	case *ast.ExprStmt:
 		return g.parseExpr(f, scope, l.X)
+	case *ast.BlockStmt:
+		block, err := g.parseBlock(f, scope, l.List)
+		if err != nil {
+			return nil, wrap(f, n, trace.Wrap(err))
+		}
+		return block, nil
+	case *ast.IfStmt:
+		return g.parseIf(f, scope, l)
+	case *ast.SwitchStmt:
+		return g.parseSwitch(f, scope, l)
+	case *ast.RangeStmt:
+		return g.parseRange(f, scope, l)
+	case *ast.ReturnStmt:
+		return g.parseReturn(f, scope, l)
+	case *ast.IndexExpr:
+		x, err := g.parseOperand(f, scope, l.X)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		index, err := g.parseOperand(f, scope, l.Index)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		expr, err := force.Index(x, index)
+		if err != nil {
+			return nil, wrap(f, n, err)
+		}
+		return expr, nil
 	case *ast.SelectorExpr:
 		fields := []force.String{force.String(l.Sel.Name)}
 	accumulate:
@@ -816,6 +1036,204 @@ func (g *gParser) parseExpr(f *token.FileSet, scope force.Group, n ast.Node) (in
 	}
 }
 
+// parseBlock parses statements of the block in a new lexical scope
+func (g *gParser) parseBlock(f *token.FileSet, scope force.Group, stmts []ast.Stmt) (*force.BlockAction, error) {
+	nodes := make([]ast.Node, len(stmts))
+	for i := range stmts {
+		nodes[i] = stmts[i]
+	}
+	statements, err := g.parseStatements(f, force.WithLexicalScope(scope), nodes)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return force.Block(statements...), nil
+}
+
+// parseInit parses optional init statement of the if or switch statement,
+// the statement is defined in a new lexical scope shared by
+// the condition and all the branches of the statement
+func (g *gParser) parseInit(f *token.FileSet, scope force.Group, n ast.Stmt) (force.Group, force.Action, error) {
+	if n == nil {
+		return scope, nil, nil
+	}
+	initScope := force.WithLexicalScope(scope)
+	val, err := g.parseExpr(f, initScope, n)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
+	init, ok := val.(force.Action)
+	if !ok {
+		return nil, nil, wrap(f, n, trace.BadParameter("expected statement, got %T", val))
+	}
+	return initScope, init, nil
+}
+
+// withInit prepends init statement to the action, if present
+func withInit(init force.Action, action force.Action) force.Action {
+	if init == nil {
+		return action
+	}
+	return force.Block(init, action)
+}
+
+// parseIf lowers if/else statement into conditional action
+func (g *gParser) parseIf(f *token.FileSet, scope force.Group, n *ast.IfStmt) (force.Action, error) {
+	scope, init, err := g.parseInit(f, scope, n.Init)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	cond, err := g.parseOperand(f, scope, n.Cond)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	body, err := g.parseBlock(f, scope, n.Body.List)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var elseAction force.Action
+	switch e := n.Else.(type) {
+	case nil:
+	case *ast.BlockStmt:
+		elseAction, err = g.parseBlock(f, scope, e.List)
+	case *ast.IfStmt:
+		elseAction, err = g.parseIf(f, scope, e)
+	default:
+		return nil, wrap(f, n.Else, trace.BadParameter("unsupported else statement %T", n.Else))
+	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	action, err := force.IfStmt(cond, body, elseAction)
+	if err != nil {
+		return nil, wrap(f, n, err)
+	}
+	return withInit(init, action), nil
+}
+
+// parseSwitch lowers switch statement into switch action
+func (g *gParser) parseSwitch(f *token.FileSet, scope force.Group, n *ast.SwitchStmt) (force.Action, error) {
+	scope, init, err := g.parseInit(f, scope, n.Init)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var tag force.Expression
+	if n.Tag != nil {
+		tag, err = g.parseOperand(f, scope, n.Tag)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	cases := make([]force.SwitchCase, 0, len(n.Body.List))
+	for _, stmt := range n.Body.List {
+		clause, ok := stmt.(*ast.CaseClause)
+		if !ok {
+			return nil, wrap(f, stmt, trace.BadParameter("expected case clause, got %T", stmt))
+		}
+		var c force.SwitchCase
+		for _, v := range clause.List {
+			val, err := g.parseOperand(f, scope, v)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			c.Values = append(c.Values, val)
+		}
+		for _, s := range clause.Body {
+			if branch, ok := s.(*ast.BranchStmt); ok {
+				return nil, wrap(f, s, trace.BadParameter("%v is not supported", branch.Tok))
+			}
+		}
+		c.Body, err = g.parseBlock(f, scope, clause.Body)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		cases = append(cases, c)
+	}
+	action, err := force.Switch(tag, cases...)
+	if err != nil {
+		return nil, wrap(f, n, err)
+	}
+	return withInit(init, action), nil
+}
+
+// parseRange lowers for/range statement into range action
+func (g *gParser) parseRange(f *token.FileSet, scope force.Group, n *ast.RangeStmt) (force.Action, error) {
+	if n.Key != nil && n.Tok != token.DEFINE {
+		return nil, wrap(f, n, trace.BadParameter("only := is supported in range statements"))
+	}
+	var names [2]string
+	for i, node := range []ast.Expr{n.Key, n.Value} {
+		if node == nil {
+			continue
+		}
+		id, ok := node.(*ast.Ident)
+		if !ok {
+			return nil, wrap(f, node, trace.BadParameter("expected identifier, got %T", node))
+		}
+		names[i] = id.Name
+	}
+	list, err := g.parseOperand(f, scope, n.X)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	// index and element variables are defined in the scope
+	// of the loop, so the body could redefine them
+	loopScope := force.WithLexicalScope(scope)
+	if err := force.RangeVars(loopScope, list, names[0], names[1]); err != nil {
+		return nil, wrap(f, n, err)
+	}
+	body, err := g.parseBlock(f, loopScope, n.Body.List)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	action, err := force.Range(list, names[0], names[1], body)
+	if err != nil {
+		return nil, wrap(f, n, err)
+	}
+	return action, nil
+}
+
+// parseReturn parses return statement of the enclosing lambda function
+func (g *gParser) parseReturn(f *token.FileSet, scope force.Group, n *ast.ReturnStmt) (force.Action, error) {
+	def, err := scope.GetDefinition(force.KeyReturn)
+	if err != nil {
+		return nil, wrap(f, n, trace.BadParameter("return statement outside of function"))
+	}
+	lambda, ok := def.(*force.LambdaFunction)
+	if !ok {
+		return nil, wrap(f, n, trace.BadParameter("expected function, got %T", def))
+	}
+	var value force.Expression
+	switch len(n.Results) {
+	case 0:
+	case 1:
+		value, err = g.parseOperand(f, scope, n.Results[0])
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	default:
+		return nil, wrap(f, n, trace.BadParameter("multiple return values are not supported"))
+	}
+	action, err := force.Return(lambda, value)
+	if err != nil {
+		return nil, wrap(f, n, err)
+	}
+	return action, nil
+}
+
+// parseOperand parses node that should evaluate to expression,
+// for example an operand of the binary expression or a condition
+func (g *gParser) parseOperand(f *token.FileSet, scope force.Group, n ast.Node) (force.Expression, error) {
+	val, err := g.parseExpr(f, scope, n)
+	if err != nil {
+		return nil, wrap(f, n, trace.Wrap(err))
+	}
+	expr, ok := val.(force.Expression)
+	if !ok {
+		return nil, wrap(f, n, trace.BadParameter("expected expression, got %T", val))
+	}
+	return expr, nil
+}
+
 func (g *gParser) evalFunctionArg(f *token.FileSet, scope force.Group, n ast.Node) (interface{}, error) {
 	switch l := n.(type) {
 	case *ast.Ident:
@@ -834,6 +1252,16 @@ func (g *gParser) evalFunctionArg(f *token.FileSet, scope force.Group, n ast.Nod
 			return force.BoolSlice(nil), nil
 		}
 		return nil, wrap(f, n, trace.BadParameter("%T is not supported", n))
+	case *ast.MapType:
+		keyType, valueType, err := g.parseMapType(f, l)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		m, err := force.Map(keyType, valueType, nil, nil)
+		if err != nil {
+			return nil, wrap(f, n, err)
+		}
+		return m, nil
 	case *ast.StructType:
 		structFields := make([]reflect.StructField, len(l.Fields.List))
 		for i := range l.Fields.List {
@@ -921,6 +1349,12 @@ func literalToValue(a *ast.BasicLit) (interface{}, error) {
 			return nil, trace.BadParameter("failed to parse argument: %s, error: %s", a.Value, err)
 		}
 		return force.Int(value), nil
+	case token.FLOAT:
+		value, err := strconv.ParseFloat(a.Value, 64)
+		if err != nil {
+			return nil, trace.BadParameter("failed to parse argument: %s, error: %s", a.Value, err)
+		}
+		return force.Float(value), nil
 	case token.STRING:
 		value, err := strconv.Unquote(a.Value)
 		if err != nil {
@@ -931,6 +1365,59 @@ func literalToValue(a *ast.BasicLit) (interface{}, error) {
 	return nil, trace.BadParameter("unsupported function argument type: '%v'", a.Kind)
 }
 
+// isLiteralType returns true if the type name is one of the literal types
+func isLiteralType(kind string) bool {
+	switch kind {
+	case force.StringType, force.IntType, force.BoolType:
+		return true
+	}
+	return false
+}
+
+// parseMapType returns zero values of the map key and value types
+func (g *gParser) parseMapType(f *token.FileSet, n *ast.MapType) (interface{}, interface{}, error) {
+	var out [2]interface{}
+	for i, node := range []ast.Expr{n.Key, n.Value} {
+		id, ok := node.(*ast.Ident)
+		if !ok || !isLiteralType(id.Name) {
+			return nil, nil, wrap(f, node, trace.BadParameter("unsupported map type, supported are string, int and bool keys and values"))
+		}
+		zero, err := literalZeroValue(id.Name)
+		if err != nil {
+			return nil, nil, wrap(f, node, err)
+		}
+		out[i] = zero
+	}
+	return out[0], out[1], nil
+}
+
+// parseLiteralSlice parses composite literal of the slice of strings, ints or bools
+func (g *gParser) parseLiteralSlice(f *token.FileSet, scope force.Group, kind string, nodes []ast.Expr) (interface{}, error) {
+	proto, err := literalZeroValue(kind)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	out := make([]force.Expression, len(nodes))
+	for i, n := range nodes {
+		expr, err := g.parseOperand(f, scope, n)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		if err := force.ExpectEqualTypes(expr, proto); err != nil {
+			return nil, wrap(f, n, trace.BadParameter("expected %v, got %v", kind, expr.Type()))
+		}
+		out[i] = expr
+	}
+	switch kind {
+	case force.StringType:
+		return force.StringSlice(out), nil
+	case force.IntType:
+		return force.IntSlice(out), nil
+	default:
+		return force.BoolSlice(out), nil
+	}
+}
+
 func literalZeroValue(kind string) (interface{}, error) {
 	switch kind {
 	case "string":
@@ -939,6 +1426,8 @@ func literalZeroValue(kind string) (interface{}, error) {
 		return force.Int(0), nil
 	case "bool":
 		return force.Bool(false), nil
+	case "float64":
+		return force.Float(0), nil
 	}
 	return nil, trace.BadParameter("unsupported function argument type: '%v'", kind)
 }
@@ -0,0 +1,873 @@
+package runner
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitational/force"
+	"github.com/gravitational/force/pkg/builder"
+	"github.com/gravitational/force/pkg/ssh"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+// Bootstrap check
+func Test(t *testing.T) { check.TestingT(t) }
+
+type ParserSuite struct {
+}
+
+var _ = check.Suite(&ParserSuite{})
+
+// newTestParser returns a parser bound to a new runner
+func newTestParser(c *check.C) *gParser {
+	runner := newRunner(Input{Context: context.Background()})
+	g, err := newParser("test", runner)
+	c.Assert(err, check.IsNil)
+	return g
+}
+
+// parseExpr parses code into expression
+func parseExpr(g *gParser, code string) (force.Expression, error) {
+	f := token.NewFileSet()
+	expr, err := parser.ParseExprFrom(f, "", []byte(code), 0)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	out, err := g.parseExpr(f, g.runner, expr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	e, ok := out.(force.Expression)
+	if !ok {
+		return nil, trace.BadParameter("expected expression, got %T", out)
+	}
+	return e, nil
+}
+
+// evalExpr parses and evaluates code in the global scope of the parser
+func evalExpr(g *gParser, code string) (interface{}, error) {
+	expr, err := parseExpr(g, code)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if lambda, ok := expr.(*force.LambdaFunction); ok {
+		return lambda.Call(force.WithRuntimeScope(g.scope))
+	}
+	return expr.Eval(force.WithRuntimeScope(g.scope))
+}
+
+// TestVarRefType checks that references to variables and fields
+// that are not expressions have the type of their values
+func (s *ParserSuite) TestVarRefType(c *check.C) {
+	type build struct {
+		Name string
+		Tags []string
+	}
+	g := newTestParser(c)
+	c.Assert(g.runner.AddDefinition("build", build{Tags: []string{}}), check.IsNil)
+
+	expr, err := parseExpr(g, "build.Name")
+	c.Assert(err, check.IsNil)
+	c.Assert(expr.Type(), check.Equals, "")
+	expr, err = parseExpr(g, "build")
+	c.Assert(err, check.IsNil)
+	c.Assert(force.ExpectEqualTypes(expr.Type(), build{}), check.IsNil)
+
+	ctx := force.WithRuntimeScope(g.scope)
+	ctx.SetValue(force.ContextKey("build"), build{Name: "release", Tags: []string{"a", "b"}})
+	out, err := parseExpr(g, `func() string {
+		for i, tag := range build.Tags {
+			if i == 1 {
+				return Sprintf("%v:%v", build.Name, tag)
+			}
+		}
+		return ""
+	}`)
+	c.Assert(err, check.IsNil)
+	result, err := out.(*force.LambdaFunction).Call(ctx)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.Equals, "release:b")
+}
+
+func (s *ParserSuite) TestBinaryExpressions(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `1 + 2 * 3`, expected: 7},
+		{code: `(1 + 2) * 3`, expected: 9},
+		{code: `7 / 2`, expected: 3},
+		{code: `7 % 2`, expected: 1},
+		{code: `10 - 3 - 2`, expected: 5},
+		{code: `"v" + "1.0"`, expected: "v1.0"},
+		{code: `"master" == "master"`, expected: true},
+		{code: `"master" != "master"`, expected: false},
+		{code: `3 > 2`, expected: true},
+		{code: `3 <= 2`, expected: false},
+		{code: `"a" < "b"`, expected: true},
+		{code: `true && false`, expected: false},
+		{code: `true || false`, expected: true},
+		{code: `1 < 2 && "a" == "a"`, expected: true},
+		{code: `func(){ver := "1.0"; "v" + ver}`, expected: "v1.0"},
+		{code: `func(){count := 4; count > 3}`, expected: true},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		out, err := evalExpr(g, tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.DeepEquals, tc.expected, comment)
+	}
+}
+
+func (s *ParserSuite) TestBinaryShortCircuit(c *check.C) {
+	g := newTestParser(c)
+	// the right side is not evaluated, otherwise division by zero
+	// would have failed the expression
+	out, err := evalExpr(g, `false && 1 / 0 == 1`)
+	c.Assert(err, check.IsNil)
+	c.Assert(out, check.Equals, false)
+
+	out, err = evalExpr(g, `true || 1 / 0 == 1`)
+	c.Assert(err, check.IsNil)
+	c.Assert(out, check.Equals, true)
+
+	_, err = evalExpr(g, `true && 1 / 0 == 1`)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *ParserSuite) TestBinaryTypeErrors(c *check.C) {
+	testCases := []string{
+		`1 + "a"`,
+		`"a" - "b"`,
+		`1 && true`,
+		`"a" == 1`,
+		`true < false`,
+		`Strings("a") == Strings("a")`,
+	}
+	for i, code := range testCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		_, err := parseExpr(g, code)
+		c.Assert(err, check.NotNil, comment)
+	}
+}
+
+func (s *ParserSuite) TestBinaryMarshal(c *check.C) {
+	g := newTestParser(c)
+	expr, err := parseExpr(g, `1 + 2 * 3 == 7 && "a" != "b"`)
+	c.Assert(err, check.IsNil)
+	data, err := force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, `(((1 + (2 * 3)) == 7) && ("a" != "b"))`)
+
+	// marshaled code parses back to the same value
+	out, err := evalExpr(newTestParser(c), string(data))
+	c.Assert(err, check.IsNil)
+	c.Assert(out, check.Equals, true)
+}
+
+func (s *ParserSuite) TestIfStatements(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `func(){ if 1 < 2 { "b" } }`, expected: "b"},
+		{code: `func(){ if 1 > 2 { "b" } }`, expected: false},
+		{code: `func(){ if 1 > 2 { "b" } else { "c" } }`, expected: "c"},
+		{code: `func(){ if 1 > 2 { "b" } else if 2 > 1 { "c" } else { "d" } }`, expected: "c"},
+		{code: `func(){ if 1 > 2 { "b" } else if 3 > 4 { "c" } else { 4 } }`, expected: 4},
+		{code: `func(){ if v := 3; v > 2 { "v" } }`, expected: "v"},
+		{code: `func(){ if v := 3; v > 4 { "a" } else if w := v + 1; w == 4 { "b" } }`, expected: "b"},
+		{code: `func(){ {"block"} }`, expected: "block"},
+		{code: `func(){ if true {} }`, expected: false},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		out, err := evalExpr(g, tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.DeepEquals, tc.expected, comment)
+	}
+}
+
+func (s *ParserSuite) TestSwitchStatements(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `func(){ switch "main" { case "master", "main": "ok"; default: "no" } }`, expected: "ok"},
+		{code: `func(){ switch "dev" { case "master", "main": "ok"; default: "no" } }`, expected: "no"},
+		{code: `func(){ switch "dev" { case "master": "ok" } }`, expected: false},
+		{code: `func(){ switch { case 1 > 2: "a"; case 2 > 1: "b" } }`, expected: "b"},
+		{code: `func(){ switch v := 2 + 2; v { case 3: "three"; case 4: "four" } }`, expected: "four"},
+		{code: `func(){ switch { default: "d"; case true: "t" } }`, expected: "t"},
+		// times in different locations are equal if they denote the same instant
+		{code: `func(){ switch ParseTime("2006-01-02T15:04:05Z07:00", "2019-01-01T00:00:00Z") { case ParseTime("2006-01-02T15:04:05Z07:00", "2019-01-01T02:00:00+02:00"): "same"; default: "different" } }`, expected: "same"},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		out, err := evalExpr(g, tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.DeepEquals, tc.expected, comment)
+	}
+}
+
+func (s *ParserSuite) TestStatementScopes(c *check.C) {
+	testCases := []string{
+		// variables defined in the branch are not visible outside
+		`func(){ if true { v := 1 }; v }`,
+		`func(){ if v := 1; true { }; v }`,
+		`func(){ switch { case true: v := 1 }; v }`,
+		// condition should evaluate to bool
+		`func(){ if 1 { } }`,
+		`func(){ switch { case 1: "a" } }`,
+		`func(){ switch 1 { case "a": "a" } }`,
+		`func(){ switch { default: "a"; default: "b" } }`,
+		`func(){ switch { case true: fallthrough; default: "b" } }`,
+	}
+	for i, code := range testCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		_, err := parseExpr(g, code)
+		c.Assert(err, check.NotNil, comment)
+	}
+}
+
+func (s *ParserSuite) TestStatementsMarshal(c *check.C) {
+	testCases := []string{
+		`func(){ if v := 3; v > 4 { "a" } else if w := v + 1; w == 4 { "b" } else { "c" } }`,
+		`func(){ switch v := 2 + 2; v { case 3: "three"; case 4, 5: "four"; default: "other" } }`,
+	}
+	for i, code := range testCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		expr, err := parseExpr(g, code)
+		c.Assert(err, check.IsNil, comment)
+		data, err := force.MarshalCode(g.scope, expr)
+		c.Assert(err, check.IsNil, comment)
+
+		// marshaled code evaluates to the same value
+		expected, err := evalExpr(g, code)
+		c.Assert(err, check.IsNil, comment)
+		out, err := evalExpr(newTestParser(c), string(data))
+		c.Assert(err, check.IsNil, check.Commentf("test case %v %v", i, string(data)))
+		c.Assert(out, check.DeepEquals, expected, comment)
+	}
+}
+
+func (s *ParserSuite) TestRangeStatements(c *check.C) {
+	type testCase struct {
+		code string
+		err  bool
+	}
+	testCases := []testCase{
+		{code: `func(){ for _, x := range []int{1, 3} { 1 / (x - 2) } }`},
+		{code: `func(){ for _, x := range []int{1, 2, 3} { 1 / (x - 2) } }`, err: true},
+		{code: `func(){ for i := range Strings("a", "b", "c") { 1 / (i - 2) } }`, err: true},
+		{code: `func(){ for i, s := range Strings("a", "b") { if i == 1 && s == "b" { 1 / 0 } } }`, err: true},
+		{code: `func(){ for range []bool{true} { 1 / 0 } }`, err: true},
+		{code: `func(){ for range []bool{} { 1 / 0 } }`},
+		// loop variables could be redefined in the body
+		{code: `func(){ for _, x := range []int{1} { x := x + 1; 1 / (x - 2) } }`, err: true},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		_, err := evalExpr(g, tc.code)
+		if tc.err {
+			c.Assert(err, check.NotNil, comment)
+		} else {
+			c.Assert(err, check.IsNil, comment)
+		}
+	}
+
+	errCases := []string{
+		// loop variables are not visible outside of the loop
+		`func(){ for _, x := range []int{1} { }; x }`,
+		`func(){ for _, x := range "abc" { } }`,
+		`func(){ x := 1; for _, x = range []int{1} { } }`,
+		`func(){ for _, x := range []int{1} { break } }`,
+		`func(){ for _, x := range []int{1} { x + "a" } }`,
+	}
+	for i, code := range errCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		_, err := parseExpr(g, code)
+		c.Assert(err, check.NotNil, comment)
+	}
+}
+
+func (s *ParserSuite) TestForEach(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `ForEach([]int{1, 2, 3}, func(x int){ x * 2 })`, expected: []int{2, 4, 6}},
+		{code: `ForEach(Strings("a", "b"), func(s string){ "v" + s })`, expected: []string{"va", "vb"}},
+		{code: `ForEach([]int{}, func(x int){ x * 2 })`, expected: []int{}},
+		{code: `ForEach([]int{1}, func(x int){ x * 2 }, 4)`, expected: []int{2}},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		out, err := evalExpr(g, tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.DeepEquals, tc.expected, comment)
+	}
+
+	errCases := []string{
+		`ForEach([]int{1}, func(x string){ x })`,
+		`ForEach([]int{1}, func(){ 1 })`,
+		`ForEach([]int{1}, func(x int){ x }, "a")`,
+		`ForEach(1, func(x int){ x })`,
+	}
+	for i, code := range errCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		_, err := parseExpr(g, code)
+		c.Assert(err, check.NotNil, comment)
+	}
+
+	// parallel calls fail if any of the calls fail
+	g := newTestParser(c)
+	_, err := evalExpr(g, `ForEach([]int{1, 2, 3}, func(x int){ 1 / (x - 2) }, 3)`)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *ParserSuite) TestRangeMarshal(c *check.C) {
+	testCases := []string{
+		`func(){ for i, x := range []int{1, 2, 3} { 1 / (x - i - 1) } }`,
+		`func(){ for range Strings("a") { 1 / 0 } }`,
+	}
+	for i, code := range testCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		expr, err := parseExpr(g, code)
+		c.Assert(err, check.IsNil, comment)
+		data, err := force.MarshalCode(g.scope, expr)
+		c.Assert(err, check.IsNil, comment)
+
+		_, expectedErr := evalExpr(g, code)
+		_, err = evalExpr(newTestParser(c), string(data))
+		c.Assert(err == nil, check.Equals, expectedErr == nil, check.Commentf("test case %v %v", i, string(data)))
+	}
+}
+
+func (s *ParserSuite) TestReturnValues(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `func() int { return 1 }()`, expected: 1},
+		{code: `func(x int) string { if x > 1 { return "big" }; return "small" }(2)`, expected: "big"},
+		{code: `func(x int) string { if x > 1 { return "big" } else { return "small" } }(1)`, expected: "small"},
+		{code: `func() int { for _, x := range []int{1, 2, 3} { if x == 2 { return x * 10 } }; return 0 }()`, expected: 20},
+		{code: `func() string { switch 2 { case 1: return "one"; default: return "other" } }()`, expected: "other"},
+		{code: `func() bool { return 1 < 2 }()`, expected: true},
+		{code: `func() []string { return Strings("a", "b") }()`, expected: []string{"a", "b"}},
+		// function without return values could return early
+		{code: `func() { if true { return }; 1 / 0 }`, expected: 0},
+		// statements after return are not evaluated
+		{code: `func() string { if true { return "a" }; 1 / 0; return "b" }()`, expected: "a"},
+		// returned value is usable in expressions
+		{code: `func(){ double := func(x int) int { return x * 2 }; double(2) + 1 }`, expected: 5},
+		{code: `ForEach([]int{1, 2}, func(x int) string { if x == 1 { return "one" }; return "two" })`, expected: []string{"one", "two"}},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		out, err := evalExpr(g, tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.DeepEquals, tc.expected, comment)
+	}
+}
+
+func (s *ParserSuite) TestReturnTypes(c *check.C) {
+	g := newTestParser(c)
+	expr, err := parseExpr(g, `func(x int) string { return "a" }(1)`)
+	c.Assert(err, check.IsNil)
+	c.Assert(expr.Type(), check.Equals, "")
+
+	// type of the call is reported from the signature
+	expr, err = parseExpr(g, `func() int { if true { return 1 } else { return 2 } }()`)
+	c.Assert(err, check.IsNil)
+	c.Assert(expr.Type(), check.Equals, 0)
+
+	errCases := []string{
+		`func() int { return "a" }`,
+		`func() int { return }`,
+		`func() { return 1 }`,
+		`func() int { 1 }`,
+		`func() int { if true { return 1 } }`,
+		`func() int { switch { case true: return 1 } }`,
+		`func() (int, string) { return 1, "a" }`,
+		`func() (x int) { return 1 }`,
+		`func() int { return 1 }() + "a"`,
+		`func(){ f := func() int { return 1 }; f() + "a" }`,
+	}
+	for i, code := range errCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		_, err := parseExpr(g, code)
+		c.Assert(err, check.NotNil, comment)
+	}
+}
+
+func (s *ParserSuite) TestReturnMarshal(c *check.C) {
+	code := `func(x int, s string) string { if x > 1 { return s + "!" }; return s }`
+	g := newTestParser(c)
+	expr, err := parseExpr(g, code)
+	c.Assert(err, check.IsNil)
+	data, err := force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+
+	g = newTestParser(c)
+	out, err := evalExpr(g, "func(){ f := "+string(data)+"\n f(2, \"a\") }")
+	c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+	c.Assert(out, check.Equals, "a!")
+}
+
+func (s *ParserSuite) TestMaps(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `map[string]string{"app": "nginx", "env": "prod"}`, expected: map[string]string{"app": "nginx", "env": "prod"}},
+		{code: `map[string]int{}`, expected: map[string]int{}},
+		{code: `map[string]string{"app": "nginx"}["app"]`, expected: "nginx"},
+		{code: `map[string]int{"a": 1}["b"]`, expected: 0},
+		{code: `Strings("a", "b")[1]`, expected: "b"},
+		{code: `func(){ labels := map[string]string{"app": "nginx"}; labels["app"] + "-1" }`, expected: "nginx-1"},
+		{code: `func(){ key := "env"; map[string]bool{key: true}[key] }`, expected: true},
+		{code: `func(){ get := func(m map[string]string, key string) string { return m[key] }; get(map[string]string{"a": "b"}, "a") }`, expected: "b"},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		out, err := evalExpr(g, tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.DeepEquals, tc.expected, comment)
+	}
+
+	errCases := []string{
+		`map[string]string{"a": 1}`,
+		`map[string]string{1: "a"}`,
+		`map[string][]string{}`,
+		`map[string]string{"a": "b"}[1]`,
+		`Strings("a")["a"]`,
+		`"abc"[1]`,
+		`func(){ get := func(m map[string]string) string { return m["a"] }; get(map[string]int{}) }`,
+	}
+	for i, code := range errCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		_, err := parseExpr(g, code)
+		c.Assert(err, check.NotNil, comment)
+	}
+
+	// duplicate keys and out of range indexes fail when evaluated
+	for _, code := range []string{`map[string]int{"a": 1, "a": 2}`, `Strings("a")[2]`} {
+		_, err := evalExpr(newTestParser(c), code)
+		c.Assert(err, check.NotNil, check.Commentf(code))
+	}
+}
+
+func (s *ParserSuite) TestMapStructFields(c *check.C) {
+	testCases := []string{
+		`builder.Image{Tag: "a", BuildArgs: map[string]string{"VERSION": "1.0"}}`,
+		`builder.Image{Tag: "a", BuildArgs: _{VERSION: "1.0"}}`,
+		`builder.Image{Tag: "a", BuildArgs: args}`,
+	}
+	for i, code := range testCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		g := newTestParser(c)
+		ctx := force.WithRuntimeScope(g.scope)
+
+		// define variable referenced by the struct
+		f := token.NewFileSet()
+		stmt, err := parser.ParseExprFrom(f, "", []byte(`map[string]string{"VERSION": "1.0"}`), 0)
+		c.Assert(err, check.IsNil)
+		val, err := g.parseExpr(f, g.runner, stmt)
+		c.Assert(err, check.IsNil)
+		def, err := force.Define(g.runner)("args", val)
+		c.Assert(err, check.IsNil)
+		_, err = def.Eval(ctx)
+		c.Assert(err, check.IsNil)
+
+		expr, err := parser.ParseExprFrom(f, "", []byte(code), 0)
+		c.Assert(err, check.IsNil, comment)
+		out, err := g.parseExpr(f, g.runner, expr)
+		c.Assert(err, check.IsNil, comment)
+		var image builder.Image
+		err = force.EvalInto(ctx, out, &image)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(image.BuildArgs, check.DeepEquals, map[string]string{"VERSION": "1.0"}, comment)
+	}
+}
+
+func (s *ParserSuite) TestMapMarshal(c *check.C) {
+	g := newTestParser(c)
+	expr, err := parseExpr(g, `map[string]int{"a": 1, "b": 2}["b"]`)
+	c.Assert(err, check.IsNil)
+	data, err := force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, `map[string]int{"a": 1, "b": 2}["b"]`)
+
+	expr, err = parseExpr(g, `func(m map[string]bool) bool { return m["a"] }`)
+	c.Assert(err, check.IsNil)
+	data, err = force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+	out, err := evalExpr(newTestParser(c), "func(){ f := "+string(data)+"\n f(map[string]bool{\"a\": true}) }")
+	c.Assert(err, check.IsNil, check.Commentf(string(data)))
+	c.Assert(out, check.Equals, true)
+}
+
+func (s *ParserSuite) TestFloats(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `1.5 + 2.25`, expected: 3.75},
+		{code: `3.0 / 2.0`, expected: 1.5},
+		{code: `0.5 * 4.0 > 1.5`, expected: true},
+		{code: `func(x float64) float64 { return x * 2.0 }(1.25)`, expected: 2.5},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		out, err := evalExpr(newTestParser(c), tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.Equals, tc.expected, comment)
+	}
+
+	for i, code := range []string{`1.5 + 1`, `1.5 % 2.0`, `1.0 / 0.0`} {
+		comment := check.Commentf("test case %v %v", i, code)
+		_, err := evalExpr(newTestParser(c), code)
+		c.Assert(err, check.NotNil, comment)
+	}
+}
+
+func (s *ParserSuite) TestDurationsAndTimes(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `ParseDuration("1h") + ParseDuration("30m")`, expected: 90 * time.Minute},
+		{code: `ParseDuration("10s") * 3`, expected: 30 * time.Second},
+		{code: `ParseDuration("1m") > ParseDuration("59s")`, expected: true},
+		{code: `FormatTime(ParseTime("2006-01-02", "2019-10-01") + ParseDuration("24h"), "2006-01-02")`, expected: "2019-10-02"},
+		{code: `ParseTime("2006-01-02", "2019-10-02") - ParseTime("2006-01-02", "2019-10-01")`, expected: 24 * time.Hour},
+		{code: `ParseTime("2006-01-02", "2019-10-01") < Now() - ParseDuration("24h")`, expected: true},
+		{code: `ParseTime("2006-01-02T15:04:05Z07:00", "2019-10-01T00:00:00Z") == ParseTime("2006-01-02", "2019-10-01")`, expected: true},
+		{code: `Since(Now()) < ParseDuration("1h")`, expected: true},
+		{code: `Created() <= Now()`, expected: true},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		out, err := evalExpr(newTestParser(c), tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.Equals, tc.expected, comment)
+	}
+
+	errors := []string{
+		`ParseDuration("1 hour")`,
+		`ParseDuration(1)`,
+		`Now() + Now()`,
+		`ParseDuration("1h") + 1`,
+		`Since(ParseDuration("1h"))`,
+	}
+	for i, code := range errors {
+		comment := check.Commentf("test case %v %v", i, code)
+		_, err := evalExpr(newTestParser(c), code)
+		c.Assert(err, check.NotNil, comment)
+	}
+}
+
+func (s *ParserSuite) TestDurationStructFields(c *check.C) {
+	type testCase struct {
+		code     string
+		expected time.Duration
+	}
+	testCases := []testCase{
+		{code: `ssh.Config{User: "bob", DialTimeout: "10s"}`, expected: 10 * time.Second},
+		{code: `ssh.Config{User: "bob", DialTimeout: ParseDuration("1m") * 2}`, expected: 2 * time.Minute},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		g := newTestParser(c)
+		f := token.NewFileSet()
+		expr, err := parser.ParseExprFrom(f, "", []byte(tc.code), 0)
+		c.Assert(err, check.IsNil, comment)
+		out, err := g.parseExpr(f, g.runner, expr)
+		c.Assert(err, check.IsNil, comment)
+		var cfg ssh.Config
+		err = force.EvalInto(force.WithRuntimeScope(g.scope), out, &cfg)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(cfg.DialTimeout, check.Equals, tc.expected, comment)
+	}
+
+	g := newTestParser(c)
+	_, err := parseExpr(g, `ssh.Config{User: "bob", DialTimeout: "ten seconds"}`)
+	c.Assert(err, check.NotNil)
+}
+
+func (s *ParserSuite) TestTimeMarshal(c *check.C) {
+	g := newTestParser(c)
+	expr, err := parseExpr(g, `ParseDuration("90s") + ParseDuration("1m") * 2 > ParseDuration("3m")`)
+	c.Assert(err, check.IsNil)
+	data, err := force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(data), check.Equals, `((ParseDuration("1m30s") + (ParseDuration("1m0s") * 2)) > ParseDuration("3m0s"))`)
+	out, err := evalExpr(newTestParser(c), string(data))
+	c.Assert(err, check.IsNil)
+	c.Assert(out, check.Equals, true)
+
+	// evaluated times and floats are marshaled back to code
+	for _, code := range []string{`ParseTime("2006-01-02", "2019-10-01")`, `3.0 / 2.0`, `2.0 * 2.0`} {
+		comment := check.Commentf(code)
+		expected, err := evalExpr(newTestParser(c), code)
+		c.Assert(err, check.IsNil, comment)
+		data, err := force.MarshalCode(g.scope, expected)
+		c.Assert(err, check.IsNil, comment)
+		out, err := evalExpr(newTestParser(c), string(data))
+		c.Assert(err, check.IsNil, check.Commentf(string(data)))
+		c.Assert(out, check.Equals, expected, check.Commentf(string(data)))
+	}
+}
+
+func (s *ParserSuite) TestTryCatchFinally(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+		err      bool
+	}
+	testCases := []testCase{
+		{code: `Try(func() int { return 2 }())`, expected: 2},
+		{code: `Try(func() int { x := 0; return 1 / x }(), Catch(func(err string){ if err != Error() { 1 / 0 } }))`, expected: 0},
+		{code: `Try(func(){ x := 0; 1 / x }(), Catch(func(err string){ x := 0; 1 / x }))`, err: true},
+		{code: `Try(func(){ 1 }(), Finally(func(){ x := 0; 1 / x }()))`, err: true},
+		{code: `Try(func(){ x := 0; 1 / x }(), Finally(func(){ if Error() == "" { Exit() } }()))`, err: true},
+		{code: `Try(func(){ x := 0; 1 / x }(), Catch(func(err string){}), Finally(func(){ if Error() != "" { 1 / 0 } }()))`, expected: 0},
+		{code: `func() string { Try(func(){ x := 0; 1 / x }(), Catch(func(err string){})); return Error() }()`, expected: ""},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		out, err := evalExpr(newTestParser(c), tc.code)
+		if tc.err {
+			c.Assert(err, check.NotNil, comment)
+			continue
+		}
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.Equals, tc.expected, comment)
+	}
+
+	errCases := []string{
+		`Try(func(){ 1 }(), Catch(func(){}))`,
+		`Try(func(){ 1 }(), Catch(func(err string){}), Catch(func(err string){}))`,
+		`Try(func(){ 1 }(), Finally(func(){ 1 }()), Catch(func(err string){}))`,
+		`Try(func(){ 1 }(), 1)`,
+	}
+	for i, code := range errCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		_, err := parseExpr(newTestParser(c), code)
+		c.Assert(err, check.NotNil, comment)
+	}
+
+	// the error is available to deferred actions
+	_, err := evalExpr(newTestParser(c), `func(){ Defer(func(){ if Error() == "" { Exit() } }()); x := 0; 1 / x }`)
+	c.Assert(err, check.NotNil)
+	c.Assert(strings.Contains(err.Error(), "division"), check.Equals, true, check.Commentf("%v", err))
+
+	// code is marshaled back into Try, Catch and Finally calls
+	code := `func(){ Try(func(){ x := 0; 1 / x }(), Catch(func(err string){ Infof("%v", Error()) }), Finally(func(){ 1 }())) }`
+	g := newTestParser(c)
+	expr, err := parseExpr(g, code)
+	c.Assert(err, check.IsNil)
+	data, err := force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+	_, err = evalExpr(newTestParser(c), string(data))
+	c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+}
+
+// recordAction records its name when evaluated
+type recordAction struct {
+	name string
+	out  *[]string
+}
+
+func (r *recordAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	*r.out = append(*r.out, r.name)
+	return r.name, nil
+}
+
+func (r *recordAction) Type() interface{} {
+	return ""
+}
+
+func (r *recordAction) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	return nil, trace.NotImplemented("record action can not be marshaled")
+}
+
+// TestSequenceDefer checks that deferred actions run once,
+// in the reverse order, after the other actions of the sequence
+func (s *ParserSuite) TestSequenceDefer(c *check.C) {
+	g := newTestParser(c)
+	defer g.runner.cancel()
+	var out []string
+	seq, err := force.Sequence(
+		force.Defer(&recordAction{name: "a", out: &out}),
+		force.Defer(&recordAction{name: "b", out: &out}),
+		&recordAction{name: "c", out: &out},
+	)
+	c.Assert(err, check.IsNil)
+	last, err := seq.Eval(force.WithRuntimeScope(g.scope))
+	c.Assert(err, check.IsNil)
+	c.Assert(last, check.Equals, "c")
+	c.Assert(out, check.DeepEquals, []string{"c", "b", "a"})
+}
+
+func (s *ParserSuite) TestRetryAndTimeout(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		{code: `Attempt()`, expected: 0},
+		{code: `Retry(RetryPolicy{Initial: "1ms"}, func() int { if Attempt() < 3 { x := 0; 1 / x }; return Attempt() }())`, expected: 3},
+		{code: `Retry(RetryPolicy{Attempts: 1}, func() int { return Attempt() }())`, expected: 1},
+		{code: `Timeout("1m", func() int { return 1 }())`, expected: 1},
+		{code: `Timeout(ParseDuration("1s") * 60, func() string { return "a" }())`, expected: "a"},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		out, err := evalExpr(newTestParser(c), tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.Equals, tc.expected, comment)
+	}
+
+	errCases := []string{
+		`Retry(RetryPolicy{Attempts: 2, Initial: "1ms"}, func(){ if Attempt() < 3 { x := 0; 1 / x } }())`,
+		`Retry(RetryPolicy{Attempts: -1}, func(){ 1 }())`,
+		`Retry(RetryPolicy{Multiplier: 0.5}, func(){ 1 }())`,
+		`Timeout("0s", func(){ 1 }())`,
+	}
+	for i, code := range errCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		_, err := evalExpr(newTestParser(c), code)
+		c.Assert(err, check.NotNil, comment)
+	}
+
+	_, err := parseExpr(newTestParser(c), `Timeout("ten minutes", func(){ 1 }())`)
+	c.Assert(err, check.NotNil)
+
+	// timeout cancels shell commands
+	start := time.Now()
+	_, err = evalExpr(newTestParser(c), `Timeout("100ms", Command("sleep 10"))`)
+	c.Assert(trace.IsLimitExceeded(err), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(time.Since(start) < 5*time.Second, check.Equals, true)
+
+	// code is marshaled back into Retry and Timeout calls
+	code := `func() int { return Timeout("1m", Retry(RetryPolicy{Attempts: 2, Initial: "1ms"}, func() int { return Attempt() }())) }`
+	g := newTestParser(c)
+	expr, err := parseExpr(g, code)
+	c.Assert(err, check.IsNil)
+	data, err := force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+	out, err := evalExpr(newTestParser(c), string(data))
+	c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+	c.Assert(out, check.Equals, 1)
+}
+
+func (s *ParserSuite) TestParallelWith(c *check.C) {
+	type testCase struct {
+		code     string
+		expected interface{}
+	}
+	testCases := []testCase{
+		// results are in the order of the actions, not in the order of completion
+		{code: `Parallel(func(){ Command("sleep 0.2"); 1 }(), func(){ 2 }())`, expected: []int{1, 2}},
+		{code: `ParallelWith(ParallelConfig{MaxConcurrent: 2}, func(){ Command("sleep 0.2"); 1 }(), func(){ 2 }(), func(){ 3 }())`, expected: []int{1, 2, 3}},
+		{code: `ParallelWith(ParallelConfig{FailFast: true}, func(){ "a" }(), func(){ "b" }())`, expected: []string{"a", "b"}},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.code)
+		out, err := evalExpr(newTestParser(c), tc.code)
+		c.Assert(err, check.IsNil, comment)
+		c.Assert(out, check.DeepEquals, tc.expected, comment)
+	}
+
+	errCases := []string{
+		`ParallelWith(ParallelConfig{}, func(){ x := 0; 1 / x }(), func(){ Command("sleep 0.1"); 1 }())`,
+		`ParallelWith(ParallelConfig{MaxConcurrent: -1}, func(){ 1 }())`,
+	}
+	for i, code := range errCases {
+		comment := check.Commentf("test case %v %v", i, code)
+		_, err := evalExpr(newTestParser(c), code)
+		c.Assert(err, check.NotNil, comment)
+	}
+
+	// actions wait for their turn
+	start := time.Now()
+	_, err := evalExpr(newTestParser(c), `ParallelWith(ParallelConfig{MaxConcurrent: 1}, Command("sleep 0.2"), Command("sleep 0.2"))`)
+	c.Assert(err, check.IsNil)
+	c.Assert(time.Since(start) >= 400*time.Millisecond, check.Equals, true)
+
+	// fail fast cancels other actions
+	start = time.Now()
+	_, err = evalExpr(newTestParser(c), `ParallelWith(ParallelConfig{FailFast: true}, func(){ Command("sleep 10"); 1 }(), func(){ x := 0; 1 / x }())`)
+	c.Assert(err, check.NotNil)
+	c.Assert(strings.Contains(err.Error(), "division"), check.Equals, true, check.Commentf("%v", err))
+	c.Assert(time.Since(start) < 5*time.Second, check.Equals, true)
+
+	// code is marshaled back into ParallelWith call
+	code := `func(){ ParallelWith(ParallelConfig{MaxConcurrent: 1}, func(){ 1 }(), func(){ 2 }()) }`
+	g := newTestParser(c)
+	expr, err := parseExpr(g, code)
+	c.Assert(err, check.IsNil)
+	data, err := force.MarshalCode(g.scope, expr)
+	c.Assert(err, check.IsNil)
+	out, err := evalExpr(newTestParser(c), string(data))
+	c.Assert(err, check.IsNil, check.Commentf("%v", string(data)))
+	c.Assert(out, check.DeepEquals, []int{1, 2})
+}
+
+// parseChannel parses the channel expression
+func parseChannel(c *check.C, code string) (force.Channel, error) {
+	f := token.NewFileSet()
+	expr, err := parser.ParseExprFrom(f, "", []byte(code), 0)
+	c.Assert(err, check.IsNil)
+	g := newTestParser(c)
+	out, err := g.parseExpr(f, g.runner, expr)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return out.(force.Channel), nil
+}
+
+// TestClosePlugins checks that plugins holding resources
+// are closed on the runner shutdown
+func (s *ParserSuite) TestClosePlugins(c *check.C) {
+	g := newTestParser(c)
+	closed := false
+	g.runner.SetPlugin("closer", force.CloserFunc(func() error {
+		closed = true
+		return nil
+	}))
+	c.Assert(g.runner.Close(), check.IsNil)
+	c.Assert(closed, check.Equals, true)
+}
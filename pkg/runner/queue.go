@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"context"
+
+	"github.com/gravitational/force"
+)
+
+// newExecutionQueue returns a new queue of executions waiting to be run
+func newExecutionQueue(size int, overflow string) *executionQueue {
+	return &executionQueue{
+		overflow: overflow,
+		queueC:   make(chan *execution, size),
+	}
+}
+
+// executionQueue is a bounded queue of executions,
+// overflow sets how new executions are handled when the queue is full
+type executionQueue struct {
+	overflow string
+	queueC   chan *execution
+}
+
+// push adds execution to the queue, in block mode waits until there
+// is space in the queue, returns the execution dropped from the queue
+// because of the overflow, returns false if the context is done
+func (q *executionQueue) push(ctx context.Context, e *execution) (*execution, bool) {
+	switch q.overflow {
+	case force.OverflowBlock:
+		select {
+		case q.queueC <- e:
+			return nil, true
+		case <-ctx.Done():
+			return nil, false
+		}
+	case force.OverflowReplaceOldest:
+		var dropped *execution
+		for {
+			select {
+			case q.queueC <- e:
+				return dropped, true
+			default:
+			}
+			// queue is full, workers could have picked up
+			// the oldest execution in the meantime
+			select {
+			case dropped = <-q.queueC:
+			default:
+			}
+		}
+	default:
+		select {
+		case q.queueC <- e:
+			return nil, true
+		default:
+			return e, true
+		}
+	}
+}
+
+// pop returns the next execution, returns false if the context is done
+func (q *executionQueue) pop(ctx context.Context) (*execution, bool) {
+	select {
+	case e := <-q.queueC:
+		return e, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// len returns the number of executions waiting in the queue
+func (q *executionQueue) len() int {
+	return len(q.queueC)
+}
@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestEventQueue(c *check.C) {
+	executions := make([]*execution, 3)
+	for i := range executions {
+		executions[i] = &execution{event: &force.OneshotEvent{Time: time.Unix(int64(i), 0)}}
+	}
+	type testCase struct {
+		overflow string
+		dropped  []*execution
+		expected []*execution
+	}
+	testCases := []testCase{
+		{overflow: force.OverflowDrop, dropped: []*execution{nil, nil, executions[2]}, expected: executions[:2]},
+		{overflow: force.OverflowReplaceOldest, dropped: []*execution{nil, nil, executions[0]}, expected: executions[1:]},
+	}
+	for i, tc := range testCases {
+		comment := check.Commentf("test case %v %v", i, tc.overflow)
+		q := newExecutionQueue(2, tc.overflow)
+		for j, e := range executions {
+			dropped, ok := q.push(context.TODO(), e)
+			c.Assert(ok, check.Equals, true, comment)
+			c.Assert(dropped, check.Equals, tc.dropped[j], comment)
+		}
+		for _, expected := range tc.expected {
+			e, ok := q.pop(context.TODO())
+			c.Assert(ok, check.Equals, true, comment)
+			c.Assert(e, check.Equals, expected, comment)
+		}
+	}
+
+	// block mode waits for space in the queue
+	q := newExecutionQueue(1, force.OverflowBlock)
+	_, ok := q.push(context.TODO(), executions[0])
+	c.Assert(ok, check.Equals, true)
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	_, ok = q.push(ctx, executions[1])
+	c.Assert(ok, check.Equals, false)
+	go func() {
+		q.pop(context.TODO())
+	}()
+	_, ok = q.push(context.TODO(), executions[2])
+	c.Assert(ok, check.Equals, true)
+	e, ok := q.pop(context.TODO())
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e, check.Equals, executions[2])
+
+	// spec checks the concurrency policy
+	errCases := []force.Spec{
+		{Run: force.Exit(), Overflow: "DropAll"},
+		{Run: force.Exit(), MaxConcurrent: -1},
+		{Run: force.Exit(), QueueSize: -1},
+		{Run: force.Exit(), CancelInProgress: true},
+		{Run: force.Exit(), ConcurrencyKey: force.Int(1)},
+	}
+	for i, spec := range errCases {
+		c.Assert(spec.CheckAndSetDefaults(), check.NotNil, check.Commentf("test case %v", i))
+	}
+	spec := force.Spec{Run: force.Exit()}
+	c.Assert(spec.CheckAndSetDefaults(), check.IsNil)
+	c.Assert(spec.QueueSize, check.Equals, force.Int(force.DefaultQueueSize))
+	c.Assert(spec.Overflow, check.Equals, force.String(force.OverflowDrop))
+}
@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"sync"
 	"sync/atomic"
@@ -30,6 +31,12 @@ type Runner struct {
 	logger        force.Logger
 	parser        *gParser
 	runners       map[string]*Runner
+	// forwarders forward broadcast events to the processes
+	forwarders map[force.Process]*forwarder
+	journal    *journal
+	history    *History
+	runs       *runTracker
+	traces     string
 }
 
 // RemoveRunner removes the runner if it matches
@@ -140,6 +147,7 @@ func (r *Runner) remove(p force.Process) bool {
 	for i := range r.processes {
 		if r.processes[i] == p {
 			r.processes = append(r.processes[:i], r.processes[i+1:]...)
+			delete(r.forwarders, p)
 			return true
 		}
 	}
@@ -197,18 +205,67 @@ func (r *Runner) fanInEvents(channel force.Channel) {
 		case <-channel.Done():
 			return
 		case event := <-channel.Events():
+			event = r.receive(event)
+			// events are not dropped, fan out never waits for the processes
 			select {
 			case r.eventsC <- event:
 				log.Debugf("Fan in received event %v.", event)
 			case <-r.Done():
 				return
-			default:
-				log.Warningf("Overflow, dropping event %v", event)
 			}
 		}
 	}
 }
 
+// receive records the event in the journal for every process,
+// so the event waiting in the runner and forwarder queues
+// is delivered again after restart
+func (r *Runner) receive(event force.Event) force.Event {
+	if r.journal == nil {
+		return event
+	}
+	r.RLock()
+	defer r.RUnlock()
+	var received map[force.Process]*journaledEvent
+	for _, proc := range r.processes {
+		recorder, ok := proc.(eventRecorder)
+		if !ok {
+			continue
+		}
+		if journaled := recorder.receive(event); journaled != nil {
+			if received == nil {
+				received = make(map[force.Process]*journaledEvent)
+			}
+			received[proc] = journaled
+		}
+	}
+	if received == nil {
+		return event
+	}
+	return &broadcastEvent{Event: event, received: received}
+}
+
+// broadcastEvent is an event recorded in the journal
+// for the processes it is sent to
+type broadcastEvent struct {
+	force.Event
+	// received are the journaled events of the processes
+	received map[force.Process]*journaledEvent
+}
+
+// String returns user friendly description of the event
+func (b *broadcastEvent) String() string {
+	return fmt.Sprintf("%v", b.Event)
+}
+
+// eventFor returns the event sent to the process
+func (b *broadcastEvent) eventFor(proc force.Process) force.Event {
+	if journaled, ok := b.received[proc]; ok {
+		return journaled
+	}
+	return b.Event
+}
+
 func (r *Runner) setExitEvent(event force.ExitEvent) {
 	r.Lock()
 	defer r.Unlock()
@@ -262,23 +319,60 @@ func (r *Runner) fanOutEvents() {
 	}
 }
 
+// sendEvent forwards the event to every process, the event waits
+// in the forwarder of the process while the process is busy,
+// forwarders are bounded by the process queue size, so the event
+// is dropped by the full forwarder, unless the process is in the Block
+// overflow mode, then sendEvent waits until the process catches up
 func (r *Runner) sendEvent(event force.Event) bool {
+	select {
+	case <-r.Done():
+		return false
+	default:
+	}
+	runnerEvents.Inc()
 	log := r.Logger()
-	r.RLock()
-	defer r.RUnlock()
-	for _, proc := range r.processes {
-		select {
-		case proc.Events() <- event:
-			log.Debugf("%v triggered by %v", proc, event)
-		case <-r.Done():
+	for _, f := range r.getForwarders(log) {
+		procEvent := event
+		if b, ok := event.(*broadcastEvent); ok {
+			procEvent = b.eventFor(f.proc)
+		}
+		dropped, ok := f.push(r.ctx, procEvent)
+		if !ok {
 			return false
-		default:
-			log.Warningf("Overflow, dropping event %v for proc %v", event, proc)
+		}
+		if dropped != nil {
+			log.Warningf("Overflow, %v is dropping %v.", f.proc, dropped)
+			processEventsDropped.Inc(f.proc.Name())
+			if recorder, ok := f.proc.(eventRecorder); ok {
+				recorder.discard(dropped)
+			}
 		}
 	}
 	return true
 }
 
+// getForwarders returns forwarders of the running processes,
+// forwarders are started on the first event sent to the process
+func (r *Runner) getForwarders(log force.Logger) []*forwarder {
+	r.Lock()
+	defer r.Unlock()
+	if r.forwarders == nil {
+		r.forwarders = make(map[force.Process]*forwarder)
+	}
+	out := make([]*forwarder, 0, len(r.processes))
+	for _, proc := range r.processes {
+		f, ok := r.forwarders[proc]
+		if !ok {
+			f = newForwarder(proc)
+			r.forwarders[proc] = f
+			go f.run(r.ctx, log)
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
 // Done returns channel
 func (r *Runner) Done() <-chan struct{} {
 	return r.ctx.Done()
@@ -304,12 +398,99 @@ func (r *Runner) Start() {
 		log.Debugf("Started process %v.", p)
 		go r.wait(p)
 	}
+	if r.journal != nil {
+		go r.replay()
+	}
+}
+
+// replay delivers events that have not been processed before
+// the restart to the processes that have received them
+func (r *Runner) replay() {
+	log := r.Logger()
+	entries, err := r.journal.unfinished()
+	if err != nil {
+		log.WithError(err).Errorf("Failed to read event journal.")
+		return
+	}
+	for _, entry := range entries {
+		if !r.replayEntry(entry) {
+			return
+		}
+	}
+}
+
+// replayEntry delivers the journal entry to the process, the entry
+// is completed when the process completes the execution of the event,
+// returns false if the runner is closed
+func (r *Runner) replayEntry(entry journalEntry) bool {
+	log := r.Logger()
+	proc := r.findProcess(entry.Process)
+	if proc == nil {
+		log.Warningf("Process %v is not found, skipping unfinished event.", entry.Process)
+		r.skipEntry(entry)
+		return true
+	}
+	unmarshaler, ok := proc.Channel().(force.EventUnmarshaler)
+	if !ok {
+		log.Warningf("%v can not restore events, skipping unfinished event.", proc)
+		r.skipEntry(entry)
+		return true
+	}
+	event, err := unmarshaler.UnmarshalEvent(r.ctx, entry.Event)
+	if err != nil {
+		log.WithError(err).Warningf("Failed to restore unfinished event of %v.", proc)
+		r.skipEntry(entry)
+		return true
+	}
+	log.Infof("Replaying unfinished %v received at %v to %v.", event, entry.Received.Format(force.HumanDateFormat), proc)
+	select {
+	case proc.Events() <- &journaledEvent{Event: event, journalID: entry.ID}:
+	case <-proc.Done():
+		log.Debugf("%v has exited, skipping %v", proc, event)
+	case <-r.Done():
+		return false
+	}
+	return true
+}
+
+// skipEntry completes the journal entry that can not be replayed
+func (r *Runner) skipEntry(entry journalEntry) {
+	if err := r.journal.complete(entry.ID); err != nil {
+		r.Logger().WithError(err).Warningf("Failed to complete journal entry %v.", entry.ID)
+	}
+}
+
+// findProcess returns the process by name
+func (r *Runner) findProcess(name string) force.Process {
+	r.RLock()
+	defer r.RUnlock()
+	for _, p := range r.processes {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
 }
 
 func (r *Runner) Close() error {
 	r.cancel()
 	r.stop()
-	return nil
+	var errors []error
+	// plugins holding resources, like databases, are closed
+	r.RLock()
+	for _, plugin := range r.plugins {
+		if closer, ok := plugin.(io.Closer); ok {
+			errors = append(errors, closer.Close())
+		}
+	}
+	r.RUnlock()
+	if r.journal != nil {
+		errors = append(errors, r.journal.Close())
+	}
+	if r.history != nil {
+		errors = append(errors, r.history.Close())
+	}
+	return trace.NewAggregate(errors...)
 }
 
 // OneshotWithExit creates a oneshot process that wraps actions and exits
@@ -355,6 +536,9 @@ func (r *Runner) Oneshot(name string, actions ...force.Action) (force.Process, e
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	l.history = r.history
+	l.runs = r.runs
+	l.traces = r.traces
 	return l, nil
 }
 
@@ -379,6 +563,10 @@ func (r *Runner) Process(spec force.Spec) (force.Process, error) {
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	l.journal = r.journal
+	l.history = r.history
+	l.runs = r.runs
+	l.traces = r.traces
 	return l, nil
 }
 
@@ -0,0 +1,377 @@
+package runner
+
+import (
+	"context"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitational/force"
+	"github.com/gravitational/force/pkg/metrics"
+
+	"github.com/gravitational/trace"
+)
+
+const (
+	// MaxTrackedRuns is a maximum number of recent runs
+	// kept in memory by the server
+	MaxTrackedRuns = 100
+	// logPollPeriod is a period of polling the log of the running execution
+	logPollPeriod = 500 * time.Millisecond
+	// readHeaderTimeout is a timeout of reading the request headers
+	readHeaderTimeout = 10 * time.Second
+	// readTimeout is a timeout of reading the whole request,
+	// there is no write timeout, as live logs are streamed for a long time
+	readTimeout = 30 * time.Second
+)
+
+// newRunTracker returns a new tracker of the recent runs
+func newRunTracker() *runTracker {
+	return &runTracker{runs: make(map[string]*trackedRun)}
+}
+
+// runTracker keeps the recent runs of the processes
+// in memory, so the server could show the runs in progress
+// and stream their logs
+type runTracker struct {
+	mutex sync.RWMutex
+	// baseURL is set when the server is set up
+	baseURL string
+	runs    map[string]*trackedRun
+	// order is a list of run ids, the oldest runs go first
+	order []string
+}
+
+// trackedRun is a run tracked by the server
+type trackedRun struct {
+	run     Run
+	capture *logCapture
+	// done is closed when the run has completed
+	done chan struct{}
+}
+
+// setURL sets the base URL of the server,
+// returns false if the URL has been set already
+func (t *runTracker) setURL(url string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.baseURL != "" {
+		return false
+	}
+	t.baseURL = url
+	return true
+}
+
+// runURL returns a URL of the run page, or empty string
+// if the server is not set up
+func (t *runTracker) runURL(id string) string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if t.baseURL == "" {
+		return ""
+	}
+	return t.baseURL + "/runs/" + id
+}
+
+// enabled returns true if the server is set up
+func (t *runTracker) enabled() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.baseURL != ""
+}
+
+// start adds the started run, the oldest runs
+// are removed once there are more than MaxTrackedRuns runs
+func (t *runTracker) start(run Run, capture *logCapture) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.runs[run.ID] = &trackedRun{run: run, capture: capture, done: make(chan struct{})}
+	t.order = append(t.order, run.ID)
+	for len(t.order) > MaxTrackedRuns {
+		delete(t.runs, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// finish updates the completed run
+func (t *runTracker) finish(run Run) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tracked, ok := t.runs[run.ID]
+	if !ok {
+		return
+	}
+	run.Log = ""
+	tracked.run = run
+	close(tracked.done)
+}
+
+// get returns the run by id
+func (t *runTracker) get(id string) (*trackedRun, Run, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	tracked, ok := t.runs[id]
+	if !ok {
+		return nil, Run{}, false
+	}
+	return tracked, tracked.run, true
+}
+
+// list returns the recent runs, the latest runs go first
+func (t *runTracker) list() []Run {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	out := make([]Run, 0, len(t.order))
+	for i := len(t.order) - 1; i >= 0; i-- {
+		out = append(out, t.runs[t.order[i]].run)
+	}
+	return out
+}
+
+// NewServer creates the server action
+type NewServer struct {
+	runner *Runner
+}
+
+// NewInstance returns a function creating the server action
+func (n *NewServer) NewInstance(group force.Group) (force.Group, interface{}) {
+	return group, func(cfg interface{}) (force.Action, error) {
+		return &ServerAction{runner: n.runner, cfg: cfg}, nil
+	}
+}
+
+// ServerAction starts the HTTP server listing the processes
+// and their recent runs, GitHub statuses and Slack messages
+// posted by the runs link to the run pages:
+//
+// Setup(Server(ServerConfig{Listen: "127.0.0.1:8080", URL: "https://force.example.com"}))
+//
+type ServerAction struct {
+	runner *Runner
+	cfg    interface{}
+}
+
+// Type returns bool type
+func (s *ServerAction) Type() interface{} {
+	return true
+}
+
+// Eval starts the server, the server is stopped
+// when the process group exits
+func (s *ServerAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	var cfg force.ServerConfig
+	if err := force.EvalInto(ctx, s.cfg, &cfg); err != nil {
+		return false, trace.Wrap(err)
+	}
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return false, trace.Wrap(err)
+	}
+	if !s.runner.runs.setURL(cfg.URL) {
+		return false, trace.AlreadyExists("server is already running")
+	}
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return false, trace.ConvertSystemError(err)
+	}
+	server := &http.Server{
+		Handler:           s.runner.handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+	}
+	log := s.runner.Logger()
+	go func() {
+		<-s.runner.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Errorf("Server has failed.")
+		}
+	}()
+	log.Infof("Serving runs at %v on %v.", cfg.URL, listener.Addr())
+	return true, nil
+}
+
+// MarshalCode marshals action into code representation
+func (s *ServerAction) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	call := &force.FnCall{
+		FnName: "Server",
+		Args:   []interface{}{s.cfg},
+	}
+	return call.MarshalCode(ctx)
+}
+
+// handler returns the HTTP handler of the server
+func (r *Runner) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", r.serveIndex)
+	mux.HandleFunc("/runs/", r.serveRun)
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+// processStatus is a process with its latest run
+type processStatus struct {
+	Name string
+	Last *Run
+}
+
+// serveIndex lists processes and the recent runs
+func (r *Runner) serveIndex(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	runs := r.runs.list()
+	r.RLock()
+	processes := make([]processStatus, 0, len(r.processes))
+	for _, p := range r.processes {
+		processes = append(processes, processStatus{Name: p.Name()})
+	}
+	r.RUnlock()
+	sort.Slice(processes, func(i, j int) bool {
+		return processes[i].Name < processes[j].Name
+	})
+	for i := range processes {
+		for j := range runs {
+			if runs[j].Process == processes[i].Name {
+				processes[i].Last = &runs[j]
+				break
+			}
+		}
+	}
+	render(w, indexTemplate, map[string]interface{}{
+		"Processes": processes,
+		"Runs":      runs,
+	})
+}
+
+// serveRun serves the run page and the log of the run,
+// runs that are no longer kept in memory are looked up in the history
+func (r *Runner) serveRun(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/runs/")
+	id := strings.TrimSuffix(path, "/log")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, req)
+		return
+	}
+	tracked, run, ok := r.runs.get(id)
+	if !ok {
+		if r.history == nil {
+			http.NotFound(w, req)
+			return
+		}
+		stored, err := r.history.Get(id)
+		if err != nil {
+			if trace.IsNotFound(err) {
+				http.NotFound(w, req)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		run = *stored
+	}
+	if strings.HasSuffix(path, "/log") {
+		streamLog(w, req, tracked, run)
+		return
+	}
+	if tracked != nil && tracked.capture != nil {
+		run.Log, _ = tracked.capture.since(0)
+	}
+	render(w, runTemplate, run)
+}
+
+// streamLog writes the log of the run, the log of the running
+// execution is streamed until the execution completes
+func streamLog(w http.ResponseWriter, req *http.Request, tracked *trackedRun, run Run) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if tracked == nil || tracked.capture == nil {
+		io.WriteString(w, run.Log)
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	ticker := time.NewTicker(logPollPeriod)
+	defer ticker.Stop()
+	offset := 0
+	for {
+		var out string
+		out, offset = tracked.capture.since(offset)
+		if _, err := io.WriteString(w, out); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-tracked.done:
+			out, _ = tracked.capture.since(offset)
+			io.WriteString(w, out)
+			return
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// render renders the page template
+func render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Format(force.HumanDateFormat)
+	},
+	"running": func(r Run) bool {
+		return r.Status == RunRunning
+	},
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><title>Force</title></head>
+<body>
+<h2>Processes</h2>
+<table>
+<tr><th>Process</th><th>Last run</th><th>Status</th></tr>
+{{range .Processes}}<tr><td>{{.Name}}</td>{{with .Last}}<td><a href="/runs/{{.ID}}">{{.ID}}</a></td><td>{{.Status}}</td>{{else}}<td></td><td></td>{{end}}</tr>
+{{end}}</table>
+<h2>Recent runs</h2>
+<table>
+<tr><th>Run</th><th>Process</th><th>Event</th><th>Status</th><th>Started</th><th>Duration</th></tr>
+{{range .Runs}}<tr><td><a href="/runs/{{.ID}}">{{.ID}}</a></td><td>{{.Process}}</td><td>{{.Event}}</td><td>{{.Status}}</td><td>{{date .Started}}</td><td>{{if not (running .)}}{{.Duration}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var runTemplate = template.Must(template.New("run").Funcs(templateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head><title>Force run {{.ID}}</title></head>
+<body>
+<p><a href="/">All runs</a></p>
+<h2>{{.Process}} run {{.ID}}</h2>
+<table>
+<tr><td>Event</td><td>{{.Event}}</td></tr>
+<tr><td>Status</td><td>{{.Status}}</td></tr>
+<tr><td>Started</td><td>{{date .Started}}</td></tr>
+{{if not (running .)}}<tr><td>Duration</td><td>{{.Duration}}</td></tr>{{end}}
+{{with .Error}}<tr><td>Error</td><td>{{.}}</td></tr>{{end}}
+</table>
+{{if running .}}<p>The run is in progress, <a href="/runs/{{.ID}}/log">follow the live log</a>.</p>{{end}}
+<pre>{{.Log}}</pre>
+</body>
+</html>
+`))
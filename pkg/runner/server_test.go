@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestServer(c *check.C) {
+	g := newTestParser(c)
+	c.Assert(g.runner.runs.setURL("http://force.example.com"), check.Equals, true)
+	c.Assert(g.runner.runs.setURL("http://force.example.com"), check.Equals, false)
+	proc, err := g.runner.Process(force.Spec{Name: force.String("build"), Run: force.Exit()})
+	c.Assert(err, check.IsNil)
+	g.runner.AddProcess(proc)
+	local := proc.(*LocalProcess)
+
+	// executions link to the run pages
+	e, err := local.newExecution(force.WithRuntimeScope(g.scope), &force.OneshotEvent{Time: time.Unix(1, 0)})
+	c.Assert(err, check.IsNil)
+	id := e.ctx.ID()
+	c.Assert(force.RunURL(e.ctx), check.Equals, "http://force.example.com/runs/"+id)
+	c.Assert(e.capture, check.NotNil)
+
+	run := Run{ID: id, Process: "build", Status: RunRunning, Started: time.Now().UTC()}
+	g.runner.runs.start(run, e.capture)
+	force.Log(e.ctx).Infof("Building.")
+
+	server := httptest.NewServer(g.runner.handler())
+	defer server.Close()
+	get := func(path string) (int, string) {
+		re, err := http.Get(server.URL + path)
+		c.Assert(err, check.IsNil)
+		defer re.Body.Close()
+		data, err := ioutil.ReadAll(re.Body)
+		c.Assert(err, check.IsNil)
+		return re.StatusCode, string(data)
+	}
+
+	code, out := get("/")
+	c.Assert(code, check.Equals, http.StatusOK)
+	c.Assert(strings.Contains(out, "/runs/"+id), check.Equals, true)
+
+	code, out = get("/runs/" + id)
+	c.Assert(code, check.Equals, http.StatusOK)
+	c.Assert(strings.Contains(out, "follow the live log"), check.Equals, true)
+	c.Assert(strings.Contains(out, "Building."), check.Equals, true)
+
+	// the live log is streamed until the run completes
+	go func() {
+		time.Sleep(2 * logPollPeriod)
+		force.Log(e.ctx).Infof("Done.")
+		run.Status = RunSucceeded
+		g.runner.runs.finish(run)
+	}()
+	code, out = get("/runs/" + id + "/log")
+	c.Assert(code, check.Equals, http.StatusOK)
+	c.Assert(strings.Contains(out, "Building.\n"), check.Equals, true)
+	c.Assert(strings.HasSuffix(out, "Done.\n"), check.Equals, true)
+
+	code, _ = get("/runs/missing")
+	c.Assert(code, check.Equals, http.StatusNotFound)
+
+	// by default the server is reachable only from the local host
+	cfg := force.ServerConfig{}
+	c.Assert(cfg.CheckAndSetDefaults(), check.IsNil)
+	c.Assert(cfg.Listen, check.Equals, force.DefaultServerListen)
+	c.Assert(cfg.URL, check.Equals, "http://127.0.0.1:8080")
+}
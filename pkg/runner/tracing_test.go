@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/gravitational/force"
+	"github.com/gravitational/force/pkg/tracing"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestTracing(c *check.C) {
+	g := newTestParser(c)
+	recorder := tracing.NewRecorder()
+	c.Assert(g.scope.SetValue(force.KeyTracer, recorder), check.IsNil)
+	code := `func(){
+		build := func(){ Command("sleep 0.1") }
+		Parallel(Command("sleep 0.1"), Command("sleep 0.1; exit 1"))
+	}`
+	_, err := evalExpr(g, code)
+	c.Assert(err, check.NotNil)
+	_, err = evalExpr(g, `func(){ build := func(){ Command("true") }; build() }`)
+	c.Assert(err, check.IsNil)
+
+	spans := recorder.Spans()
+	names := make(map[uint64]string)
+	var tree []string
+	for _, span := range spans {
+		names[span.ID] = span.Operation
+		tree = append(tree, names[span.ParentID]+"/"+span.Operation)
+	}
+	c.Assert(tree, check.DeepEquals, []string{
+		"/Parallel",
+		"Parallel/Shell",
+		"Parallel/Shell",
+		"/build()",
+		"build()/Shell",
+	})
+	// failed actions and their parents are tagged with errors
+	failed := 0
+	for _, span := range spans {
+		if span.Tags["error"] == true {
+			failed++
+		}
+	}
+	c.Assert(failed, check.Equals, 2)
+
+	// actions running in parallel are placed on separate tracks
+	buf := &bytes.Buffer{}
+	c.Assert(recorder.WriteChromeTrace(buf), check.IsNil)
+	var out struct {
+		TraceEvents []struct {
+			Name string `json:"name"`
+			TID  int    `json:"tid"`
+		} `json:"traceEvents"`
+	}
+	c.Assert(json.Unmarshal(buf.Bytes(), &out), check.IsNil)
+	c.Assert(out.TraceEvents, check.HasLen, len(spans))
+	c.Assert(out.TraceEvents[1].TID, check.Not(check.Equals), out.TraceEvents[2].TID)
+
+	// control flow actions and their clauses are traced
+	recorder = tracing.NewRecorder()
+	c.Assert(g.scope.SetValue(force.KeyTracer, recorder), check.IsNil)
+	code = `func(){
+		if 1 > 0 {
+			Try(Retry(RetryPolicy{Attempts: 2, Initial: "1ms"}, Timeout("1s", Command("exit 1"))),
+				Catch(func(err string){ Command("true") }),
+				Finally(Command("true")))
+		}
+	}`
+	_, err = evalExpr(g, code)
+	c.Assert(err, check.IsNil)
+	tree = nil
+	for _, span := range recorder.Spans() {
+		names[span.ID] = span.Operation
+		tree = append(tree, names[span.ParentID]+"/"+span.Operation)
+	}
+	c.Assert(tree, check.DeepEquals, []string{
+		"/If",
+		"If/Try",
+		"Try/Retry",
+		"Retry/Attempt",
+		"Attempt/Timeout",
+		"Timeout/Shell",
+		"Retry/Attempt",
+		"Attempt/Timeout",
+		"Timeout/Shell",
+		"Try/Catch",
+		"Catch/func()",
+		"func()/Shell",
+		"Try/Finally",
+		"Finally/Shell",
+	})
+}
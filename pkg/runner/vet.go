@@ -0,0 +1,137 @@
+package runner
+
+import (
+	"go/parser"
+	"go/token"
+
+	"github.com/gravitational/force"
+	"github.com/gravitational/force/pkg/github"
+	"github.com/gravitational/force/pkg/http"
+	"github.com/gravitational/force/pkg/log"
+	"github.com/gravitational/force/pkg/slack"
+
+	"github.com/gravitational/trace"
+)
+
+// Vet parses the setup and the script, including all included scripts,
+// and checks functions, struct fields and types without evaluating
+// the setup or starting any channels, all found errors are returned
+// as an aggregate, detected event loops are logged as warnings
+func Vet(i Input) error {
+	if err := i.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	runner := newRunner(i)
+	defer runner.Close()
+
+	g, err := newParser(i.ID, runner)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	g.vet = true
+
+	// some plugins are looked up when channels and actions are created,
+	// setup is not evaluated, so uninitialized plugins are used instead
+	vetPlugins := map[interface{}]interface{}{
+		log.Key:    &log.Plugin{},
+		github.Key: &github.Plugin{},
+		slack.Key:  &slack.Plugin{},
+		http.Key:   &http.Plugin{},
+	}
+	for key, plugin := range vetPlugins {
+		runner.SetPlugin(key, plugin)
+	}
+
+	var errors []error
+	for _, script := range []Script{i.Setup, i.Script} {
+		if script.Content == "" {
+			continue
+		}
+		out, err := g.vetScript(script)
+		if err != nil {
+			errors = append(errors, flattenErrors(err)...)
+			continue
+		}
+		for _, loop := range force.DetectLoops(out) {
+			runner.Logger().Warningf("Detected event loop: %v.", loop)
+		}
+		if err := checkCompletions(runner.Logger(), out); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return trace.NewAggregate(errors...)
+}
+
+// checkCompletions returns an error if Completed channels watch
+// processes that are not defined by the node, processes of the
+// loaded scripts are only known during execution, so if the node
+// loads scripts, the undefined processes are logged as warnings
+func checkCompletions(log force.Logger, node interface{}) error {
+	undefined := force.UndefinedCompletions(node)
+	if len(undefined) == 0 {
+		return nil
+	}
+	if loadsScripts(node) {
+		log.Warningf("Completed watches processes %v that are not defined by the script, make sure the loaded scripts define them.", undefined)
+		return nil
+	}
+	return trace.BadParameter("Completed watches processes %v that are not defined", undefined)
+}
+
+// loadsScripts returns true if the node or any of its processes
+// load or reload scripts
+func loadsScripts(node interface{}) bool {
+	loads := false
+	nodes := []interface{}{node}
+	for _, p := range force.Processes(node) {
+		nodes = append(nodes, p.Action())
+	}
+	for _, n := range nodes {
+		force.Walk(n, func(node interface{}) bool {
+			if _, ok := node.(*LoadAction); ok {
+				loads = true
+			}
+			return !loads
+		})
+	}
+	return loads
+}
+
+// vetScript parses the script, the script
+// should evaluate to a process or an action
+func (g *gParser) vetScript(script Script) (interface{}, error) {
+	f := token.NewFileSet()
+	expr, err := parser.ParseExprFrom(f, "", []byte(script.Content), 0)
+	if err != nil {
+		return nil, convertScanError(err, script)
+	}
+	out, err := g.parseExpr(f, g.runner, expr)
+	if err != nil {
+		return nil, convertScanError(err, script)
+	}
+	switch out.(type) {
+	case force.Process, force.Action:
+		return out, nil
+	default:
+		return nil, trace.BadParameter("%v: expected Process, Setup or an action, got %T", script.Filename, out)
+	}
+}
+
+// flattenErrors returns a flat list of errors, code errors
+// are unwrapped, so their snippets are printed
+func flattenErrors(err error) []error {
+	switch e := trace.Unwrap(err).(type) {
+	case *force.CodeError:
+		if _, ok := trace.Unwrap(e.Err).(trace.Aggregate); ok {
+			return flattenErrors(e.Err)
+		}
+		return []error{e}
+	case trace.Aggregate:
+		var out []error
+		for _, sub := range e.Errors() {
+			out = append(out, flattenErrors(sub)...)
+		}
+		return out
+	}
+	return []error{err}
+}
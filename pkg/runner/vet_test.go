@@ -0,0 +1,256 @@
+package runner
+
+import (
+	"context"
+	"go/scanner"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gravitational/force"
+	"github.com/gravitational/force/pkg/github"
+	"github.com/gravitational/force/pkg/slack"
+
+	"github.com/gravitational/trace"
+	"gopkg.in/check.v1"
+)
+
+func (s *ParserSuite) TestVet(c *check.C) {
+	// setup is not evaluated, otherwise missing token file would fail
+	setup := Script{
+		Filename: "setup.force",
+		Content:  `Setup(github.Setup(github.Config{TokenFile: "/does/not/exist"}))`,
+	}
+	err := Vet(Input{
+		Context: context.TODO(),
+		Setup:   setup,
+		Script: Script{
+			Filename: "g.force",
+			Content: `Process(Spec{
+	Name: "test",
+	Watch: github.PullRequests(github.Source{Repo: "gravitational/force"}),
+	Run: func(){ Infof("%v", 1 + 2) },
+})`,
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	err = Vet(Input{
+		Context: context.TODO(),
+		Setup:   setup,
+		Script: Script{
+			Filename: "g.force",
+			Content: `func(){
+	x := 1 + "a"
+	Unknown(1)
+	Infof("%v", true)
+}()`,
+		},
+	})
+	c.Assert(err, check.NotNil)
+	agg, ok := trace.Unwrap(err).(trace.Aggregate)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(agg.Errors(), check.HasLen, 2)
+	for i, line := range []int{2, 3} {
+		codeErr, ok := agg.Errors()[i].(*force.CodeError)
+		c.Assert(ok, check.Equals, true, check.Commentf("%T", agg.Errors()[i]))
+		c.Assert(codeErr.Snippet.Pos.Filename, check.Equals, "g.force")
+		c.Assert(codeErr.Snippet.Pos.Line, check.Equals, line)
+	}
+}
+
+// TestVetInclude checks that included scripts
+// are parsed, but not evaluated by vet
+func (s *ParserSuite) TestVetInclude(c *check.C) {
+	dir := c.MkDir()
+	marker := filepath.Join(dir, "marker")
+	included := filepath.Join(dir, "included.force")
+	c.Assert(ioutil.WriteFile(included, []byte(`func(){ Command("touch `+marker+`") }()`), 0600), check.IsNil)
+	script := Script{
+		Filename: "g.force",
+		Content: `func(){
+	Include("` + included + `")
+	Infof("included")
+}()`,
+	}
+	c.Assert(Vet(Input{Context: context.TODO(), Script: script}), check.IsNil)
+	_, err := os.Stat(marker)
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+
+	// the script is evaluated when included outside of vet
+	_, err = evalExpr(newTestParser(c), `func(){ Include("`+included+`") }`)
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(marker)
+	c.Assert(err, check.IsNil)
+}
+
+// TestConvertScanError checks that scan errors
+// nested in aggregates are converted to code errors
+func (s *ParserSuite) TestConvertScanError(c *check.C) {
+	script := Script{Filename: "g.force", Content: "func(){\n\tx :=\n}"}
+	scanErr := scanner.ErrorList{&scanner.Error{Pos: token.Position{Line: 2, Column: 2}, Msg: "expected operand"}}
+	for _, err := range []error{
+		trace.NewAggregate(scanErr),
+		&force.CodeError{Err: trace.NewAggregate(scanErr)},
+	} {
+		errors := flattenErrors(convertScanError(err, script))
+		c.Assert(errors, check.HasLen, 1)
+		codeErr, ok := errors[0].(*force.CodeError)
+		c.Assert(ok, check.Equals, true, check.Commentf("%T", errors[0]))
+		c.Assert(codeErr.Snippet.Pos.Filename, check.Equals, "g.force")
+		c.Assert(codeErr.Snippet.Pos.Line, check.Equals, 2)
+	}
+}
+
+func (s *ParserSuite) TestDetectLoops(c *check.C) {
+	dir := c.MkDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, name), nil, 0600), check.IsNil)
+	}
+	code := `func(){
+	Process(Spec{
+		Name: "self",
+		Watch: Files("DIR/a.txt"),
+		Run: func(){
+			If(true, aws.Copy(aws.S3{Bucket: "b", Key: "k"}, aws.Local{Path: "DIR/a.txt"}))
+		},
+	})
+	Process(Spec{
+		Name: "first",
+		Watch: Files("DIR/b.txt"),
+		Run: git.Clone(git.Repo{URL: "x", Into: "DIR/c.txt", Branch: "master"}),
+	})
+	Process(Spec{
+		Name: "second",
+		Watch: Files("DIR/c.txt"),
+		Run: ssh.Copy("host:22", ssh.Remote("/tmp/b.txt"), ssh.Local("DIR/b.txt")),
+	})
+	Process(Spec{
+		Name: "unknown",
+		Watch: Files("DIR/c.txt"),
+		Run: func(){
+			into := Sprintf("%v", ID())
+			git.Clone(git.Repo{URL: "x", Into: into, Branch: "master"})
+		},
+	})
+}`
+	expr, err := parseExpr(newTestParser(c), strings.Replace(code, "DIR", dir, -1))
+	c.Assert(err, check.IsNil)
+
+	loops := force.DetectLoops(expr)
+	c.Assert(loops, check.HasLen, 2)
+	var names [][]string
+	for _, loop := range loops {
+		var loopNames []string
+		for _, p := range loop.Processes {
+			loopNames = append(loopNames, p.Name())
+		}
+		names = append(names, loopNames)
+	}
+	c.Assert(names, check.DeepEquals, [][]string{{"self"}, {"first", "second"}})
+	c.Assert(loops[1].Resources, check.DeepEquals, []force.Resource{
+		{Kind: force.ResourceFile, Name: dir + "/c.txt"},
+		{Kind: force.ResourceFile, Name: dir + "/b.txt"},
+	})
+}
+
+func (s *ParserSuite) TestDetectPluginLoops(c *check.C) {
+	dir := c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "a.txt"), nil, 0600), check.IsNil)
+	code := `func(){
+	Process(Spec{
+		Name: "chat",
+		Watch: slack.Listen(slack.Command{Name: "deploy"}),
+		Run: aws.Copy(aws.S3{Bucket: "b", Key: "k"}, aws.Local{Path: "DIR/b.txt"}),
+	})
+	Process(Spec{
+		Name: "branches",
+		Watch: github.Branches(github.Source{Repo: "gravitational/force", BranchPattern: "^release-.*$"}),
+		Run: git.Clone(git.Repo{URL: "https://github.com/gravitational/force", Into: "DIR/force", Branch: "release-1.0"}),
+	})
+	Process(Spec{
+		Name: "fan-in",
+		Watch: FanIn(Files("DIR/a.txt"), Ticker("1h")),
+		Run: aws.Copy(aws.S3{Bucket: "b", Key: "k"}, aws.Local{Path: "DIR/a.txt"}),
+	})
+}`
+	g := newTestParser(c)
+	g.runner.SetPlugin(github.Key, &github.Plugin{})
+	g.runner.SetPlugin(slack.Key, &slack.Plugin{})
+	expr, err := parseExpr(g, strings.Replace(code, "DIR", dir, -1))
+	c.Assert(err, check.IsNil)
+
+	loops := force.DetectLoops(expr)
+	c.Assert(loops, check.HasLen, 1)
+	c.Assert(loops[0].Processes[0].Name(), check.Equals, "fan-in")
+	c.Assert(loops[0].Resources, check.DeepEquals, []force.Resource{
+		{Kind: force.ResourceFile, Name: dir + "/a.txt"},
+	})
+
+	// watched branches match the branches of the same repository
+	// matching the branch pattern
+	watched := force.GitHubBranch("gravitational/force", "^release-.*$")
+	c.Assert(watched.Matches(force.GitHubBranch("Gravitational/Force", "release-1.0")), check.Equals, true)
+	c.Assert(watched.Matches(force.GitHubBranch("gravitational/force", "master")), check.Equals, false)
+	c.Assert(watched.Matches(force.GitHubBranch("gravitational/teleport", "release-1.0")), check.Equals, false)
+}
+
+func (s *ParserSuite) TestCompletedChecks(c *check.C) {
+	code := `func(){
+	Process(Spec{
+		Name: "build",
+		Watch: Completed("build"),
+		Run: Command("make"),
+	})
+	Process(Spec{
+		Name: "unit",
+		Watch: Completed("integration"),
+		Run: Command("make test"),
+	})
+	Process(Spec{
+		Name: "integration",
+		Watch: Filter(Completed("unit"), func() bool { return event.Status == "succeeded" }),
+		Run: Command("make integration"),
+	})
+	Process(Spec{
+		Name: "deploy",
+		Watch: Join(Completed("unit"), Completed("missing")),
+		Run: Command("make deploy"),
+	})
+}`
+	expr, err := parseExpr(newTestParser(c), code)
+	c.Assert(err, check.IsNil)
+
+	// processes watching their own completions are loops
+	var names [][]string
+	for _, loop := range force.DetectLoops(expr) {
+		var loopNames []string
+		for _, p := range loop.Processes {
+			loopNames = append(loopNames, p.Name())
+		}
+		names = append(names, loopNames)
+	}
+	c.Assert(names, check.DeepEquals, [][]string{{"build"}, {"unit", "integration"}})
+
+	c.Assert(force.UndefinedCompletions(expr), check.DeepEquals, []string{"missing"})
+	c.Assert(checkCompletions(force.Log(force.EmptyContext()), expr), check.NotNil)
+
+	// processes of the loaded scripts are not known before they run
+	loads, err := parseExpr(newTestParser(c), `func(){
+	Load("other.force")
+	Process(Spec{Name: "deploy", Watch: Completed("missing"), Run: Command("make deploy")})
+}`)
+	c.Assert(err, check.IsNil)
+	c.Assert(checkCompletions(force.Log(force.EmptyContext()), loads), check.IsNil)
+
+	err = Vet(Input{
+		Context: context.TODO(),
+		Script: Script{
+			Filename: "g.force",
+			Content:  code,
+		},
+	})
+	c.Assert(err, check.NotNil)
+}
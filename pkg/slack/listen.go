@@ -2,8 +2,10 @@ package slack
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/gravitational/force"
@@ -100,6 +102,38 @@ func (r *Listener) Done() <-chan struct{} {
 	return nil
 }
 
+// UnmarshalEvent restores chat event, the restored event
+// replies in the thread of the original conversation
+func (r *Listener) UnmarshalEvent(ctx context.Context, data []byte) (force.Event, error) {
+	var e chatEventJSON
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	values := reflect.New(r.valuesType)
+	if err := json.Unmarshal(e.Values, values.Interface()); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	bot := newBot(botConfig{
+		client:     r.plugin.client,
+		log:        force.Log(ctx),
+		valuesType: r.valuesType,
+		dialog:     Dialog{Commands: []Command{r.command}},
+	})
+	return &ChatEvent{
+		created: e.Created,
+		Values:  values.Elem().Interface(),
+		convo: &conversation{
+			ctx:             ctx,
+			lock:            &sync.RWMutex{},
+			state:           convoState{phase: phaseInit},
+			threadTimestamp: e.ThreadTimestamp,
+			bot:             bot,
+			channel:         e.Channel,
+			userID:          e.UserID,
+		},
+	}, nil
+}
+
 // ChatEvent is event
 type ChatEvent struct {
 	created time.Time
@@ -203,6 +237,30 @@ func (r *ChatEvent) AddMetadata(ctx force.ExecutionContext) {
 	ctx.SetValue(force.ContextKey(force.KeyEvent), *r)
 }
 
+// MarshalEvent serializes chat event
+func (r *ChatEvent) MarshalEvent() ([]byte, error) {
+	values, err := json.Marshal(r.Values)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return json.Marshal(chatEventJSON{
+		Values:          values,
+		Channel:         r.convo.channel,
+		ThreadTimestamp: r.convo.threadTimestamp,
+		UserID:          r.convo.userID,
+		Created:         r.created,
+	})
+}
+
+// chatEventJSON is a serialized chat event
+type chatEventJSON struct {
+	Values          json.RawMessage `json:"values"`
+	Channel         string          `json:"channel"`
+	ThreadTimestamp string          `json:"thread_timestamp"`
+	UserID          string          `json:"user_id"`
+	Created         time.Time       `json:"created"`
+}
+
 func (r *ChatEvent) String() string {
 	return fmt.Sprintf("chat event")
 }
@@ -42,6 +42,15 @@ type PostStatusOfAction struct {
 	actions []force.Action
 }
 
+// Children returns inner actions
+func (p *PostStatusOfAction) Children() []interface{} {
+	out := make([]interface{}, len(p.actions))
+	for i := range p.actions {
+		out[i] = p.actions[i]
+	}
+	return out
+}
+
 func (p *PostStatusOfAction) Type() interface{} {
 	return p.seq.Type()
 }
@@ -53,9 +62,8 @@ func (p *PostStatusOfAction) Eval(ctx force.ExecutionContext) (interface{}, erro
 		// in the standalone mode given all the parameters
 		return nil, trace.BadParameter("slack.PostStatusOf can only be executed with Listen")
 	}
-	log := force.Log(ctx)
 	if err := event.convo.sendMessage(
-		fmt.Sprintf(":shipit: Started action, check logs at %v.", log.URL(ctx))); err != nil {
+		fmt.Sprintf(":shipit: Started action, check logs at %v.", force.RunURL(ctx))); err != nil {
 		return nil, trace.Wrap(err)
 	}
 
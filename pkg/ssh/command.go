@@ -55,10 +55,12 @@ func (s *CommandAction) Type() interface{} {
 
 // Eval evaluates variable and returns string
 func (s *CommandAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	ctx, span := force.StartSpan(ctx, "ssh.Command")
 	w := force.Writer(force.Log(ctx))
 	defer w.Close()
 	buf := force.NewSyncBuffer()
 	err := s.run(ctx, io.MultiWriter(w, buf))
+	force.FinishSpan(span, err)
 	return strings.TrimSpace(buf.String()), err
 }
 
@@ -79,6 +81,10 @@ func (s *CommandAction) run(ctx force.ExecutionContext, writer io.Writer) error
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	force.ActiveSpan(ctx).SetTag("command", command)
+	if host != "" {
+		force.ActiveSpan(ctx).SetTag("host", host)
+	}
 	if host != "" {
 		client, err = dial(ctx, host, plugin.cfg.ProxyJump, *plugin.clientConfig)
 		if err != nil {
@@ -110,7 +116,22 @@ func (s *CommandAction) run(ctx force.ExecutionContext, writer io.Writer) error
 	if err != nil {
 		return trace.ConnectionProblem(err, "could not execute command %v", command)
 	}
-	return session.Wait()
+	// close the session when the context is cancelled,
+	// for example when the command is stopped by Timeout
+	doneC := make(chan struct{})
+	defer close(doneC)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-doneC:
+		}
+	}()
+	err = session.Wait()
+	if err != nil && ctx.Err() != nil {
+		return trace.ConnectionProblem(ctx.Err(), "command %v has been cancelled", command)
+	}
+	return err
 }
 
 func parseHost(host string) (string, string) {
@@ -15,16 +15,30 @@ import (
 type Dialer struct {
 }
 
+// Dial connects to the SSH server, failed attempts are counted
 func (s *Dialer) Dial(network, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	client, err := s.dial(network, addr, config)
+	if err != nil {
+		dialFailures.Inc()
+		return nil, trace.Wrap(err)
+	}
+	return client, nil
+}
+
+func (s *Dialer) dial(network, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultDialTimeout
+	}
 	d := &net.Dialer{
-		Timeout:   defaultDialTimeout,
+		Timeout:   timeout,
 		KeepAlive: defaultKeepAlive,
 	}
 	conn, err := d.Dial(network, addr)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
-	if err := conn.SetReadDeadline(time.Now().Add(defaultDialTimeout)); err != nil {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
 		return nil, trace.Wrap(err)
 	}
 	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
@@ -0,0 +1,9 @@
+package ssh
+
+import (
+	"github.com/gravitational/force/pkg/metrics"
+)
+
+var dialFailures = metrics.NewCounter(
+	"force_ssh_dial_failures_total",
+	"Failed SSH connection attempts.")
@@ -74,6 +74,19 @@ type CopyAction struct {
 	client      *Client
 }
 
+// Produces returns the local destination of the copy,
+// if the destination is known before the execution
+func (s *CopyAction) Produces() []force.Resource {
+	if !s.destination.Local {
+		return nil
+	}
+	path, err := force.EvalString(force.EmptyContext(), s.destination.Path)
+	if err != nil || path == "" {
+		return nil
+	}
+	return []force.Resource{{Kind: force.ResourceFile, Name: path}}
+}
+
 func (s *CopyAction) BindClient(client *Client, _ []Env) (Action, error) {
 	if s.client != nil {
 		return nil, trace.AlreadyExists("client already set")
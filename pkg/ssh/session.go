@@ -201,11 +201,13 @@ func dial(ctx context.Context, host string, proxyJump string, config ssh.ClientC
 	proxyConn, err := proxyClient.Dial("tcp", host)
 	if err != nil {
 		defer proxyClient.Close()
+		dialFailures.Inc()
 		return nil, trace.ConnectionProblem(err, "failed connecting to node %v. %s", host, err)
 	}
 
 	conn, chans, _, err := newClientConn(ctx, proxyConn, host, &config)
 	if err != nil {
+		dialFailures.Inc()
 		if strings.Contains(trace.Unwrap(err).Error(), "ssh: handshake failed") {
 			proxyConn.Close()
 			return nil, trace.AccessDenied(`access denied to %v connecting to %v`, config.User, host)
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"net"
 	"reflect"
+	"time"
 
 	"github.com/gravitational/force"
 
@@ -98,6 +99,9 @@ type Config struct {
 	KeyPairs []KeyPair
 	// ProxyJump is a proxy jump address (similar to ssh -J)
 	ProxyJump string
+	// DialTimeout is a timeout of the connection and SSH handshake,
+	// for example DialTimeout: "10s", 30 seconds by default
+	DialTimeout time.Duration
 }
 
 // CheckAndSetDefaults checks and sets default values
@@ -114,9 +118,16 @@ func (cfg *Config) CheckAndSetDefaults() (*ssh.ClientConfig, error) {
 		return nil, trace.BadParameter("set ssh.Config{KnownHostsFile: ``} parameter")
 	}
 
+	if cfg.DialTimeout < 0 {
+		return nil, trace.BadParameter("ssh.Config{DialTimeout: } should not be negative")
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+
 	clientConfig := ssh.ClientConfig{
 		User:    cfg.User,
-		Timeout: defaultDialTimeout,
+		Timeout: cfg.DialTimeout,
 	}
 
 	var signers []ssh.Signer
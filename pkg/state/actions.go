@@ -0,0 +1,356 @@
+package state
+
+import (
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/gravitational/trace"
+)
+
+// Get returns the value of the key, or empty string
+// if the key does not exist or has expired:
+//
+// commit := state.Get("last-deployed-commit")
+//
+func Get(key force.Expression) (force.Action, error) {
+	if err := force.ExpectString(key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &GetAction{key: key}, nil
+}
+
+// GetAction returns the value of the key
+type GetAction struct {
+	key force.Expression
+}
+
+// Type returns string type
+func (g *GetAction) Type() interface{} {
+	return ""
+}
+
+// Eval returns the value of the key
+func (g *GetAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "state.Get", g.eval)
+}
+
+// eval returns the value of the key
+func (g *GetAction) eval(ctx force.ExecutionContext) (interface{}, error) {
+	plugin, key, err := pluginAndKey(ctx, g.key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	value, _, err := plugin.Get(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return value, nil
+}
+
+// MarshalCode marshals action into code representation
+func (g *GetAction) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	return marshalCall(ctx, Get, g.key)
+}
+
+// Set sets the value of the key, the optional ttl
+// sets when the key expires:
+//
+// state.Set("last-deployed-commit", event.Commit, "24h")
+//
+func Set(key, value force.Expression, ttl ...force.Expression) (force.Action, error) {
+	if err := expectStrings(key, value); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	t, err := newTTL(ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &SetAction{key: key, value: value, ttl: t}, nil
+}
+
+// SetAction sets the value of the key
+type SetAction struct {
+	key   force.Expression
+	value force.Expression
+	ttl   *force.DurationVar
+}
+
+// Type returns bool type
+func (s *SetAction) Type() interface{} {
+	return true
+}
+
+// Eval sets the value of the key
+func (s *SetAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "state.Set", s.eval)
+}
+
+// eval sets the value of the key
+func (s *SetAction) eval(ctx force.ExecutionContext) (interface{}, error) {
+	plugin, key, err := pluginAndKey(ctx, s.key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	value, err := force.EvalString(ctx, s.value)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ttl, err := evalTTL(ctx, s.ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := plugin.Set(key, value, ttl); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return true, nil
+}
+
+// MarshalCode marshals action into code representation
+func (s *SetAction) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	return marshalCall(ctx, Set, withTTL(s.ttl, s.key, s.value)...)
+}
+
+// Delete deletes the key, evaluates to false
+// if the key did not exist
+func Delete(key force.Expression) (force.Action, error) {
+	if err := force.ExpectString(key); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &DeleteAction{key: key}, nil
+}
+
+// DeleteAction deletes the key
+type DeleteAction struct {
+	key force.Expression
+}
+
+// Type returns bool type
+func (d *DeleteAction) Type() interface{} {
+	return true
+}
+
+// Eval deletes the key
+func (d *DeleteAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "state.Delete", d.eval)
+}
+
+// eval deletes the key
+func (d *DeleteAction) eval(ctx force.ExecutionContext) (interface{}, error) {
+	plugin, key, err := pluginAndKey(ctx, d.key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	deleted, err := plugin.Delete(key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return deleted, nil
+}
+
+// MarshalCode marshals action into code representation
+func (d *DeleteAction) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	return marshalCall(ctx, Delete, d.key)
+}
+
+// CompareAndSwap sets the value of the key if the current value
+// equals to the expected value, missing keys have empty values,
+// evaluates to true if the value has been swapped:
+//
+// If(state.CompareAndSwap("deployed", "", event.Commit), Command("make deploy"))
+//
+func CompareAndSwap(key, expected, value force.Expression, ttl ...force.Expression) (force.Action, error) {
+	if err := expectStrings(key, expected, value); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	t, err := newTTL(ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &CompareAndSwapAction{key: key, expected: expected, value: value, ttl: t}, nil
+}
+
+// CompareAndSwapAction swaps the value of the key
+type CompareAndSwapAction struct {
+	key      force.Expression
+	expected force.Expression
+	value    force.Expression
+	ttl      *force.DurationVar
+}
+
+// Type returns bool type
+func (c *CompareAndSwapAction) Type() interface{} {
+	return true
+}
+
+// Eval swaps the value of the key
+func (c *CompareAndSwapAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "state.CompareAndSwap", c.eval)
+}
+
+// eval swaps the value of the key
+func (c *CompareAndSwapAction) eval(ctx force.ExecutionContext) (interface{}, error) {
+	plugin, key, err := pluginAndKey(ctx, c.key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	expected, err := force.EvalString(ctx, c.expected)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	value, err := force.EvalString(ctx, c.value)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ttl, err := evalTTL(ctx, c.ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	swapped, err := plugin.CompareAndSwap(key, expected, value, ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return swapped, nil
+}
+
+// MarshalCode marshals action into code representation
+func (c *CompareAndSwapAction) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	return marshalCall(ctx, CompareAndSwap, withTTL(c.ttl, c.key, c.expected, c.value)...)
+}
+
+// CreateIfNotExists sets the value of the key if the key does not
+// exist or has expired, evaluates to true if the key has been created:
+//
+// If(state.CreateIfNotExists(Sprintf("channel-%v", email), "created"), slack.PostMessage(...))
+//
+func CreateIfNotExists(key, value force.Expression, ttl ...force.Expression) (force.Action, error) {
+	if err := expectStrings(key, value); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	t, err := newTTL(ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &CreateIfNotExistsAction{key: key, value: value, ttl: t}, nil
+}
+
+// CreateIfNotExistsAction creates the key
+type CreateIfNotExistsAction struct {
+	key   force.Expression
+	value force.Expression
+	ttl   *force.DurationVar
+}
+
+// Type returns bool type
+func (c *CreateIfNotExistsAction) Type() interface{} {
+	return true
+}
+
+// Eval creates the key if it does not exist
+func (c *CreateIfNotExistsAction) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	return force.EvalWithSpan(ctx, "state.CreateIfNotExists", c.eval)
+}
+
+// eval creates the key if it does not exist
+func (c *CreateIfNotExistsAction) eval(ctx force.ExecutionContext) (interface{}, error) {
+	plugin, key, err := pluginAndKey(ctx, c.key)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	value, err := force.EvalString(ctx, c.value)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ttl, err := evalTTL(ctx, c.ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	created, err := plugin.CreateIfNotExists(key, value, ttl)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return created, nil
+}
+
+// MarshalCode marshals action into code representation
+func (c *CreateIfNotExistsAction) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	return marshalCall(ctx, CreateIfNotExists, withTTL(c.ttl, c.key, c.value)...)
+}
+
+// pluginAndKey returns the plugin and the key prefixed
+// with the process name, so every process has its own keys
+func pluginAndKey(ctx force.ExecutionContext, keyExpr force.Expression) (*Plugin, string, error) {
+	pluginI, ok := ctx.Process().Group().GetPlugin(Key)
+	if !ok {
+		return nil, "", trace.NotFound("initialize state plugin in the setup section")
+	}
+	key, err := force.EvalString(ctx, keyExpr)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+	if key == "" {
+		return nil, "", trace.BadParameter("state key can not be empty")
+	}
+	return pluginI.(*Plugin), ctx.Process().Name() + "/" + key, nil
+}
+
+// expectStrings checks that all expressions are strings
+func expectStrings(exprs ...force.Expression) error {
+	for _, expr := range exprs {
+		if err := force.ExpectString(expr); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// newTTL converts the optional ttl argument to duration
+func newTTL(ttl []force.Expression) (*force.DurationVar, error) {
+	switch len(ttl) {
+	case 0:
+		return nil, nil
+	case 1:
+		converted, err := force.DurationVar{}.Convert(ttl[0])
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		d := converted.(force.DurationVar)
+		return &d, nil
+	default:
+		return nil, trace.BadParameter("expected at most one ttl argument, got %v", len(ttl))
+	}
+}
+
+// evalTTL evaluates the optional ttl, zero ttl means that the key never expires
+func evalTTL(ctx force.ExecutionContext, ttl *force.DurationVar) (time.Duration, error) {
+	if ttl == nil {
+		return 0, nil
+	}
+	out, err := ttl.Eval(ctx)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	d, ok := out.(time.Duration)
+	if !ok || d < 0 {
+		return 0, trace.BadParameter("ttl should be a non-negative duration, got %v", out)
+	}
+	return d, nil
+}
+
+// withTTL appends the optional ttl to the arguments
+func withTTL(ttl *force.DurationVar, args ...interface{}) []interface{} {
+	if ttl != nil {
+		args = append(args, ttl.Expression)
+	}
+	return args
+}
+
+// marshalCall marshals call of the plugin function
+func marshalCall(ctx force.ExecutionContext, fn interface{}, args ...interface{}) ([]byte, error) {
+	call := &force.FnCall{
+		Package: string(Key),
+		Fn:      fn,
+		Args:    args,
+	}
+	return call.MarshalCode(ctx)
+}
@@ -0,0 +1,318 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Scope returns a new scope with all the functions and structs
+// defined, this is the entrypoint into plugin as far as force is concerned
+func Scope() (force.Group, error) {
+	scope := force.WithLexicalScope(nil)
+	err := force.ImportStructsIntoAST(scope,
+		reflect.TypeOf(Config{}),
+	)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	scope.AddDefinition(force.FunctionName(Get), &force.NopScope{Func: Get})
+	scope.AddDefinition(force.FunctionName(Set), &force.NopScope{Func: Set})
+	scope.AddDefinition(force.FunctionName(Delete), &force.NopScope{Func: Delete})
+	scope.AddDefinition(force.FunctionName(CompareAndSwap), &force.NopScope{Func: CompareAndSwap})
+	scope.AddDefinition(force.FunctionName(CreateIfNotExists), &force.NopScope{Func: CreateIfNotExists})
+	scope.AddDefinition(KeySetup, &Setup{})
+	return scope, nil
+}
+
+// Namespace is a wrapper around string to namespace a variable in the context
+type Namespace string
+
+// Key is a name of the plugin variable
+const Key = Namespace("state")
+
+const (
+	KeySetup  = "Setup"
+	KeyConfig = "Config"
+)
+
+// PruneInterval is a period of deleting the expired keys
+const PruneInterval = time.Minute
+
+// bucket is a name of the bolt bucket with the keys
+var bucket = []byte("state")
+
+// Config is a state store configuration
+type Config struct {
+	// Path is a path to the database file,
+	// the file is created if it does not exist
+	Path string
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (cfg *Config) CheckAndSetDefaults() error {
+	if cfg.Path == "" {
+		return trace.BadParameter("set state.Config{Path: ``} parameter")
+	}
+	return nil
+}
+
+// Plugin is a state store plugin
+type Plugin struct {
+	cfg   Config
+	db    *bolt.DB
+	clock clockwork.Clock
+	// closeC is closed when the plugin is closed
+	closeC    chan struct{}
+	closeOnce sync.Once
+}
+
+// New opens the database and returns a new plugin
+func New(cfg Config, clock clockwork.Clock) (*Plugin, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0700); err != nil {
+		return nil, trace.ConvertSystemError(err)
+	}
+	db, err := bolt.Open(cfg.Path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, trace.Wrap(err)
+	}
+	return &Plugin{cfg: cfg, db: db, clock: clock, closeC: make(chan struct{})}, nil
+}
+
+// Close stops pruning the expired keys and closes the database,
+// the runner closes the plugin on shutdown
+func (p *Plugin) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closeC)
+	})
+	return p.db.Close()
+}
+
+// pruneExpired deletes the expired keys every PruneInterval
+// until the plugin is closed
+func (p *Plugin) pruneExpired(log force.Logger) {
+	for {
+		select {
+		case <-p.clock.After(PruneInterval):
+			if _, err := p.prune(); err != nil {
+				log.WithError(err).Warningf("Failed to delete expired keys.")
+			}
+		case <-p.closeC:
+			return
+		}
+	}
+}
+
+// prune deletes the expired keys, returns the number of deleted keys
+func (p *Plugin) prune() (int, error) {
+	var expired [][]byte
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		now := p.clock.Now()
+		err := b.ForEach(func(k, v []byte) error {
+			var r record
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if r.expired(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	return len(expired), nil
+}
+
+// record is a stored value with optional expiry time
+type record struct {
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// expired returns true if the record has expired
+func (r *record) expired(now time.Time) bool {
+	return !r.Expires.IsZero() && !now.Before(r.Expires)
+}
+
+// read returns the record, expired records are not returned
+func (p *Plugin) read(tx *bolt.Tx, key string) (*record, error) {
+	data := tx.Bucket(bucket).Get([]byte(key))
+	if data == nil {
+		return nil, nil
+	}
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if r.expired(p.clock.Now()) {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// write stores the value, zero ttl means that the value never expires
+func (p *Plugin) write(tx *bolt.Tx, key, value string, ttl time.Duration) error {
+	r := record{Value: value}
+	if ttl != 0 {
+		r.Expires = p.clock.Now().UTC().Add(ttl)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(tx.Bucket(bucket).Put([]byte(key), data))
+}
+
+// Get returns the value of the key, returns false if the key
+// does not exist or has expired
+func (p *Plugin) Get(key string) (string, bool, error) {
+	var r *record
+	err := p.db.View(func(tx *bolt.Tx) error {
+		var err error
+		r, err = p.read(tx, key)
+		return err
+	})
+	if err != nil || r == nil {
+		return "", false, trace.Wrap(err)
+	}
+	return r.Value, true, nil
+}
+
+// Set sets the value of the key
+func (p *Plugin) Set(key, value string, ttl time.Duration) error {
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		return p.write(tx, key, value, ttl)
+	})
+	return trace.Wrap(err)
+}
+
+// Delete deletes the key, returns false if the key did not exist
+func (p *Plugin) Delete(key string) (bool, error) {
+	var deleted bool
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		r, err := p.read(tx, key)
+		if err != nil {
+			return err
+		}
+		deleted = r != nil
+		return tx.Bucket(bucket).Delete([]byte(key))
+	})
+	return deleted, trace.Wrap(err)
+}
+
+// CompareAndSwap sets the value of the key if the current value
+// equals to the expected one, missing keys have empty values,
+// returns false if the value has not been swapped
+func (p *Plugin) CompareAndSwap(key, expected, value string, ttl time.Duration) (bool, error) {
+	var swapped bool
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		r, err := p.read(tx, key)
+		if err != nil {
+			return err
+		}
+		current := ""
+		if r != nil {
+			current = r.Value
+		}
+		if current != expected {
+			return nil
+		}
+		swapped = true
+		return p.write(tx, key, value, ttl)
+	})
+	return swapped, trace.Wrap(err)
+}
+
+// CreateIfNotExists sets the value of the key if the key
+// does not exist or has expired, returns false otherwise
+func (p *Plugin) CreateIfNotExists(key, value string, ttl time.Duration) (bool, error) {
+	var created bool
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		r, err := p.read(tx, key)
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			return nil
+		}
+		created = true
+		return p.write(tx, key, value, ttl)
+	})
+	return created, trace.Wrap(err)
+}
+
+// Setup creates new plugins
+type Setup struct {
+	cfg interface{}
+}
+
+// NewInstance returns function creating new plugin bound to the process group
+// and registers plugin variable
+func (n *Setup) NewInstance(group force.Group) (force.Group, interface{}) {
+	return group, func(cfg interface{}) (force.Action, error) {
+		return &Setup{
+			cfg: cfg,
+		}, nil
+	}
+}
+
+func (n *Setup) Type() interface{} {
+	return true
+}
+
+// Run sets up state plugin for the process group
+func (n *Setup) Eval(ctx force.ExecutionContext) (interface{}, error) {
+	var cfg Config
+	if err := force.EvalInto(ctx, n.cfg, &cfg); err != nil {
+		return false, trace.Wrap(err)
+	}
+	plugin, err := New(cfg, clockwork.NewRealClock())
+	if err != nil {
+		return false, trace.Wrap(err)
+	}
+	go plugin.pruneExpired(force.Log(ctx))
+	ctx.Process().Group().SetPlugin(Key, plugin)
+	return true, nil
+}
+
+// MarshalCode marshals plugin code to representation
+func (n *Setup) MarshalCode(ctx force.ExecutionContext) ([]byte, error) {
+	call := &force.FnCall{
+		Package: string(Key),
+		FnName:  KeySetup,
+		Args:    []interface{}{n.cfg},
+	}
+	return call.MarshalCode(ctx)
+}
@@ -0,0 +1,129 @@
+package state
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gravitational/force"
+
+	"github.com/jonboulle/clockwork"
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/check.v1"
+)
+
+// Bootstrap check
+func Test(t *testing.T) { check.TestingT(t) }
+
+type StateSuite struct {
+	dir    string
+	clock  clockwork.FakeClock
+	plugin *Plugin
+}
+
+var _ = check.Suite(&StateSuite{})
+
+func (s *StateSuite) SetUpTest(c *check.C) {
+	dir, err := ioutil.TempDir("", "force-state")
+	c.Assert(err, check.IsNil)
+	s.dir = dir
+	s.clock = clockwork.NewFakeClock()
+	s.plugin, err = New(Config{Path: filepath.Join(dir, "state.db")}, s.clock)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *StateSuite) TearDownTest(c *check.C) {
+	c.Assert(s.plugin.Close(), check.IsNil)
+	os.RemoveAll(s.dir)
+}
+
+func (s *StateSuite) TestKeys(c *check.C) {
+	p := s.plugin
+	_, ok, err := p.Get("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+
+	c.Assert(p.Set("a", "1", 0), check.IsNil)
+	value, ok, err := p.Get("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(value, check.Equals, "1")
+
+	created, err := p.CreateIfNotExists("a", "2", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.Equals, false)
+
+	swapped, err := p.CompareAndSwap("a", "2", "3", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(swapped, check.Equals, false)
+
+	swapped, err = p.CompareAndSwap("a", "1", "3", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(swapped, check.Equals, true)
+
+	// missing keys are compared as empty values
+	swapped, err = p.CompareAndSwap("b", "", "1", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(swapped, check.Equals, true)
+
+	deleted, err := p.Delete("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(deleted, check.Equals, true)
+	deleted, err = p.Delete("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(deleted, check.Equals, false)
+}
+
+func (s *StateSuite) TestTTL(c *check.C) {
+	p := s.plugin
+	created, err := p.CreateIfNotExists("a", "1", time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.Equals, true)
+
+	s.clock.Advance(30 * time.Second)
+	_, ok, err := p.Get("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+
+	// expired keys could be created again
+	s.clock.Advance(time.Minute)
+	_, ok, err = p.Get("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, false)
+	created, err = p.CreateIfNotExists("a", "2", 0)
+	c.Assert(err, check.IsNil)
+	c.Assert(created, check.Equals, true)
+
+	s.clock.Advance(time.Hour)
+	value, ok, err := p.Get("a")
+	c.Assert(err, check.IsNil)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(value, check.Equals, "2")
+}
+
+func (s *StateSuite) TestPrune(c *check.C) {
+	p := s.plugin
+	c.Assert(p.Set("a", "1", time.Minute), check.IsNil)
+	c.Assert(p.Set("b", "1", time.Hour), check.IsNil)
+	c.Assert(p.Set("c", "1", 0), check.IsNil)
+
+	go p.pruneExpired(force.Log(context.TODO()))
+	s.clock.BlockUntil(1)
+	s.clock.Advance(PruneInterval)
+	// the next prune is scheduled after the expired keys are deleted
+	s.clock.BlockUntil(1)
+	pruned, err := p.prune()
+	c.Assert(err, check.IsNil)
+	c.Assert(pruned, check.Equals, 0)
+
+	err = p.db.View(func(tx *bolt.Tx) error {
+		c.Assert(tx.Bucket(bucket).Get([]byte("a")), check.IsNil)
+		c.Assert(tx.Bucket(bucket).Get([]byte("b")), check.NotNil)
+		c.Assert(tx.Bucket(bucket).Get([]byte("c")), check.NotNil)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+}
@@ -0,0 +1,345 @@
+// Package tracing implements OpenTracing tracer recording spans
+// in memory and exporting them in the Chrome trace event format
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// NewRecorder returns a new tracer recording finished spans
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Recorder is a tracer recording finished spans in memory
+type Recorder struct {
+	mutex  sync.Mutex
+	lastID uint64
+	spans  []Span
+}
+
+// Span is a finished span
+type Span struct {
+	// ID is a unique span id
+	ID uint64
+	// ParentID is an id of the parent span, 0 for root spans
+	ParentID uint64
+	// Operation is a name of the span operation
+	Operation string
+	// Start is a time when the span has started
+	Start time.Time
+	// Duration is a duration of the span
+	Duration time.Duration
+	// Tags are span tags
+	Tags map[string]interface{}
+	// Logs are span logs
+	Logs []opentracing.LogRecord
+}
+
+// nextID returns a new span id
+func (r *Recorder) nextID() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastID++
+	return r.lastID
+}
+
+// finish records the finished span
+func (r *Recorder) finish(s Span) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// StartSpan starts a new span, only references
+// to the spans of the recorder are supported
+func (r *Recorder) StartSpan(operation string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	var options opentracing.StartSpanOptions
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+	s := &span{
+		recorder: r,
+		Span: Span{
+			ID:        r.nextID(),
+			Operation: operation,
+			Start:     options.StartTime,
+			Tags:      make(map[string]interface{}, len(options.Tags)),
+		},
+		baggage: make(map[string]string),
+	}
+	if s.Start.IsZero() {
+		s.Start = time.Now()
+	}
+	for k, v := range options.Tags {
+		s.Tags[k] = v
+	}
+	for _, ref := range options.References {
+		if parent, ok := ref.ReferencedContext.(spanContext); ok {
+			s.ParentID = parent.id
+			for k, v := range parent.baggage {
+				s.baggage[k] = v
+			}
+			break
+		}
+	}
+	return s
+}
+
+// Inject is not supported, spans are recorded within the process
+func (r *Recorder) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	return opentracing.ErrUnsupportedFormat
+}
+
+// Extract is not supported, spans are recorded within the process
+func (r *Recorder) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	return nil, opentracing.ErrUnsupportedFormat
+}
+
+// Spans returns finished spans, the earliest spans go first
+func (r *Recorder) Spans() []Span {
+	r.mutex.Lock()
+	out := make([]Span, len(r.spans))
+	copy(out, r.spans)
+	r.mutex.Unlock()
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Start.Equal(out[j].Start) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out
+}
+
+// chromeEvent is a complete event of the Chrome trace event format
+type chromeEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur"`
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes finished spans in the Chrome trace event format,
+// the trace could be opened in chrome://tracing or https://ui.perfetto.dev,
+// spans running in parallel are placed on separate tracks
+func (r *Recorder) WriteChromeTrace(w io.Writer) error {
+	spans := r.Spans()
+	events := make([]chromeEvent, 0, len(spans))
+	lanes := newLanes()
+	for _, s := range spans {
+		args := make(map[string]interface{}, len(s.Tags))
+		for k, v := range s.Tags {
+			args[k] = fmt.Sprintf("%v", v)
+		}
+		for _, record := range s.Logs {
+			for _, field := range record.Fields {
+				if field.Key() == "error" || field.Key() == "message" {
+					args[field.Key()] = fmt.Sprintf("%v", field.Value())
+				}
+			}
+		}
+		events = append(events, chromeEvent{
+			Name:      s.Operation,
+			Category:  "force",
+			Phase:     "X",
+			Timestamp: s.Start.UnixNano() / int64(time.Microsecond),
+			Duration:  int64(s.Duration / time.Microsecond),
+			PID:       1,
+			TID:       lanes.place(s),
+			Args:      args,
+		})
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	}, "", "  ")
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	_, err = w.Write(data)
+	return trace.ConvertSystemError(err)
+}
+
+// newLanes returns new tracks of the trace
+func newLanes() *lanes {
+	return &lanes{spans: make(map[uint64]int)}
+}
+
+// lanes places spans on tracks, so spans on the same
+// track are nested within each other
+type lanes struct {
+	// open are stacks of spans open on every track
+	open [][]Span
+	// spans maps span ids to their tracks
+	spans map[uint64]int
+}
+
+// place returns a track of the span, spans are placed
+// in the order they have started, a span is placed on the track
+// of the parent, unless it overlaps with a sibling running in parallel
+func (l *lanes) place(s Span) int {
+	candidates := make([]int, 0, len(l.open)+1)
+	if lane, ok := l.spans[s.ParentID]; ok {
+		candidates = append(candidates, lane)
+	}
+	for i := range l.open {
+		candidates = append(candidates, i)
+	}
+	for _, lane := range candidates {
+		stack := l.open[lane]
+		for len(stack) > 0 && !stack[len(stack)-1].Start.Add(stack[len(stack)-1].Duration).After(s.Start) {
+			stack = stack[:len(stack)-1]
+		}
+		l.open[lane] = stack
+		if len(stack) == 0 || stack[len(stack)-1].ID == s.ParentID {
+			l.open[lane] = append(stack, s)
+			l.spans[s.ID] = lane
+			return lane
+		}
+	}
+	l.open = append(l.open, []Span{s})
+	l.spans[s.ID] = len(l.open) - 1
+	return len(l.open) - 1
+}
+
+// spanContext is a context of the recorded span
+type spanContext struct {
+	id      uint64
+	baggage map[string]string
+}
+
+// ForeachBaggageItem calls handler for every baggage item
+func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+// span is a span in progress
+type span struct {
+	Span
+	mutex    sync.Mutex
+	recorder *Recorder
+	baggage  map[string]string
+	finished bool
+}
+
+// Finish finishes the span
+func (s *span) Finish() {
+	s.FinishWithOptions(opentracing.FinishOptions{})
+}
+
+// FinishWithOptions finishes the span and records it,
+// spans are recorded only once
+func (s *span) FinishWithOptions(opts opentracing.FinishOptions) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.finished {
+		return
+	}
+	s.finished = true
+	finish := opts.FinishTime
+	if finish.IsZero() {
+		finish = time.Now()
+	}
+	s.Duration = finish.Sub(s.Start)
+	s.Logs = append(s.Logs, opts.LogRecords...)
+	s.recorder.finish(s.Span)
+}
+
+// Context returns the span context
+func (s *span) Context() opentracing.SpanContext {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	baggage := make(map[string]string, len(s.baggage))
+	for k, v := range s.baggage {
+		baggage[k] = v
+	}
+	return spanContext{id: s.ID, baggage: baggage}
+}
+
+// SetOperationName sets the name of the span operation
+func (s *span) SetOperationName(operation string) opentracing.Span {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Operation = operation
+	return s
+}
+
+// SetTag sets the span tag
+func (s *span) SetTag(key string, value interface{}) opentracing.Span {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Tags[key] = value
+	return s
+}
+
+// LogFields records the log fields
+func (s *span) LogFields(fields ...log.Field) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Logs = append(s.Logs, opentracing.LogRecord{Timestamp: time.Now(), Fields: fields})
+}
+
+// LogKV records alternating keys and values
+func (s *span) LogKV(alternatingKeyValues ...interface{}) {
+	fields, err := log.InterleavedKVToFields(alternatingKeyValues...)
+	if err != nil {
+		s.LogFields(log.Error(err), log.String("function", "LogKV"))
+		return
+	}
+	s.LogFields(fields...)
+}
+
+// SetBaggageItem sets the baggage item propagated to the child spans
+func (s *span) SetBaggageItem(key, value string) opentracing.Span {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.baggage[key] = value
+	return s
+}
+
+// BaggageItem returns the baggage item
+func (s *span) BaggageItem(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.baggage[key]
+}
+
+// Tracer returns the recorder
+func (s *span) Tracer() opentracing.Tracer {
+	return s.recorder
+}
+
+// LogEvent is deprecated
+func (s *span) LogEvent(event string) {
+	s.LogFields(log.String("event", event))
+}
+
+// LogEventWithPayload is deprecated
+func (s *span) LogEventWithPayload(event string, payload interface{}) {
+	s.LogFields(log.String("event", event), log.Object("payload", payload))
+}
+
+// Log is deprecated
+func (s *span) Log(data opentracing.LogData) {
+	record := data.ToLogRecord()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Logs = append(s.Logs, record)
+}
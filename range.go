@@ -0,0 +1,283 @@
+package force
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+
+	"github.com/gravitational/trace"
+)
+
+// elementPrototype returns a prototype value with the type of the list element,
+// lists of structs, e.g. batched events, are supported by for/range statements
+func elementPrototype(list Expression) (interface{}, error) {
+	listType := reflect.TypeOf(list.Type())
+	if listType == nil || listType.Kind() != reflect.Slice {
+		return nil, trace.BadParameter("expected list, got %v", list.Type())
+	}
+	switch listType.Elem().Kind() {
+	case reflect.String:
+		return String(""), nil
+	case reflect.Int:
+		return Int(0), nil
+	case reflect.Bool:
+		return Bool(false), nil
+	case reflect.Struct:
+		return reflect.Zero(listType.Elem()).Interface(), nil
+	}
+	return nil, trace.BadParameter("list of %v is not supported", listType.Elem())
+}
+
+// elementLiteral converts evaluated list element to expression
+func elementLiteral(v reflect.Value) (Expression, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return String(v.String()), nil
+	case reflect.Int:
+		return Int(v.Int()), nil
+	case reflect.Bool:
+		return Bool(v.Bool()), nil
+	}
+	return nil, trace.BadParameter("list element %v is not supported", v.Type())
+}
+
+// isBlank returns true if the variable name is empty or blank
+func isBlank(name string) bool {
+	return name == "" || name == "_"
+}
+
+// RangeVars defines index and element variables of the for/range
+// statement in the lexical scope of the loop
+func RangeVars(group Group, list Expression, key, value string) error {
+	proto, err := elementPrototype(list)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if !isBlank(key) {
+		if err := group.AddDefinition(key, Int(0)); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if !isBlank(value) {
+		if err := group.AddDefinition(value, proto); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// Range returns action lowered from for/range statement
+// over the list, key and value are names of the index and element
+// variables defined with RangeVars, blank names are ignored:
+//
+// for _, target := range Strings("linux", "darwin") {
+// Infof("Building %v", target)
+// }
+//
+func Range(list Expression, key, value string, body *BlockAction) (*RangeAction, error) {
+	if _, err := elementPrototype(list); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &RangeAction{
+		list:  list,
+		key:   key,
+		value: value,
+		body:  body,
+	}, nil
+}
+
+// RangeAction evaluates the body for each element of the list
+type RangeAction struct {
+	list  Expression
+	key   string
+	value string
+	body  *BlockAction
+}
+
+// Type returns type of the for/range statement
+func (r *RangeAction) Type() interface{} {
+	return false
+}
+
+// Children returns the list and the body of the loop
+func (r *RangeAction) Children() []interface{} {
+	return []interface{}{r.list, r.body}
+}
+
+// Eval evaluates the statement in a new scope
+func (r *RangeAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	return r.EvalWithScope(WithRuntimeScope(ctx))
+}
+
+// EvalWithScope evaluates the list once and then evaluates
+// the body in a new scope for every element of the list
+func (r *RangeAction) EvalWithScope(ctx ExecutionContext) (interface{}, error) {
+	list, err := r.list.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	values := reflect.ValueOf(list)
+	if values.Kind() != reflect.Slice {
+		return nil, trace.BadParameter("expected list, got %T", list)
+	}
+	for i := 0; i < values.Len(); i++ {
+		iterCtx := WithRuntimeScope(ctx)
+		if !isBlank(r.key) {
+			iterCtx.SetValue(ContextKey(r.key), i)
+		}
+		if !isBlank(r.value) {
+			iterCtx.SetValue(ContextKey(r.value), values.Index(i).Interface())
+		}
+		if _, err := r.body.Eval(iterCtx); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return false, nil
+}
+
+// MarshalCode marshals action into the for/range statement
+func (r *RangeAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, "for ")
+	if !isBlank(r.key) || !isBlank(r.value) {
+		key := r.key
+		if isBlank(key) {
+			key = "_"
+		}
+		io.WriteString(buf, key)
+		if !isBlank(r.value) {
+			io.WriteString(buf, ", "+r.value)
+		}
+		io.WriteString(buf, " := ")
+	}
+	io.WriteString(buf, "range ")
+	data, err := MarshalCode(ctx, r.list)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	buf.Write(data)
+	io.WriteString(buf, " ")
+	data, err = r.body.MarshalCode(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// NewForEach creates a new function calling lambda
+// for each element of the list
+type NewForEach struct {
+}
+
+// NewInstance returns a new instance
+func (n *NewForEach) NewInstance(group Group) (Group, interface{}) {
+	return WithLexicalScope(group), ForEach
+}
+
+// ForEach calls lambda function with each element of the list
+// and evaluates to the list of results, optional parallelism
+// sets how many elements are processed at the same time, 1 by default:
+//
+// ForEach(Strings("linux", "darwin"), func(target string){
+// Command("make build-" + target)
+// }, 2)
+//
+func ForEach(list Expression, fn Expression, parallelism ...Expression) (Action, error) {
+	proto, err := elementPrototype(list)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	lambda, err := ExpectLambdaFunction(fn)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(lambda.Statements) == 0 {
+		return nil, trace.BadParameter("ForEach function should have at least one statement")
+	}
+	call := &LambdaFunctionCall{Expression: fn, Arguments: []interface{}{proto}}
+	if err := call.CheckCall(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	var p Expression = Int(1)
+	switch len(parallelism) {
+	case 0:
+	case 1:
+		if err := ExpectInt(parallelism[0]); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		p = parallelism[0]
+	default:
+		return nil, trace.BadParameter("only one parallelism argument is allowed")
+	}
+	return &ForEachAction{
+		list:        list,
+		fn:          fn,
+		parallelism: p,
+		resultType:  call.Type(),
+	}, nil
+}
+
+// ForEachAction calls lambda function with each element of the list
+type ForEachAction struct {
+	list        Expression
+	fn          Expression
+	parallelism Expression
+	resultType  interface{}
+}
+
+// Type returns a slice of lambda function results
+func (f *ForEachAction) Type() interface{} {
+	sliceType := reflect.SliceOf(reflect.TypeOf(f.resultType))
+	return reflect.Zero(sliceType).Interface()
+}
+
+// Children returns the list and the function
+func (f *ForEachAction) Children() []interface{} {
+	return []interface{}{f.list, f.fn, f.parallelism}
+}
+
+// Eval evaluates the list and runs lambda function calls in parallel
+func (f *ForEachAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	parallelism, err := EvalInt(ctx, f.parallelism)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if parallelism < 1 {
+		return nil, trace.BadParameter("parallelism should be at least 1, got %v", parallelism)
+	}
+	list, err := f.list.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	values := reflect.ValueOf(list)
+	if values.Kind() != reflect.Slice {
+		return nil, trace.BadParameter("expected list, got %T", list)
+	}
+	if values.Len() == 0 {
+		sliceType := reflect.SliceOf(reflect.TypeOf(f.resultType))
+		return reflect.MakeSlice(sliceType, 0, 0).Interface(), nil
+	}
+	actions := make([]Action, values.Len())
+	for i := range actions {
+		arg, err := elementLiteral(values.Index(i))
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		actions[i] = &LambdaFunctionCall{Expression: f.fn, Arguments: []interface{}{arg}}
+	}
+	p := &ParallelAction{
+		actions:       actions,
+		maxConcurrent: parallelism,
+	}
+	return p.Eval(ctx)
+}
+
+// MarshalCode marshals action into code representation
+func (f *ForEachAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	call := &FnCall{
+		Fn:   ForEach,
+		Args: []interface{}{f.list, f.fn, f.parallelism},
+	}
+	return call.MarshalCode(ctx)
+}
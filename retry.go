@@ -0,0 +1,231 @@
+package force
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitational/force/pkg/retry"
+
+	"github.com/cenkalti/backoff"
+	"github.com/gravitational/trace"
+)
+
+// RetryPolicy sets up how failed actions are retried
+type RetryPolicy struct {
+	// Attempts is a maximum number of attempts, 3 by default
+	Attempts int
+	// Initial is a backoff period after the first attempt, 1 second by default
+	Initial time.Duration
+	// Max is a maximum backoff period, 1 minute by default
+	Max time.Duration
+	// Multiplier increases the backoff period after every attempt, 2 by default
+	Multiplier float64
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (r *RetryPolicy) CheckAndSetDefaults() error {
+	if r.Attempts < 0 {
+		return trace.BadParameter("RetryPolicy Attempts can not be negative")
+	}
+	if r.Attempts == 0 {
+		r.Attempts = 3
+	}
+	if r.Initial < 0 || r.Max < 0 {
+		return trace.BadParameter("RetryPolicy Initial and Max can not be negative")
+	}
+	if r.Initial == 0 {
+		r.Initial = time.Second
+	}
+	if r.Max == 0 {
+		r.Max = time.Minute
+	}
+	if r.Max < r.Initial {
+		return trace.BadParameter("RetryPolicy Max %v is less than Initial %v", r.Max, r.Initial)
+	}
+	if r.Multiplier == 0 {
+		r.Multiplier = 2
+	}
+	if r.Multiplier < 1 {
+		return trace.BadParameter("RetryPolicy Multiplier should be at least 1, got %v", r.Multiplier)
+	}
+	return nil
+}
+
+// backOff returns exponential backoff limited by the number of attempts
+func (r *RetryPolicy) backOff() backoff.BackOff {
+	b := retry.NewUnlimitedExponentialBackOff()
+	b.InitialInterval = r.Initial
+	b.MaxInterval = r.Max
+	b.Multiplier = r.Multiplier
+	b.Reset()
+	return backoff.WithMaxRetries(b, uint64(r.Attempts-1))
+}
+
+// Retry evaluates the action until it succeeds or the attempts
+// of the retry policy are exhausted, the number of the current
+// attempt is available as Attempt():
+//
+// Retry(RetryPolicy{Attempts: 5, Initial: "10s"}, func(){
+// Infof("Pushing image, attempt %v", Attempt())
+// builder.Push(image)
+// }())
+//
+func Retry(policy interface{}, action Action) (Action, error) {
+	action, err := callableAction(action)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &RetryAction{
+		policy: policy,
+		action: action,
+	}, nil
+}
+
+// RetryAction retries the action
+type RetryAction struct {
+	policy interface{}
+	action Action
+}
+
+// Type returns the type of the action
+func (r *RetryAction) Type() interface{} {
+	return r.action.Type()
+}
+
+// Children returns the retried action
+func (r *RetryAction) Children() []interface{} {
+	return []interface{}{r.action}
+}
+
+// Eval evaluates the action, every attempt is evaluated in a new scope
+func (r *RetryAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	var policy RetryPolicy
+	if err := EvalInto(ctx, r.policy, &policy); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := policy.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ctx, span := StartSpan(ctx, "Retry")
+	var out interface{}
+	attempt := 0
+	err := retry.WithInterval(ctx, policy.backOff(), func() error {
+		attempt++
+		attemptCtx, attemptSpan := StartSpan(WithRuntimeScope(ctx), "Attempt")
+		attemptSpan.SetTag("attempt", attempt)
+		attemptCtx.SetValue(KeyAttempt, attempt)
+		var err error
+		out, err = r.action.Eval(attemptCtx)
+		FinishSpan(attemptSpan, err)
+		if err == nil {
+			return nil
+		}
+		// return statements stop the evaluation, they are not failures
+		if _, ok := isReturn(err); ok {
+			return backoff.Permanent(err)
+		}
+		Log(ctx).WithError(err).Warningf("Attempt %v of %v has failed.", attempt, policy.Attempts)
+		return err
+	})
+	FinishSpan(span, err)
+	return out, err
+}
+
+// MarshalCode marshals action into code representation
+func (r *RetryAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Retry, r.policy, r.action).MarshalCode(ctx)
+}
+
+// Attempt returns expression evaluating to the number
+// of the current Retry attempt starting from 1,
+// outside of Retry it evaluates to 0
+func Attempt() Expression {
+	return &AttemptExpr{}
+}
+
+// AttemptExpr evaluates to the number of the current attempt
+type AttemptExpr struct {
+}
+
+// Type returns int type
+func (a *AttemptExpr) Type() interface{} {
+	return 0
+}
+
+// Eval returns the number of the current attempt
+func (a *AttemptExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	attempt, ok := ctx.Value(KeyAttempt).(int)
+	if !ok {
+		return 0, nil
+	}
+	return attempt, nil
+}
+
+// MarshalCode marshals expression into code representation
+func (a *AttemptExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Attempt).MarshalCode(ctx)
+}
+
+// Timeout evaluates the action and cancels it if it does not
+// complete in time, timeout is a duration string or a duration expression:
+//
+// Timeout("10m", kube.Run(job))
+//
+func Timeout(timeout Expression, action Action) (Action, error) {
+	action, err := callableAction(action)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	converted, err := DurationVar{}.Convert(timeout)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &TimeoutAction{
+		timeout: converted.(DurationVar),
+		action:  action,
+	}, nil
+}
+
+// TimeoutAction cancels the action after the timeout
+type TimeoutAction struct {
+	timeout DurationVar
+	action  Action
+}
+
+// Type returns the type of the action
+func (t *TimeoutAction) Type() interface{} {
+	return t.action.Type()
+}
+
+// Children returns the action
+func (t *TimeoutAction) Children() []interface{} {
+	return []interface{}{t.timeout, t.action}
+}
+
+// Eval evaluates the action with the context cancelled after the timeout,
+// so shell commands, kubernetes jobs and SSH sessions are stopped
+func (t *TimeoutAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	out, err := t.timeout.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	timeout, ok := out.(time.Duration)
+	if !ok || timeout <= 0 {
+		return nil, trace.BadParameter("Timeout should be a positive duration, got %v", out)
+	}
+	spanCtx, span := StartSpan(ctx, "Timeout")
+	span.SetTag("timeout", timeout.String())
+	timeoutCtx, cancel := WithTimeout(spanCtx, timeout)
+	defer cancel()
+	out, err = t.action.Eval(timeoutCtx)
+	if err != nil && timeoutCtx.Err() == context.DeadlineExceeded {
+		err = trace.LimitExceeded("action has timed out after %v: %v", timeout, err)
+	}
+	FinishSpan(span, err)
+	return out, err
+}
+
+// MarshalCode marshals action into code representation
+func (t *TimeoutAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Timeout, t.timeout.Expression, t.action).MarshalCode(ctx)
+}
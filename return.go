@@ -0,0 +1,121 @@
+package force
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gravitational/trace"
+)
+
+// Return returns action lowered from the return statement
+// of the lambda function, value is nil for functions
+// without return values
+func Return(lambda *LambdaFunction, value Expression) (Action, error) {
+	switch {
+	case lambda.Result == nil && value != nil:
+		return nil, trace.BadParameter("too many values to return, function has no return values")
+	case lambda.Result != nil && value == nil:
+		return nil, trace.BadParameter("not enough values to return, expected %v", ExpressionType(lambda.Result))
+	case value != nil:
+		if err := ExpectEqualTypes(value, lambda.Result); err != nil {
+			return nil, trace.BadParameter("can not use value as function return value: %v", err)
+		}
+	}
+	return &ReturnAction{value: value}, nil
+}
+
+// ReturnAction evaluates the value and stops
+// evaluation of the enclosing lambda function
+type ReturnAction struct {
+	value Expression
+}
+
+// Children returns the returned value
+func (r *ReturnAction) Children() []interface{} {
+	if r.value == nil {
+		return nil
+	}
+	return []interface{}{r.value}
+}
+
+// returnSignal is returned by the return action as an error
+// to stop evaluation of all enclosing statements up to
+// the lambda function call
+type returnSignal struct {
+	value interface{}
+}
+
+// Error returns error message, normally this is never seen,
+// because lambda function calls intercept the return signal
+func (r *returnSignal) Error() string {
+	return "return statement outside of the function"
+}
+
+// isReturn returns return signal if the error is a return signal
+func isReturn(err error) (*returnSignal, bool) {
+	if err == nil {
+		return nil, false
+	}
+	ret, ok := trace.Unwrap(err).(*returnSignal)
+	return ret, ok
+}
+
+// Type returns type of the returned value
+func (r *ReturnAction) Type() interface{} {
+	if r.value == nil {
+		return false
+	}
+	return r.value.Type()
+}
+
+// Eval evaluates the returned value
+func (r *ReturnAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	if r.value == nil {
+		return nil, &returnSignal{}
+	}
+	v, err := r.value.Eval(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return v, &returnSignal{value: v}
+}
+
+// MarshalCode marshals action into the return statement
+func (r *ReturnAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	if r.value == nil {
+		return []byte("return"), nil
+	}
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, "return ")
+	data, err := MarshalCode(ctx, r.value)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// terminates returns true if the statement always ends
+// with the return statement
+func terminates(a Action) bool {
+	switch s := a.(type) {
+	case *ReturnAction:
+		return true
+	case *BlockAction:
+		return len(s.actions) != 0 && terminates(s.actions[len(s.actions)-1])
+	case *IfAction:
+		return s.statement && s.elseAction != nil && terminates(s.action) && terminates(s.elseAction)
+	case *SwitchAction:
+		hasDefault := false
+		for _, c := range s.cases {
+			if len(c.Values) == 0 {
+				hasDefault = true
+			}
+			if !terminates(c.Body) {
+				return false
+			}
+		}
+		return hasDefault
+	}
+	return false
+}
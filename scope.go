@@ -45,6 +45,31 @@ func WithParent(group Group, parent interface{}) *LexScope {
 	}
 }
 
+// WithArgumentScope wraps a group to create a new lexical scope
+// shared by all arguments of the function call, so the definitions
+// added by one argument, e.g. event of the channel, are visible
+// in the following arguments
+func WithArgumentScope(group Group) *ArgumentScope {
+	return &ArgumentScope{LexScope: WithLexicalScope(group)}
+}
+
+// ArgumentScope is a lexical scope shared by the function arguments
+type ArgumentScope struct {
+	*LexScope
+}
+
+// LocalDefinition returns the definition added by the arguments,
+// the definitions of the wrapped group are not returned
+func (a *ArgumentScope) LocalDefinition(name string) (interface{}, error) {
+	a.RLock()
+	defer a.RUnlock()
+	v, ok := a.defs[name]
+	if !ok {
+		return nil, trace.NotFound("%v is not defined", name)
+	}
+	return v, nil
+}
+
 // LexScope wraps a group to create a new lexical scope
 type LexScope struct {
 	*sync.RWMutex
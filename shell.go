@@ -1,6 +1,7 @@
 package force
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -125,6 +126,24 @@ func (s ExpressionSlice) Vars() []Expression {
 	return []Expression(s)
 }
 
+// marshalSlice marshals slice of expressions into composite literal
+func marshalSlice(ctx ExecutionContext, elemType string, s []Expression) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, "[]"+elemType+"{")
+	for i := range s {
+		if i != 0 {
+			io.WriteString(buf, ", ")
+		}
+		data, err := MarshalCode(ctx, s[i])
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		buf.Write(data)
+	}
+	io.WriteString(buf, "}")
+	return buf.Bytes(), nil
+}
+
 // StringSlice represents a slice of strings
 type StringSlice []Expression
 
@@ -158,9 +177,9 @@ func (s StringSlice) Vars() []Expression {
 // IntSlice represents a slice of integers
 type IntSlice []Expression
 
-// MarshalCode
+// MarshalCode marshals slice into composite literal
 func (s IntSlice) MarshalCode(ctx ExecutionContext) ([]byte, error) {
-	return MarshalCode(ctx, []Expression(s))
+	return marshalSlice(ctx, IntType, s)
 }
 
 // Eval evaluates a list of var references to types
@@ -181,9 +200,9 @@ func (s IntSlice) Vars() []Expression {
 // BoolSlice represents a slice of integers
 type BoolSlice []Expression
 
-// MarshalCode
+// MarshalCode marshals slice into composite literal
 func (s BoolSlice) MarshalCode(ctx ExecutionContext) ([]byte, error) {
-	return MarshalCode(ctx, []Expression(s))
+	return marshalSlice(ctx, BoolType, s)
 }
 
 // Eval evaluates a list of var references to types
@@ -305,10 +324,12 @@ func (s *ShellAction) Type() interface{} {
 
 // Eval runs shell script and returns output as a string
 func (s *ShellAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	ctx, span := StartSpan(ctx, "Shell")
 	w := Writer(Log(ctx))
 	defer w.Close()
 	buf := NewSyncBuffer()
 	err := s.run(ctx, io.MultiWriter(w, buf))
+	FinishSpan(span, err)
 	return buf.String(), trace.Wrap(err)
 }
 
@@ -353,10 +374,11 @@ func (s *ShellAction) run(ctx ExecutionContext, w io.Writer) error {
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	ActiveSpan(ctx).SetTag("command", strings.Join(args, " "))
 	if echoArgs {
 		fmt.Fprintln(w, strings.Join(args, " "))
 	}
-	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdout = w
 	cmd.Stderr = w
 	cmd.Env = env
@@ -364,7 +386,27 @@ func (s *ShellAction) run(ctx ExecutionContext, w io.Writer) error {
 	if exportEnv {
 		cmd.Env = append(cmd.Env, os.Environ()...)
 	}
-	return trace.Wrap(cmd.Run())
+	// the command runs in it's own process group,
+	// so processes started by the shell are killed
+	// together with the shell when the context is cancelled
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return trace.Wrap(err)
+	}
+	doneC := make(chan struct{})
+	defer close(doneC)
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-doneC:
+		}
+	}()
+	err = cmd.Wait()
+	if err != nil && ctx.Err() != nil {
+		return trace.Wrap(ctx.Err())
+	}
+	return trace.Wrap(err)
 }
 
 func (s *ShellAction) String() string {
@@ -396,11 +438,59 @@ func Parallel(actions ...Action) (Action, error) {
 	}, nil
 }
 
+// ParallelConfig sets up how actions are run in parallel
+type ParallelConfig struct {
+	// MaxConcurrent limits the number of actions
+	// running at the same time, unlimited if 0
+	MaxConcurrent int
+	// FailFast cancels other actions on the first error,
+	// otherwise all actions run to completion
+	FailFast bool
+}
+
+// CheckAndSetDefaults checks and sets default values
+func (c *ParallelConfig) CheckAndSetDefaults() error {
+	if c.MaxConcurrent < 0 {
+		return trace.BadParameter("ParallelConfig MaxConcurrent can not be negative")
+	}
+	return nil
+}
+
+// NewParallelWith creates a new series of actions executed
+// in parallel with the config
+type NewParallelWith struct {
+}
+
+// NewInstance returns a new instance
+func (n *NewParallelWith) NewInstance(group Group) (Group, interface{}) {
+	return WithLexicalScope(group), ParallelWith
+}
+
+// ParallelWith runs actions in parallel using the config, for example:
+//
+// ParallelWith(ParallelConfig{MaxConcurrent: 2, FailFast: true},
+// Command("make test"), Command("make lint"), Command("make docs"))
+//
+func ParallelWith(config interface{}, actions ...Action) (Action, error) {
+	out, err := Parallel(actions...)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	p := out.(*ParallelAction)
+	p.config = config
+	return p, nil
+}
+
 // ParallelAction runs actions in parallel
 // waits for all to complete, if any of them fail,
 // returns error
 type ParallelAction struct {
 	actions []Action
+	// maxConcurrent limits the number of actions
+	// running at the same time, unlimited if 0
+	maxConcurrent int
+	// config is an optional ParallelConfig
+	config interface{}
 }
 
 // Type returns a slice of actions' results
@@ -410,57 +500,117 @@ func (p *ParallelAction) Type() interface{} {
 	return reflect.Zero(sliceType).Interface()
 }
 
+// Children returns actions running in parallel
+func (p *ParallelAction) Children() []interface{} {
+	return actionNodes(p.actions)
+}
+
 type result struct {
+	// index is the index of the action
+	index int
 	err   error
 	value interface{}
 }
 
 // Eval runs actions in parallel and returns a slice of results
+// in the order of the actions
 func (p *ParallelAction) Eval(ctx ExecutionContext) (interface{}, error) {
-	scopeCtx := WithRuntimeScope(ctx)
-	resultsC := make(chan result, len(p.actions))
-	for _, action := range p.actions {
-		go p.runAction(scopeCtx, action, resultsC)
+	config := ParallelConfig{MaxConcurrent: p.maxConcurrent}
+	if p.config != nil {
+		if err := EvalInto(ctx, p.config, &config); err != nil {
+			return nil, trace.Wrap(err)
+		}
 	}
-	var errors []error
+	if err := config.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	ctx, span := StartSpan(ctx, "Parallel")
+	out, err := p.eval(ctx, config)
+	FinishSpan(span, err)
+	return out, err
+}
+
+// eval runs actions in parallel
+func (p *ParallelAction) eval(ctx ExecutionContext, config ParallelConfig) (interface{}, error) {
+	// actions that have not completed are cancelled
+	// on the first error in fail fast mode
+	scopeCtx, cancel := WithCancel(ctx)
+	defer cancel()
+	resultsC := make(chan result, len(p.actions))
+	var semC chan struct{}
+	if config.MaxConcurrent > 0 {
+		semC = make(chan struct{}, config.MaxConcurrent)
+	}
+	go func() {
+		for i, action := range p.actions {
+			if semC != nil {
+				select {
+				case semC <- struct{}{}:
+				case <-scopeCtx.Done():
+					// actions waiting for their turn are not started
+					resultsC <- result{index: i, err: trace.Wrap(scopeCtx.Err())}
+					continue
+				}
+			}
+			go p.runAction(scopeCtx, i, action, semC, resultsC)
+		}
+	}()
+	errors := make([]error, len(p.actions))
+	var firstErr error
 	values := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(p.actions[0].Type())), len(p.actions), len(p.actions))
 	for i := 0; i < len(p.actions); i++ {
 		select {
 		case out := <-resultsC:
 			if out.err != nil {
-				Log(ctx).WithError(out.err).Warningf("Action %v has failed.", p.actions[i])
-				errors = append(errors, out.err)
+				Log(ctx).WithError(out.err).Warningf("Action %v has failed.", p.actions[out.index])
+				errors[out.index] = out.err
+				if firstErr == nil {
+					firstErr = out.err
+				}
+				if config.FailFast {
+					cancel()
+				}
 			} else {
-				values.Index(i).Set(reflect.ValueOf(out.value))
+				values.Index(out.index).Set(reflect.ValueOf(out.value))
 			}
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		}
 	}
-	if len(errors) > 0 {
-		SetError(ctx, trace.NewAggregate(errors...))
+	if firstErr == nil {
+		return values.Interface(), nil
 	}
-	return values.Interface(), Error(ctx)
+	// in fail fast mode, other actions fail because they were cancelled
+	if config.FailFast {
+		SetError(ctx, firstErr)
+		return values.Interface(), trace.Wrap(firstErr)
+	}
+	err := trace.NewAggregate(errors...)
+	SetError(ctx, err)
+	return values.Interface(), err
 }
 
 // MarshalCode marshals action into code representation
 func (p *ParallelAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 	call := &FnCall{
-		Fn:   Parallel,
-		Args: make([]interface{}, len(p.actions)),
+		Fn: Parallel,
+	}
+	if p.config != nil {
+		call.Fn = ParallelWith
+		call.Args = append(call.Args, p.config)
 	}
 	for i := range p.actions {
-		call.Args[i] = p.actions[i]
+		call.Args = append(call.Args, p.actions[i])
 	}
 	return call.MarshalCode(ctx)
 }
 
-func (p *ParallelAction) runAction(ctx ExecutionContext, action Action, errC chan result) {
+func (p *ParallelAction) runAction(ctx ExecutionContext, index int, action Action, semC chan struct{}, resultsC chan result) {
 	value, err := action.Eval(ctx)
-	select {
-	case errC <- result{value: value, err: err}:
-	case <-ctx.Done():
+	if semC != nil {
+		<-semC
 	}
+	resultsC <- result{index: index, value: value, err: err}
 }
 
 // Defer defers the action executed in sequence
@@ -479,6 +629,11 @@ func (d *DeferAction) Type() interface{} {
 	return d.action.Type()
 }
 
+// Children returns deferred action
+func (d *DeferAction) Children() []interface{} {
+	return []interface{}{d.action}
+}
+
 // Run runs deferred action
 func (d *DeferAction) Eval(ctx ExecutionContext) (interface{}, error) {
 	return d.action.Eval(ctx)
@@ -520,6 +675,11 @@ func (p *SequenceAction) Type() interface{} {
 	return p.actions[len(p.actions)-1].Type()
 }
 
+// Children returns actions of the sequence
+func (p *SequenceAction) Children() []interface{} {
+	return actionNodes(p.actions)
+}
+
 // MarshalCode marshals action into code representation
 func (p *SequenceAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 	call := &FnCall{
@@ -544,17 +704,27 @@ func (s *SequenceAction) EvalWithScope(ctx ExecutionContext) (interface{}, error
 			deferred = append(deferred, action)
 		}
 	}
+	// returned is set when the return statement
+	// stops the evaluation of the sequence
+	var returned error
+	// failed is the error of the sequence, errors set
+	// in the enclosing scopes are not returned
+	var failed error
 eval:
 	for i := range s.actions {
 		action := s.actions[i]
-		_, isDefer := action.(*DeferAction)
-		if isDefer {
-			deferred = append(deferred, action)
+		// deferred actions are collected above
+		if _, isDefer := action.(*DeferAction); isDefer {
 			continue
 		}
 		last, err = action.Eval(ctx)
-		SetError(ctx, err)
+		if _, ok := isReturn(err); ok {
+			returned = err
+			break eval
+		}
 		if err != nil {
+			failed = err
+			SetError(ctx, err)
 			break eval
 		}
 	}
@@ -564,15 +734,22 @@ eval:
 		action := deferred[i]
 		_, err = action.Eval(ctx)
 		if err != nil {
+			failed = err
 			SetError(ctx, err)
 		}
 	}
-	return last, Error(ctx)
+	if returned != nil && failed == nil {
+		return last, returned
+	}
+	return last, failed
 }
 
 // Eval evaluates actions in sequence
 func (s *SequenceAction) Eval(ctx ExecutionContext) (interface{}, error) {
-	return s.EvalWithScope(WithRuntimeScope(ctx))
+	ctx, span := StartSpan(ctx, "Sequence")
+	out, err := s.EvalWithScope(WithRuntimeScope(ctx))
+	FinishSpan(span, err)
+	return out, err
 }
 
 // Test is a struct used for tests
@@ -0,0 +1,18 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package force
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing on platforms without process groups
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// killProcessGroup kills the started command, processes started
+// by the command are not killed on platforms without process groups
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,174 @@
+package force
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+
+	"github.com/gravitational/trace"
+)
+
+// SwitchCase is a case clause of the switch statement,
+// case clause without values is a default clause
+type SwitchCase struct {
+	// Values is a list of values to compare with the switch tag,
+	// or a list of boolean conditions when the tag is omitted
+	Values []Expression
+	// Body is evaluated when the case clause matches
+	Body *BlockAction
+}
+
+// Switch returns action lowered from the switch statement,
+// tag is optional, when omitted, case clause values
+// are evaluated as boolean conditions:
+//
+// switch event.Branch {
+// case "master", "main":
+// Infof("deploying")
+// default:
+// Infof("skipping")
+// }
+//
+func Switch(tag Expression, cases ...SwitchCase) (*SwitchAction, error) {
+	if tag != nil && !reflect.TypeOf(tag.Type()).Comparable() {
+		return nil, trace.BadParameter("switch is not defined for %v", tag.Type())
+	}
+	defaults := 0
+	for _, c := range cases {
+		if c.Body == nil {
+			return nil, trace.BadParameter("missing case clause body")
+		}
+		if len(c.Values) == 0 {
+			defaults++
+			if defaults > 1 {
+				return nil, trace.BadParameter("multiple defaults in switch")
+			}
+			continue
+		}
+		for _, v := range c.Values {
+			if tag == nil {
+				if err := ExpectBool(v); err != nil {
+					return nil, trace.Wrap(err)
+				}
+				continue
+			}
+			if err := ExpectEqualTypes(tag, v); err != nil {
+				return nil, trace.BadParameter("case clause value: %v", err)
+			}
+		}
+	}
+	return &SwitchAction{
+		tag:   tag,
+		cases: cases,
+	}, nil
+}
+
+// SwitchAction evaluates the first matching case clause,
+// or the default clause if no other case clauses matched
+type SwitchAction struct {
+	tag   Expression
+	cases []SwitchCase
+}
+
+// Type returns the type of the first case clause
+func (s *SwitchAction) Type() interface{} {
+	if len(s.cases) == 0 {
+		return false
+	}
+	return s.cases[0].Body.Type()
+}
+
+// Children returns tag, case values and bodies
+func (s *SwitchAction) Children() []interface{} {
+	out := []interface{}{s.tag}
+	for _, c := range s.cases {
+		for _, v := range c.Values {
+			out = append(out, v)
+		}
+		out = append(out, c.Body)
+	}
+	return out
+}
+
+// Eval evaluates switch statement in a new scope
+func (s *SwitchAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	return s.EvalWithScope(WithRuntimeScope(ctx))
+}
+
+// EvalWithScope evaluates the tag once, and then evaluates
+// case clause values in order until the first match
+func (s *SwitchAction) EvalWithScope(ctx ExecutionContext) (interface{}, error) {
+	return EvalWithSpan(ctx, "Switch", s.eval)
+}
+
+// eval evaluates the tag and the matching case clause
+func (s *SwitchAction) eval(ctx ExecutionContext) (interface{}, error) {
+	var tag interface{} = true
+	if s.tag != nil {
+		var err error
+		tag, err = s.tag.Eval(ctx)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	var defaultCase *SwitchCase
+	for i := range s.cases {
+		c := &s.cases[i]
+		if len(c.Values) == 0 {
+			defaultCase = c
+			continue
+		}
+		for _, v := range c.Values {
+			val, err := v.Eval(ctx)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			// case values are compared the same way as with ==
+			if equal(val, tag) {
+				return c.Body.Eval(ctx)
+			}
+		}
+	}
+	if defaultCase != nil {
+		return defaultCase.Body.Eval(ctx)
+	}
+	return false, nil
+}
+
+// MarshalCode marshals action into the switch statement
+func (s *SwitchAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	io.WriteString(buf, "switch ")
+	if s.tag != nil {
+		data, err := MarshalCode(ctx, s.tag)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		buf.Write(data)
+		io.WriteString(buf, " ")
+	}
+	io.WriteString(buf, "{\n")
+	for _, c := range s.cases {
+		if len(c.Values) == 0 {
+			io.WriteString(buf, "default")
+		} else {
+			io.WriteString(buf, "case ")
+			for i, v := range c.Values {
+				if i > 0 {
+					io.WriteString(buf, ", ")
+				}
+				data, err := MarshalCode(ctx, v)
+				if err != nil {
+					return nil, trace.Wrap(err)
+				}
+				buf.Write(data)
+			}
+		}
+		io.WriteString(buf, ":\n")
+		if err := c.Body.marshalStatements(ctx, buf); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	io.WriteString(buf, "}")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,372 @@
+package force
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// Duration is a constant duration
+type Duration time.Duration
+
+// MarshalCode marshals duration to code representation
+func (d Duration) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(ParseDuration, time.Duration(d).String()).MarshalCode(ctx)
+}
+
+// Eval returns duration value
+func (d Duration) Eval(ctx ExecutionContext) (interface{}, error) {
+	return time.Duration(d), nil
+}
+
+// Type returns duration type
+func (d Duration) Type() interface{} {
+	return time.Duration(0)
+}
+
+// DurationVar is a duration variable, strings
+// are parsed as durations when converted, for example "10m"
+type DurationVar struct {
+	Expression
+}
+
+// Type returns duration type
+func (d DurationVar) Type() interface{} {
+	return time.Duration(0)
+}
+
+// Eval evaluates duration variable
+func (d DurationVar) Eval(ctx ExecutionContext) (interface{}, error) {
+	if d.Expression != nil {
+		return d.Expression.Eval(ctx)
+	}
+	return time.Duration(0), nil
+}
+
+// Convert converts durations, duration expressions and strings
+// to duration variable
+func (d DurationVar) Convert(in interface{}) (interface{}, error) {
+	switch v := in.(type) {
+	case time.Duration:
+		return DurationVar{Expression: Duration(v)}, nil
+	case Duration:
+		return DurationVar{Expression: v}, nil
+	case DurationVar:
+		return v, nil
+	case string:
+		return d.Convert(String(v))
+	case Expression:
+		if reflect.TypeOf(v.Type()).AssignableTo(reflect.TypeOf(time.Duration(0))) {
+			return DurationVar{Expression: v}, nil
+		}
+		if ExpectString(v) == nil {
+			expr, err := ParseDuration(v)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			return DurationVar{Expression: expr}, nil
+		}
+		return nil, trace.BadParameter("can not assign type %v to duration variable", v.Type())
+	default:
+		return nil, trace.BadParameter("can not assign type %T to duration variable", in)
+	}
+}
+
+// Time is a constant time
+type Time time.Time
+
+// MarshalCode marshals time to code representation
+func (t Time) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(ParseTime, time.RFC3339Nano, time.Time(t).Format(time.RFC3339Nano)).MarshalCode(ctx)
+}
+
+// Eval returns time value
+func (t Time) Eval(ctx ExecutionContext) (interface{}, error) {
+	return time.Time(t), nil
+}
+
+// Type returns time type
+func (t Time) Type() interface{} {
+	return time.Time{}
+}
+
+// TimeVar is a time variable
+type TimeVar struct {
+	Expression
+}
+
+// Type returns time type
+func (t TimeVar) Type() interface{} {
+	return time.Time{}
+}
+
+// Eval evaluates time variable
+func (t TimeVar) Eval(ctx ExecutionContext) (interface{}, error) {
+	if t.Expression != nil {
+		return t.Expression.Eval(ctx)
+	}
+	return time.Time{}, nil
+}
+
+// Convert converts time and time expressions to time variable
+func (t TimeVar) Convert(in interface{}) (interface{}, error) {
+	switch v := in.(type) {
+	case time.Time:
+		return TimeVar{Expression: Time(v)}, nil
+	case Time:
+		return TimeVar{Expression: v}, nil
+	case TimeVar:
+		return v, nil
+	case Expression:
+		if reflect.TypeOf(v.Type()).AssignableTo(reflect.TypeOf(time.Time{})) {
+			return TimeVar{Expression: v}, nil
+		}
+		return nil, trace.BadParameter("can not assign type %v to time variable", v.Type())
+	default:
+		return nil, trace.BadParameter("can not assign type %T to time variable", in)
+	}
+}
+
+// ExpectDuration returns nil if expression is duration, error otherwise
+func ExpectDuration(expr Expression) error {
+	if reflect.TypeOf(expr.Type()).AssignableTo(reflect.TypeOf(time.Duration(0))) {
+		return nil
+	}
+	return trace.BadParameter("%v does not evaluate to duration", expr.Type())
+}
+
+// ExpectTime returns nil if expression is time, error otherwise
+func ExpectTime(expr Expression) error {
+	if reflect.TypeOf(expr.Type()).AssignableTo(reflect.TypeOf(time.Time{})) {
+		return nil
+	}
+	return trace.BadParameter("%v does not evaluate to time", expr.Type())
+}
+
+// Now returns current time
+func Now() Expression {
+	return &NowExpr{}
+}
+
+// NowExpr evaluates to the current time
+type NowExpr struct {
+}
+
+// Type returns time type
+func (n *NowExpr) Type() interface{} {
+	return time.Time{}
+}
+
+// Eval returns current time
+func (n *NowExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	return time.Now().UTC(), nil
+}
+
+// MarshalCode marshals expression to code
+func (n *NowExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Now).MarshalCode(ctx)
+}
+
+// Created returns time when the event that triggered
+// the execution was created
+func Created() Expression {
+	return &CreatedExpr{}
+}
+
+// CreatedExpr evaluates to the time when the event was created
+type CreatedExpr struct {
+}
+
+// Type returns time type
+func (c *CreatedExpr) Type() interface{} {
+	return time.Time{}
+}
+
+// Eval returns event creation time
+func (c *CreatedExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	event := ctx.Event()
+	if event == nil {
+		return nil, trace.NotFound("no event is associated with the execution")
+	}
+	return event.Created(), nil
+}
+
+// MarshalCode marshals expression to code
+func (c *CreatedExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Created).MarshalCode(ctx)
+}
+
+// ParseDuration parses duration from string, for example "1h10m",
+// constant durations are parsed and checked right away
+func ParseDuration(in Expression) (Expression, error) {
+	if err := ExpectString(in); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if s, ok := in.(String); ok {
+		d, err := time.ParseDuration(string(s))
+		if err != nil {
+			return nil, trace.BadParameter("failed to parse duration %q: %v, use abbreviations like s (seconds), m (minutes), h (hours), for example \"10m\"", s, err)
+		}
+		return Duration(d), nil
+	}
+	return &ParseDurationExpr{in: in}, nil
+}
+
+// ParseDurationExpr parses duration from string when evaluated
+type ParseDurationExpr struct {
+	in Expression
+}
+
+// Type returns duration type
+func (p *ParseDurationExpr) Type() interface{} {
+	return time.Duration(0)
+}
+
+// Eval parses duration
+func (p *ParseDurationExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	s, err := EvalString(ctx, p.in)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, trace.BadParameter("failed to parse duration %q: %v", s, err)
+	}
+	return d, nil
+}
+
+// MarshalCode marshals expression to code
+func (p *ParseDurationExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(ParseDuration, p.in).MarshalCode(ctx)
+}
+
+// ParseTime parses time value using the layout,
+// layout uses Go's reference time, for example "2006-01-02"
+func ParseTime(layout, value Expression) (Expression, error) {
+	for _, e := range []Expression{layout, value} {
+		if err := ExpectString(e); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return &ParseTimeExpr{layout: layout, value: value}, nil
+}
+
+// ParseTimeExpr parses time when evaluated
+type ParseTimeExpr struct {
+	layout Expression
+	value  Expression
+}
+
+// Type returns time type
+func (p *ParseTimeExpr) Type() interface{} {
+	return time.Time{}
+}
+
+// Eval parses time
+func (p *ParseTimeExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	layout, err := EvalString(ctx, p.layout)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	value, err := EvalString(ctx, p.value)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return nil, trace.BadParameter("failed to parse time %q: %v", value, err)
+	}
+	return t, nil
+}
+
+// MarshalCode marshals expression to code
+func (p *ParseTimeExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(ParseTime, p.layout, p.value).MarshalCode(ctx)
+}
+
+// Since returns duration elapsed since the time
+func Since(t Expression) (Expression, error) {
+	if err := ExpectTime(t); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &SinceExpr{t: t}, nil
+}
+
+// SinceExpr evaluates to the duration elapsed since the time
+type SinceExpr struct {
+	t Expression
+}
+
+// Type returns duration type
+func (s *SinceExpr) Type() interface{} {
+	return time.Duration(0)
+}
+
+// Eval returns the elapsed duration
+func (s *SinceExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	t, err := evalTime(ctx, s.t)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return time.Since(t), nil
+}
+
+// MarshalCode marshals expression to code
+func (s *SinceExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Since, s.t).MarshalCode(ctx)
+}
+
+// FormatTime formats time using the layout,
+// layout uses Go's reference time, for example "2006-01-02"
+func FormatTime(t, layout Expression) (Expression, error) {
+	if err := ExpectTime(t); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if err := ExpectString(layout); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &FormatTimeExpr{t: t, layout: layout}, nil
+}
+
+// FormatTimeExpr formats time when evaluated
+type FormatTimeExpr struct {
+	t      Expression
+	layout Expression
+}
+
+// Type returns string type
+func (f *FormatTimeExpr) Type() interface{} {
+	return ""
+}
+
+// Eval formats time
+func (f *FormatTimeExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	t, err := evalTime(ctx, f.t)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	layout, err := EvalString(ctx, f.layout)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return t.Format(layout), nil
+}
+
+// MarshalCode marshals expression to code
+func (f *FormatTimeExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(FormatTime, f.t, f.layout).MarshalCode(ctx)
+}
+
+// evalTime evaluates expression to time
+func evalTime(ctx ExecutionContext, in Expression) (time.Time, error) {
+	out, err := in.Eval(ctx)
+	if err != nil {
+		return time.Time{}, trace.Wrap(err)
+	}
+	t, ok := out.(time.Time)
+	if !ok {
+		return time.Time{}, trace.BadParameter("expected time, got %T", out)
+	}
+	return t, nil
+}
@@ -8,7 +8,9 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/gravitational/force/pkg/runner"
@@ -30,13 +32,32 @@ func main() {
 	app := kingpin.New("force", "Force is simple CI/CD tool")
 	app.Flag("debug", "Turn on debugging level").Short('d').BoolVar(&cfg.debug)
 	app.Flag("setup", "Path to setup file").Short('s').StringVar(&cfg.setup.Filename)
-	app.Arg("file", "Force file to run").StringVar(&cfg.force.Filename)
-
 	app.Flag("id", "Optional run ID").Envar("FORCE_ID").StringVar(&cfg.id)
 	app.Flag("setup-script", "Setup script contents").Envar("FORCE_SETUP").StringVar(&cfg.setup.Content)
-	app.Arg("file-script", "Force script contents").Envar("FORCE_SCRIPT").StringVar(&cfg.force.Content)
+	app.Flag("history", "Path to the execution history database").Envar("FORCE_HISTORY").StringVar(&cfg.history)
+
+	run := app.Command("run", "Run force script").Default()
+	run.Arg("file", "Force file to run").StringVar(&cfg.force.Filename)
+	run.Arg("file-script", "Force script contents").Envar("FORCE_SCRIPT").StringVar(&cfg.force.Content)
+	run.Flag("journal", "Path to the event journal, unfinished events are replayed after restart").Envar("FORCE_JOURNAL").StringVar(&cfg.journal)
+	run.Flag("history-max-runs", "Maximum number of runs kept in the execution history, the oldest runs are pruned").Default(strconv.Itoa(runner.DefaultHistoryMaxRuns)).IntVar(&cfg.historyMaxRuns)
+	run.Flag("history-max-age", "Maximum age of runs kept in the execution history").Default(runner.DefaultHistoryMaxAge.String()).DurationVar(&cfg.historyMaxAge)
+	run.Flag("traces", "Directory to write traces of the runs to in the Chrome trace format").Envar("FORCE_TRACES").StringVar(&cfg.traces)
+
+	vet := app.Command("vet", "Check force script and included scripts for errors without running them")
+	vet.Arg("file", "Force file to check").StringVar(&cfg.force.Filename)
+
+	var filter runner.HistoryFilter
+	history := app.Command("history", "Show execution history, the latest runs go first")
+	history.Flag("process", "Show runs of the process").StringVar(&filter.Process)
+	history.Flag("failed", "Show failed runs only").BoolVar(&filter.Failed)
+	history.Flag("event", "Show runs triggered by events containing the text, e.g. a commit or a branch name").StringVar(&filter.Event)
+
+	var runID string
+	show := app.Command("show", "Show the run with the captured log")
+	show.Arg("run-id", "ID of the run").Required().StringVar(&runID)
 
-	_, err := app.Parse(os.Args[1:])
+	command, err := app.Parse(os.Args[1:])
 	if err != nil {
 		fmt.Printf("ERROR: %v", err)
 		os.Exit(1)
@@ -47,6 +68,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	switch command {
+	case history.FullCommand():
+		if err := printHistory(cfg.history, filter); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	case show.FullCommand():
+		if err := printRun(cfg.history, runID); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := cfg.CheckAndSetDefaults(); err != nil {
 		// default file not found, print nicer help
 		if trace.IsNotFound(err) && cfg.setup.Filename == "" {
@@ -57,20 +93,37 @@ func main() {
 		os.Exit(1)
 	}
 
-	run, err := generateAndStart(ctx, cfg)
-	if err != nil {
-		if trace.IsDebug() {
-			fmt.Fprintln(os.Stderr, trace.DebugReport(err))
-		} else {
-			fmt.Fprintln(os.Stderr, err.Error())
+	if command == vet.FullCommand() {
+		if err := runner.Vet(runner.Input{
+			Context: ctx,
+			ID:      cfg.id,
+			Setup:   cfg.setup,
+			Script:  cfg.force,
+			Debug:   cfg.debug,
+		}); err != nil {
+			// print every found error on its own
+			if agg, ok := trace.Unwrap(err).(trace.Aggregate); ok {
+				for _, e := range agg.Errors() {
+					printError(e)
+				}
+			} else {
+				printError(err)
+			}
+			os.Exit(1)
 		}
+		return
+	}
+
+	proc, err := generateAndStart(ctx, cfg)
+	if err != nil {
+		printError(err)
 		os.Exit(1)
 	}
 	select {
 	case <-ctx.Done():
 		return
-	case <-run.Done():
-		event := run.ExitEvent()
+	case <-proc.Done():
+		event := proc.ExitEvent()
 		if event == nil {
 			log.Debugf("Process group has shut down with unkown status.")
 		} else {
@@ -96,11 +149,16 @@ https://force.gravitational.co/master/quickstart/
 
 func generateAndStart(ctx context.Context, cfg config) (*runner.Runner, error) {
 	run, err := runner.Parse(runner.Input{
-		Context: ctx,
-		ID:      cfg.id,
-		Setup:   cfg.setup,
-		Script:  cfg.force,
-		Debug:   cfg.debug,
+		Context:        ctx,
+		ID:             cfg.id,
+		Setup:          cfg.setup,
+		Script:         cfg.force,
+		Debug:          cfg.debug,
+		Journal:        cfg.journal,
+		History:        cfg.history,
+		HistoryMaxRuns: cfg.historyMaxRuns,
+		HistoryMaxAge:  cfg.historyMaxAge,
+		Traces:         cfg.traces,
 	})
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -109,6 +167,61 @@ func generateAndStart(ctx context.Context, cfg config) (*runner.Runner, error) {
 	return run, nil
 }
 
+// printHistory prints runs from the execution history
+func printHistory(path string, filter runner.HistoryFilter) error {
+	history, err := runner.NewHistory(path)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	runs, err := history.List(filter)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPROCESS\tSTATUS\tSTARTED\tDURATION\tEVENT")
+	for _, run := range runs {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n",
+			run.ID, run.Process, run.Status, run.Started.Format(time.RFC3339),
+			run.Duration.Round(time.Millisecond), run.Event)
+	}
+	return trace.Wrap(w.Flush())
+}
+
+// printRun prints the run with the captured log
+func printRun(path string, id string) error {
+	history, err := runner.NewHistory(path)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	run, err := history.Get(id)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	fmt.Printf("ID:       %v\n", run.ID)
+	fmt.Printf("Process:  %v\n", run.Process)
+	fmt.Printf("Status:   %v\n", run.Status)
+	fmt.Printf("Event:    %v\n", run.Event)
+	fmt.Printf("Started:  %v\n", run.Started.Format(time.RFC3339))
+	fmt.Printf("Duration: %v\n", run.Duration.Round(time.Millisecond))
+	if run.Error != "" {
+		fmt.Printf("Error:    %v\n", run.Error)
+	}
+	if run.Log != "" {
+		fmt.Printf("\n%v", run.Log)
+	}
+	return nil
+}
+
+// printError prints error to stderr,
+// in debug mode the error is printed with the stack trace
+func printError(err error) {
+	if trace.IsDebug() {
+		fmt.Fprintln(os.Stderr, trace.DebugReport(err))
+	} else {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
 // setupSignalHandlers sets up a handler to handle common unix process signal traps.
 // Some signals are handled to avoid the default handling which might be termination (SIGPIPE, SIGHUP, etc)
 // The rest are considered as termination signals and the handler initiates shutdown upon receiving
@@ -152,10 +265,17 @@ const (
 
 // config contains force cli parameters
 type config struct {
-	id    string
-	setup runner.Script
-	force runner.Script
-	debug bool
+	id      string
+	setup   runner.Script
+	force   runner.Script
+	debug   bool
+	journal string
+	history string
+	traces  string
+	// historyMaxRuns is a maximum number of runs kept in the history
+	historyMaxRuns int
+	// historyMaxAge is a maximum age of runs kept in the history
+	historyMaxAge time.Duration
 }
 
 func (c *config) CheckAndSetDefaults() error {
@@ -0,0 +1,78 @@
+package force
+
+import (
+	"context"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// StartSpan starts a span of the action evaluation, the span
+// is a child of the span of the context, the returned context
+// carries the new span and shares variables with the passed context,
+// if the context has no tracer, the global tracer is used
+func StartSpan(ctx ExecutionContext, operation string) (ExecutionContext, opentracing.Span) {
+	span := StartSpanFromContext(ctx, operation)
+	return &spanScope{ExecutionContext: ctx, span: span}, span
+}
+
+// StartSpanFromContext starts a span that is a child of the span
+// of the context, used by the actions passing the context
+// to the functions expecting standard context
+func StartSpanFromContext(ctx context.Context, operation string) opentracing.Span {
+	tracer, ok := ctx.Value(KeyTracer).(opentracing.Tracer)
+	if !ok {
+		tracer = opentracing.GlobalTracer()
+	}
+	var opts []opentracing.StartSpanOption
+	if parent, ok := ctx.Value(KeySpan).(opentracing.Span); ok {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	return tracer.StartSpan(operation, opts...)
+}
+
+// EvalWithSpan evaluates the function in a new span, the function
+// is passed the context carrying the span, the span is tagged
+// with the error returned by the function
+func EvalWithSpan(ctx ExecutionContext, operation string, eval func(ExecutionContext) (interface{}, error)) (interface{}, error) {
+	ctx, span := StartSpan(ctx, operation)
+	out, err := eval(ctx)
+	FinishSpan(span, err)
+	return out, err
+}
+
+// FinishSpan tags the span with the error and finishes it,
+// return statements are not errors
+func FinishSpan(span opentracing.Span, err error) {
+	if _, ok := isReturn(err); err != nil && !ok {
+		ext.Error.Set(span, true)
+		span.LogFields(log.String("event", "error"), log.Error(err))
+	}
+	span.Finish()
+}
+
+// ActiveSpan returns the span of the context,
+// or a no-op span if the context has no span
+func ActiveSpan(ctx ExecutionContext) opentracing.Span {
+	span, ok := ctx.Value(KeySpan).(opentracing.Span)
+	if !ok {
+		return opentracing.NoopTracer{}.StartSpan("")
+	}
+	return span
+}
+
+// spanScope is a context carrying the span, unlike runtime
+// scope it does not create a new scope for the variables
+type spanScope struct {
+	ExecutionContext
+	span opentracing.Span
+}
+
+// Value returns the span or the value of the wrapped context
+func (s *spanScope) Value(key interface{}) interface{} {
+	if key == KeySpan {
+		return s.span
+	}
+	return s.ExecutionContext.Value(key)
+}
@@ -0,0 +1,217 @@
+package force
+
+import (
+	"github.com/gravitational/trace"
+)
+
+// Try evaluates the action, errors are handled by the optional
+// Catch clause, the optional Finally clause always runs last:
+//
+// Try(func(){
+// Command("make test")
+// }(), Catch(func(err string){
+// Infof("Tests failed: %v", err)
+// }), Finally(func(){
+// Command("make clean")
+// }()))
+//
+func Try(action Action, clauses ...interface{}) (Action, error) {
+	action, err := callableAction(action)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	t := &TryAction{action: action}
+	for _, clause := range clauses {
+		switch c := clause.(type) {
+		case *CatchClause:
+			if t.catch != nil {
+				return nil, trace.BadParameter("only one Catch clause is allowed")
+			}
+			if t.finally != nil {
+				return nil, trace.BadParameter("Catch clause should go before Finally")
+			}
+			t.catch = c
+		case *FinallyClause:
+			if t.finally != nil {
+				return nil, trace.BadParameter("only one Finally clause is allowed")
+			}
+			t.finally = c
+		default:
+			return nil, trace.BadParameter("expected Catch or Finally clause, got %T", clause)
+		}
+	}
+	return t, nil
+}
+
+// callableAction converts lambda functions without arguments
+// into calls, so Try(func(){...}) runs the function
+func callableAction(action Action) (Action, error) {
+	lambda, ok := action.(*LambdaFunction)
+	if !ok {
+		return action, nil
+	}
+	call, err := lambda.NewCall()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return call, nil
+}
+
+// TryAction evaluates the action and handles errors
+type TryAction struct {
+	action  Action
+	catch   *CatchClause
+	finally *FinallyClause
+}
+
+// Type returns the type of the action
+func (t *TryAction) Type() interface{} {
+	return t.action.Type()
+}
+
+// Children returns the action and the clauses
+func (t *TryAction) Children() []interface{} {
+	out := []interface{}{t.action}
+	if t.catch != nil {
+		out = append(out, t.catch.fn)
+	}
+	if t.finally != nil {
+		out = append(out, t.finally.action)
+	}
+	return out
+}
+
+// Eval evaluates the action in a new scope, so the error
+// handled by the Catch clause is not seen by the enclosing sequence
+func (t *TryAction) Eval(ctx ExecutionContext) (interface{}, error) {
+	return EvalWithSpan(ctx, "Try", t.eval)
+}
+
+// eval evaluates the action and the clauses,
+// the clauses are traced in their own spans
+func (t *TryAction) eval(ctx ExecutionContext) (interface{}, error) {
+	tryCtx := WithRuntimeScope(ctx)
+	out, err := t.action.Eval(tryCtx)
+	if _, ok := isReturn(err); !ok && err != nil && t.catch != nil {
+		catchCtx, span := StartSpan(WithRuntimeScope(tryCtx), "Catch")
+		SetError(catchCtx, err)
+		call := &LambdaFunctionCall{
+			Expression: t.catch.fn,
+			Arguments:  []interface{}{String(err.Error())},
+		}
+		out = t.action.Type()
+		_, err = call.Eval(catchCtx)
+		FinishSpan(span, err)
+	}
+	if t.finally == nil {
+		return out, err
+	}
+	finallyCtx, span := StartSpan(WithRuntimeScope(tryCtx), "Finally")
+	if _, ok := isReturn(err); !ok {
+		SetError(finallyCtx, err)
+	}
+	_, ferr := t.finally.action.Eval(finallyCtx)
+	FinishSpan(span, ferr)
+	if ferr != nil {
+		if err == nil {
+			return out, trace.Wrap(ferr)
+		}
+		return out, trace.NewAggregate(err, ferr)
+	}
+	return out, err
+}
+
+// MarshalCode marshals action into code representation
+func (t *TryAction) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	call := &FnCall{
+		Fn:   Try,
+		Args: []interface{}{t.action},
+	}
+	if t.catch != nil {
+		call.Args = append(call.Args, t.catch)
+	}
+	if t.finally != nil {
+		call.Args = append(call.Args, t.finally)
+	}
+	return call.MarshalCode(ctx)
+}
+
+// Catch returns a clause handling the error of the Try action,
+// the function is called with the error message, the error
+// is also available in the function as Error()
+func Catch(fn Expression) (*CatchClause, error) {
+	if _, err := ExpectLambdaFunction(fn); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	call := &LambdaFunctionCall{Expression: fn, Arguments: []interface{}{String("")}}
+	if err := call.CheckCall(); err != nil {
+		return nil, trace.BadParameter("Catch expects func(err string): %v", err)
+	}
+	return &CatchClause{fn: fn}, nil
+}
+
+// CatchClause is an error handler of the Try action
+type CatchClause struct {
+	fn Expression
+}
+
+// MarshalCode marshals clause into code representation
+func (c *CatchClause) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Catch, c.fn).MarshalCode(ctx)
+}
+
+// Finally returns a clause evaluated after the Try action
+// and the Catch clause, whether they failed or not
+func Finally(action Action) (*FinallyClause, error) {
+	action, err := callableAction(action)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return &FinallyClause{action: action}, nil
+}
+
+// FinallyClause is evaluated at the end of the Try action
+type FinallyClause struct {
+	action Action
+}
+
+// MarshalCode marshals clause into code representation
+func (f *FinallyClause) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	return NewFnCall(Finally, f.action).MarshalCode(ctx)
+}
+
+// LastError returns expression evaluating to the message
+// of the error in the current scope, or empty string
+// if there is no error, for example in deferred actions:
+//
+// Defer(func(){
+// If(Error() != "", Infof("Build failed: %v", Error()))
+// }())
+//
+func LastError() Expression {
+	return &ErrorExpr{}
+}
+
+// ErrorExpr evaluates to the error message
+type ErrorExpr struct {
+}
+
+// Type returns string type
+func (e *ErrorExpr) Type() interface{} {
+	return ""
+}
+
+// Eval returns the error message, or empty string
+func (e *ErrorExpr) Eval(ctx ExecutionContext) (interface{}, error) {
+	err := Error(ctx)
+	if err == nil {
+		return "", nil
+	}
+	return err.Error(), nil
+}
+
+// MarshalCode marshals expression into code representation
+func (e *ErrorExpr) MarshalCode(ctx ExecutionContext) ([]byte, error) {
+	call := &FnCall{FnName: "Error"}
+	return call.MarshalCode(ctx)
+}
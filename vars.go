@@ -32,7 +32,7 @@ func Var(group Group) func(name String, fields ...String) (interface{}, error) {
 		if ok {
 			return &VarRef{name: name, fields: fields, varType: e.Type()}, nil
 		}
-		return &VarRef{name: name, fields: fields, varType: reflect.TypeOf(v)}, nil
+		return &VarRef{name: name, fields: fields, varType: v}, nil
 	}
 }
 
@@ -63,8 +63,10 @@ func GetField(v interface{}, name String, fields ...String) (interface{}, error)
 
 // VarRef is a variable reference, evaluates to the expression
 type VarRef struct {
-	name    String
-	fields  []String
+	name   String
+	fields []String
+	// varType is a value of the variable type returned by Type,
+	// for variables that are not expressions it is the value itself
 	varType interface{}
 }
 
@@ -98,7 +100,11 @@ func (v *VarRef) Eval(ctx ExecutionContext) (interface{}, error) {
 
 // MarshalCode evaluates bool variable reference to code representation
 func (v *VarRef) MarshalCode(ctx ExecutionContext) ([]byte, error) {
-	return NewFnCall(Var, v.name).MarshalCode(ctx)
+	args := []interface{}{v.name}
+	for _, field := range v.fields {
+		args = append(args, field)
+	}
+	return NewFnCall(Var, args...).MarshalCode(ctx)
 }
 
 // NewDefine specifies a new define action
@@ -135,6 +141,11 @@ func (p *DefineAction) Type() interface{} {
 	return ExpressionType(p.value)
 }
 
+// Children returns the defined value
+func (p *DefineAction) Children() []interface{} {
+	return []interface{}{p.value}
+}
+
 // ExpressionType returns a type evaluated by expression
 func ExpressionType(in interface{}) interface{} {
 	e, ok := in.(Expression)
@@ -35,6 +35,15 @@ type FSNotify struct {
 	eventsC chan Event
 }
 
+// Watches returns watched files
+func (f *FSNotify) Watches() []Resource {
+	out := make([]Resource, len(f.Files))
+	for i, file := range f.Files {
+		out[i] = Resource{Kind: ResourceFile, Name: file}
+	}
+	return out
+}
+
 // MarshalCode marshals channel to code
 func (f *FSNotify) MarshalCode(ctx ExecutionContext) ([]byte, error) {
 	call := &FnCall{